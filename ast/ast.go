@@ -2,6 +2,8 @@ package ast
 
 import (
 	"fmt"
+	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/dstdfx/scroopy/token"
@@ -11,6 +13,8 @@ import (
 type Node interface {
 	fmt.Stringer
 	TokenLiteral() string
+	Pos() token.Position // position of the first character belonging to the node
+	End() token.Position // position of the first character immediately after the node
 }
 
 // Statement describes AST statement e.x: variable and etc.
@@ -28,6 +32,7 @@ type Expression interface {
 // Root represents a root node of program's AST.
 type Root struct {
 	Statements []Statement
+	Comments   []*Comment // comments of the program, collected if the lexer scans them
 }
 
 func (r *Root) String() string {
@@ -40,6 +45,22 @@ func (r *Root) String() string {
 	return strBuilder.String()
 }
 
+func (r *Root) Pos() token.Position {
+	if len(r.Statements) > 0 {
+		return r.Statements[0].Pos()
+	}
+
+	return token.Position{}
+}
+
+func (r *Root) End() token.Position {
+	if n := len(r.Statements); n > 0 {
+		return r.Statements[n-1].End()
+	}
+
+	return token.Position{}
+}
+
 func (r *Root) TokenLiteral() string {
 	if len(r.Statements) > 0 {
 		return r.Statements[0].TokenLiteral()
@@ -48,6 +69,28 @@ func (r *Root) TokenLiteral() string {
 	return ""
 }
 
+// Comment represents a `//` line comment or a `/* */` block comment.
+// Comments aren't statements, they're kept aside of the tree in Root.Comments.
+type Comment struct {
+	Token token.Token // token.COMMENT
+}
+
+func (c *Comment) String() string {
+	return c.Token.Literal
+}
+
+func (c *Comment) Pos() token.Position {
+	return c.Token.Pos
+}
+
+func (c *Comment) End() token.Position {
+	return c.Token.End
+}
+
+func (c *Comment) TokenLiteral() string {
+	return c.Token.Literal
+}
+
 // Identifier represents statement's identifier.
 type Identifier struct {
 	Token token.Token // token.IDENT
@@ -58,6 +101,14 @@ func (i *Identifier) String() string {
 	return i.Value
 }
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
+func (i *Identifier) End() token.Position {
+	return i.Token.End
+}
+
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
@@ -86,6 +137,21 @@ func (l *LetStatement) String() string {
 	return strBuilder.String()
 }
 
+func (l *LetStatement) Pos() token.Position {
+	return l.Token.Pos
+}
+
+func (l *LetStatement) End() token.Position {
+	switch {
+	case l.Value != nil:
+		return l.Value.End()
+	case l.Name != nil:
+		return l.Name.End()
+	default:
+		return l.Token.End
+	}
+}
+
 func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
@@ -109,12 +175,170 @@ func (r *ReturnStatement) String() string {
 	return strBuilder.String()
 }
 
+func (r *ReturnStatement) Pos() token.Position {
+	return r.Token.Pos
+}
+
+func (r *ReturnStatement) End() token.Position {
+	if r.Value != nil {
+		return r.Value.End()
+	}
+
+	return r.Token.End
+}
+
 func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
 
 func (r *ReturnStatement) statementNode() {}
 
+// AssignStatement represents `<target> = <value>` statement and its compound
+// forms such as `<target> += <value>`. The target is either an identifier
+// or an index expression.
+type AssignStatement struct {
+	Token    token.Token // The assignment operator token
+	Target   Expression
+	Operator string // the infix operator of a compound assignment, empty for `=`
+	Value    Expression
+}
+
+func (as *AssignStatement) String() string {
+	strBuilder := strings.Builder{}
+	strBuilder.WriteString(as.Target.String())
+	strBuilder.WriteByte(' ')
+	strBuilder.WriteString(as.Token.Literal)
+	strBuilder.WriteByte(' ')
+	strBuilder.WriteString(as.Value.String())
+	strBuilder.WriteByte(';')
+
+	return strBuilder.String()
+}
+
+func (as *AssignStatement) Pos() token.Position {
+	if as.Target != nil {
+		return as.Target.Pos()
+	}
+
+	return as.Token.Pos
+}
+
+func (as *AssignStatement) End() token.Position {
+	if as.Value != nil {
+		return as.Value.End()
+	}
+
+	return as.Token.End
+}
+
+func (as *AssignStatement) TokenLiteral() string {
+	return as.Token.Literal
+}
+
+func (as *AssignStatement) statementNode() {}
+
+// WhileStatement represents `while (<condition>) { <body> }` loop.
+type WhileStatement struct {
+	Token     token.Token // The `while` token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) String() string {
+	strBuilder := strings.Builder{}
+	strBuilder.WriteString("while (")
+	strBuilder.WriteString(ws.Condition.String())
+	strBuilder.WriteString(") ")
+	strBuilder.WriteString(ws.Body.String())
+
+	return strBuilder.String()
+}
+
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+
+func (ws *WhileStatement) End() token.Position {
+	if ws.Body != nil {
+		return ws.Body.End()
+	}
+
+	return ws.Token.End
+}
+
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+// ForStatement represents `for (<value> in <iterable>) { <body> }` and
+// `for (<key>, <value> in <iterable>) { <body> }` loops.
+type ForStatement struct {
+	Token    token.Token // The `for` token
+	Key      *Identifier // nil if the loop binds a single variable
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) String() string {
+	strBuilder := strings.Builder{}
+	strBuilder.WriteString("for (")
+	if fs.Key != nil {
+		strBuilder.WriteString(fs.Key.String())
+		strBuilder.WriteString(", ")
+	}
+	strBuilder.WriteString(fs.Value.String())
+	strBuilder.WriteString(" in ")
+	strBuilder.WriteString(fs.Iterable.String())
+	strBuilder.WriteString(") ")
+	strBuilder.WriteString(fs.Body.String())
+
+	return strBuilder.String()
+}
+
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+
+func (fs *ForStatement) End() token.Position {
+	if fs.Body != nil {
+		return fs.Body.End()
+	}
+
+	return fs.Token.End
+}
+
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForStatement) statementNode() {}
+
+// BranchStatement represents `break` and `continue` statements.
+type BranchStatement struct {
+	Token token.Token // The `break` or `continue` token
+}
+
+func (bs *BranchStatement) String() string {
+	return bs.Token.Literal + ";"
+}
+
+func (bs *BranchStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BranchStatement) End() token.Position {
+	return bs.Token.End
+}
+
+func (bs *BranchStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BranchStatement) statementNode() {}
+
 // ExpressionStatement represents expression statement.
 type ExpressionStatement struct {
 	Token      token.Token
@@ -131,6 +355,22 @@ func (e *ExpressionStatement) String() string {
 
 func (e *ExpressionStatement) statementNode() {}
 
+func (e *ExpressionStatement) Pos() token.Position {
+	if e.Expression != nil {
+		return e.Expression.Pos()
+	}
+
+	return e.Token.Pos
+}
+
+func (e *ExpressionStatement) End() token.Position {
+	if e.Expression != nil {
+		return e.Expression.End()
+	}
+
+	return e.Token.End
+}
+
 func (e *ExpressionStatement) TokenLiteral() string {
 	return e.Token.Literal
 }
@@ -144,6 +384,18 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode() {}
 
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+
+	return pe.Token.End
+}
+
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
@@ -169,6 +421,22 @@ type InfixExpression struct {
 
 func (oe *InfixExpression) expressionNode() {}
 
+func (oe *InfixExpression) Pos() token.Position {
+	if oe.Left != nil {
+		return oe.Left.Pos()
+	}
+
+	return oe.Token.Pos
+}
+
+func (oe *InfixExpression) End() token.Position {
+	if oe.Right != nil {
+		return oe.Right.End()
+	}
+
+	return oe.Token.End
+}
+
 func (oe *InfixExpression) TokenLiteral() string {
 	return oe.Token.Literal
 }
@@ -190,10 +458,19 @@ func (oe *InfixExpression) String() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // value of literals out of the int64 range, nil otherwise
 }
 
 func (il *IntegerLiteral) expressionNode() {}
 
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+
+func (il *IntegerLiteral) End() token.Position {
+	return il.Token.End
+}
+
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
@@ -202,6 +479,30 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// FloatLiteral represents string representation of a floating-point number.
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FloatLiteral) End() token.Position {
+	return fl.Token.End
+}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // BooleanLiteral represents string representation of boolean.
 type BooleanLiteral struct {
 	Token token.Token
@@ -210,6 +511,14 @@ type BooleanLiteral struct {
 
 func (b *BooleanLiteral) expressionNode() {}
 
+func (b *BooleanLiteral) Pos() token.Position {
+	return b.Token.Pos
+}
+
+func (b *BooleanLiteral) End() token.Position {
+	return b.Token.End
+}
+
 func (b *BooleanLiteral) TokenLiteral() string {
 	return b.Token.Literal
 }
@@ -226,6 +535,14 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode() {}
 
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+
+func (sl *StringLiteral) End() token.Position {
+	return sl.Token.End
+}
+
 func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Value
 }
@@ -244,6 +561,21 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode() {}
 
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
+func (ie *IfExpression) End() token.Position {
+	switch {
+	case ie.Alternative != nil:
+		return ie.Alternative.End()
+	case ie.Consequence != nil:
+		return ie.Consequence.End()
+	default:
+		return ie.Token.End
+	}
+}
+
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
@@ -267,10 +599,19 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // The `{` token
 	Statements []Statement
+	Rbrace     token.Position // position of the closing `}`
 }
 
 func (bs *BlockStatement) statementNode() {}
 
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BlockStatement) End() token.Position {
+	return after(bs.Rbrace)
+}
+
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
@@ -289,11 +630,25 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	Defaults   []Expression // default values of the parameters, nil for the required ones
+	Rest       *Identifier  // parameter collecting extra arguments into an array, if any
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+
+	return fl.Token.End
+}
+
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
@@ -301,8 +656,15 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 func (fl *FunctionLiteral) String() string {
 	strBuilder := strings.Builder{}
 	params := make([]string, 0)
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+fl.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 	strBuilder.WriteString(fl.TokenLiteral())
 	strBuilder.WriteByte('(')
@@ -313,15 +675,70 @@ func (fl *FunctionLiteral) String() string {
 	return strBuilder.String()
 }
 
+// MacroLiteral represents macro definition, `macro(<parameters>) { <body> }`.
+// Macros are bound with top-level `let` statements and expanded before
+// the program is executed.
+type MacroLiteral struct {
+	Token      token.Token // The `macro` token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+
+func (ml *MacroLiteral) Pos() token.Position {
+	return ml.Token.Pos
+}
+
+func (ml *MacroLiteral) End() token.Position {
+	if ml.Body != nil {
+		return ml.Body.End()
+	}
+
+	return ml.Token.End
+}
+
+func (ml *MacroLiteral) TokenLiteral() string {
+	return ml.Token.Literal
+}
+
+func (ml *MacroLiteral) String() string {
+	strBuilder := strings.Builder{}
+	params := make([]string, 0)
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	strBuilder.WriteString(ml.TokenLiteral())
+	strBuilder.WriteByte('(')
+	strBuilder.WriteString(strings.Join(params, ", "))
+	strBuilder.WriteString(") ")
+	strBuilder.WriteString(ml.Body.String())
+
+	return strBuilder.String()
+}
+
 // CallExpression represents function call.
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // The `(` token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Position // position of the closing `)`
 }
 
 func (ce *CallExpression) expressionNode() {}
 
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+
+	return ce.Token.Pos
+}
+
+func (ce *CallExpression) End() token.Position {
+	return after(ce.Rparen)
+}
+
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
@@ -340,14 +757,52 @@ func (ce *CallExpression) String() string {
 	return strBuilder.String()
 }
 
+// SpreadExpression represents a call argument expanded to the elements
+// of the array it evaluates to, e.g. `f(...args)`.
+type SpreadExpression struct {
+	Token token.Token // The `...` token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode() {}
+
+func (se *SpreadExpression) Pos() token.Position {
+	return se.Token.Pos
+}
+
+func (se *SpreadExpression) End() token.Position {
+	if se.Value != nil {
+		return se.Value.End()
+	}
+
+	return se.Token.End
+}
+
+func (se *SpreadExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
 // ArrayLiteral represents an array containing a list of expressions.
 type ArrayLiteral struct {
-	Token    token.Token
+	Token    token.Token // The `[` token
 	Elements []Expression
+	Rbracket token.Position // position of the closing `]`
 }
 
 func (a *ArrayLiteral) expressionNode() {}
 
+func (a *ArrayLiteral) Pos() token.Position {
+	return a.Token.Pos
+}
+
+func (a *ArrayLiteral) End() token.Position {
+	return after(a.Rbracket)
+}
+
 func (a *ArrayLiteral) TokenLiteral() string {
 	return a.Token.Literal
 }
@@ -368,13 +823,26 @@ func (a *ArrayLiteral) String() string {
 
 // IndexExpression represents index expression: <left-expression>[<index-expression>].
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The `[` token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position // position of the closing `]`
 }
 
 func (ie *IndexExpression) expressionNode() {}
 
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+
+	return ie.Token.Pos
+}
+
+func (ie *IndexExpression) End() token.Position {
+	return after(ie.Rbracket)
+}
+
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
@@ -393,12 +861,40 @@ func (ie *IndexExpression) String() string {
 
 // HashLiteral represents hash literal: { <expression>: <expression> }.
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Rbrace token.Position // position of the closing '}'
 }
 
 func (hl *HashLiteral) expressionNode() {}
 
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+
+func (hl *HashLiteral) End() token.Position {
+	return after(hl.Rbrace)
+}
+
+// SortedKeys returns keys of the hash literal in the source order,
+// keys without positions are ordered by their string representation.
+func (hl *HashLiteral) SortedKeys() []Expression {
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if pi, pj := keys[i].Pos().Offset, keys[j].Pos().Offset; pi != pj {
+			return pi < pj
+		}
+
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
+
 func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
@@ -425,3 +921,16 @@ func (hl *HashLiteral) String() string {
 
 	return strBuilder.String()
 }
+
+// after returns the position immediately after the single-character
+// token located at the given position.
+func after(pos token.Position) token.Position {
+	if !pos.IsValid() {
+		return pos
+	}
+
+	pos.Offset++
+	pos.Column++
+
+	return pos
+}
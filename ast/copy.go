@@ -0,0 +1,215 @@
+package ast
+
+// Copy returns a deep copy of the tree rooted at the node, so the copy
+// can be modified without affecting the original tree.
+func Copy(node Node) Node {
+	switch node := node.(type) {
+	case *Root:
+		c := *node
+		c.Statements = copyStatements(node.Statements)
+
+		return &c
+	case *Comment:
+		c := *node
+
+		return &c
+	case *Identifier:
+		c := *node
+
+		return &c
+	case *ExpressionStatement:
+		c := *node
+		c.Expression = copyExpression(node.Expression)
+
+		return &c
+	case *LetStatement:
+		c := *node
+		c.Name = copyIdentifier(node.Name)
+		c.Value = copyExpression(node.Value)
+
+		return &c
+	case *ReturnStatement:
+		c := *node
+		c.Value = copyExpression(node.Value)
+
+		return &c
+	case *AssignStatement:
+		c := *node
+		c.Target = copyExpression(node.Target)
+		c.Value = copyExpression(node.Value)
+
+		return &c
+	case *WhileStatement:
+		c := *node
+		c.Condition = copyExpression(node.Condition)
+		c.Body = copyBlock(node.Body)
+
+		return &c
+	case *ForStatement:
+		c := *node
+		c.Key = copyIdentifier(node.Key)
+		c.Value = copyIdentifier(node.Value)
+		c.Iterable = copyExpression(node.Iterable)
+		c.Body = copyBlock(node.Body)
+
+		return &c
+	case *BranchStatement:
+		c := *node
+
+		return &c
+	case *BlockStatement:
+		return copyBlock(node)
+	case *PrefixExpression:
+		c := *node
+		c.Right = copyExpression(node.Right)
+
+		return &c
+	case *InfixExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Right = copyExpression(node.Right)
+
+		return &c
+	case *IntegerLiteral:
+		c := *node
+
+		return &c
+	case *FloatLiteral:
+		c := *node
+
+		return &c
+	case *BooleanLiteral:
+		c := *node
+
+		return &c
+	case *StringLiteral:
+		c := *node
+
+		return &c
+	case *IfExpression:
+		c := *node
+		c.Condition = copyExpression(node.Condition)
+		c.Consequence = copyBlock(node.Consequence)
+		c.Alternative = copyBlock(node.Alternative)
+
+		return &c
+	case *FunctionLiteral:
+		c := *node
+		c.Parameters = copyIdentifiers(node.Parameters)
+		if node.Defaults != nil {
+			c.Defaults = copyExpressions(node.Defaults)
+		}
+		c.Rest = copyIdentifier(node.Rest)
+		c.Body = copyBlock(node.Body)
+
+		return &c
+	case *MacroLiteral:
+		c := *node
+		c.Parameters = copyIdentifiers(node.Parameters)
+		c.Body = copyBlock(node.Body)
+
+		return &c
+	case *CallExpression:
+		c := *node
+		c.Function = copyExpression(node.Function)
+		c.Arguments = copyExpressions(node.Arguments)
+
+		return &c
+	case *SpreadExpression:
+		c := *node
+		c.Value = copyExpression(node.Value)
+
+		return &c
+	case *ArrayLiteral:
+		c := *node
+		c.Elements = copyExpressions(node.Elements)
+
+		return &c
+	case *IndexExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Index = copyExpression(node.Index)
+
+		return &c
+	case *HashLiteral:
+		c := *node
+		c.Pairs = make(map[Expression]Expression, len(node.Pairs))
+		for key, value := range node.Pairs {
+			c.Pairs[copyExpression(key)] = copyExpression(value)
+		}
+
+		return &c
+	}
+
+	return node
+}
+
+func copyExpression(expr Expression) Expression {
+	if expr == nil {
+		return nil
+	}
+
+	c, _ := Copy(expr).(Expression)
+
+	return c
+}
+
+func copyExpressions(exprs []Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+
+	c := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		c[i] = copyExpression(expr)
+	}
+
+	return c
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+
+	c := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		c[i], _ = Copy(stmt).(Statement)
+	}
+
+	return c
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+
+	c := *ident
+
+	return &c
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+
+	c := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		c[i] = copyIdentifier(ident)
+	}
+
+	return c
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	c := *block
+	c.Statements = copyStatements(block.Statements)
+
+	return &c
+}
@@ -0,0 +1,109 @@
+package ast
+
+// ModifierFunc returns the node replacing the given one in the tree,
+// the node itself is returned to keep it.
+type ModifierFunc func(Node) Node
+
+// Modify rewrites the tree rooted at the node bottom-up: the children
+// of a node are modified before the node itself is passed to the modifier.
+// Nodes are modified in place, the modified root is returned.
+// Identifiers being bound, such as names of `let` statements and function
+// parameters, are only replaced with other identifiers. Comments of the root
+// aren't passed to the modifier, missing children are skipped.
+func Modify(node Node, modifier ModifierFunc) Node {
+	if node == nil {
+		return nil
+	}
+
+	switch node := node.(type) {
+	case *Root:
+		for i, stmt := range node.Statements {
+			node.Statements[i], _ = Modify(stmt, modifier).(Statement)
+		}
+	case *ExpressionStatement:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+	case *LetStatement:
+		node.Name = modifyIdentifier(node.Name, modifier)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ReturnStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *AssignStatement:
+		node.Target, _ = Modify(node.Target, modifier).(Expression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForStatement:
+		if node.Key != nil {
+			node.Key = modifyIdentifier(node.Key, modifier)
+		}
+		node.Value = modifyIdentifier(node.Value, modifier)
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *BlockStatement:
+		for i, stmt := range node.Statements {
+			node.Statements[i], _ = Modify(stmt, modifier).(Statement)
+		}
+	case *PrefixExpression:
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *InfixExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *IfExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(param, modifier)
+		}
+		for i, value := range node.Defaults {
+			if value != nil {
+				node.Defaults[i], _ = Modify(value, modifier).(Expression)
+			}
+		}
+		if node.Rest != nil {
+			node.Rest = modifyIdentifier(node.Rest, modifier)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *MacroLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(param, modifier)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *CallExpression:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+		for i, arg := range node.Arguments {
+			node.Arguments[i], _ = Modify(arg, modifier).(Expression)
+		}
+	case *SpreadExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ArrayLiteral:
+		for i, el := range node.Elements {
+			node.Elements[i], _ = Modify(el, modifier).(Expression)
+		}
+	case *IndexExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for _, key := range node.SortedKeys() {
+			newKey, _ := Modify(key, modifier).(Expression)
+			newValue, _ := Modify(node.Pairs[key], modifier).(Expression)
+			pairs[newKey] = newValue
+		}
+		node.Pairs = pairs
+	}
+
+	return modifier(node)
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+
+	return ident
+}
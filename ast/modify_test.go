@@ -0,0 +1,127 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/token"
+)
+
+func TestModify(t *testing.T) {
+	one := func() ast.Expression { return &ast.IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1} }
+	two := func() ast.Expression { return &ast.IntegerLiteral{Token: token.Token{Literal: "2"}, Value: 2} }
+	block := func(e ast.Expression) *ast.BlockStatement {
+		return &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: e}}}
+	}
+
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+
+		return two()
+	}
+
+	tests := []struct {
+		input    ast.Node
+		expected ast.Node
+	}{
+		{one(), two()},
+		{
+			&ast.Root{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			&ast.Root{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&ast.InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.PrefixExpression{Operator: "-", Right: one()},
+			&ast.PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&ast.IndexExpression{Left: one(), Index: one()},
+			&ast.IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&ast.IfExpression{Condition: one(), Consequence: block(one()), Alternative: block(one())},
+			&ast.IfExpression{Condition: two(), Consequence: block(two()), Alternative: block(two())},
+		},
+		{
+			&ast.ReturnStatement{Value: one()},
+			&ast.ReturnStatement{Value: two()},
+		},
+		{
+			&ast.LetStatement{Name: &ast.Identifier{Value: "x"}, Value: one()},
+			&ast.LetStatement{Name: &ast.Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&ast.FunctionLiteral{
+				Parameters: []*ast.Identifier{{Value: "x"}},
+				Defaults:   []ast.Expression{one()},
+				Body:       block(one()),
+			},
+			&ast.FunctionLiteral{
+				Parameters: []*ast.Identifier{{Value: "x"}},
+				Defaults:   []ast.Expression{two()},
+				Body:       block(two()),
+			},
+		},
+		{
+			&ast.CallExpression{Function: &ast.Identifier{Value: "f"}, Arguments: []ast.Expression{one(), one()}},
+			&ast.CallExpression{Function: &ast.Identifier{Value: "f"}, Arguments: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.ArrayLiteral{Elements: []ast.Expression{one(), one()}},
+			&ast.ArrayLiteral{Elements: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.WhileStatement{Condition: one(), Body: block(one())},
+			&ast.WhileStatement{Condition: two(), Body: block(two())},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := ast.Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+
+	hashLiteral := &ast.HashLiteral{Pairs: map[ast.Expression]ast.Expression{one(): one()}}
+	ast.Modify(hashLiteral, turnOneIntoTwo)
+	for key, value := range hashLiteral.Pairs {
+		if key.(*ast.IntegerLiteral).Value != 2 || value.(*ast.IntegerLiteral).Value != 2 {
+			t.Errorf("hash literal pair is not modified. got=%s: %s", key, value)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	original := &ast.CallExpression{
+		Function:  &ast.Identifier{Value: "f"},
+		Arguments: []ast.Expression{&ast.IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1}},
+	}
+
+	copied := ast.Copy(original)
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("copy is not equal to the original. got=%#v", copied)
+	}
+
+	ast.Modify(copied, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.IntegerLiteral); ok {
+			return &ast.IntegerLiteral{Token: token.Token{Literal: "2"}, Value: 2}
+		}
+
+		return node
+	})
+
+	if original.String() != "f(1)" {
+		t.Errorf("modifying the copy changed the original. got=%s", original)
+	}
+	if copied.String() != "f(2)" {
+		t.Errorf("copy is not modified. got=%s", copied)
+	}
+}
@@ -0,0 +1,124 @@
+package ast
+
+// Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of the node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at the node in depth-first order:
+// it starts by calling v.Visit(node), then walks the children of the node
+// in the source order, comments of the root are walked after its statements.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Root:
+		for _, stmt := range n.Statements {
+			Walk(v, stmt)
+		}
+		for _, comment := range n.Comments {
+			Walk(v, comment)
+		}
+	case *ExpressionStatement:
+		walkIfPresent(v, n.Expression)
+	case *LetStatement:
+		Walk(v, n.Name)
+		walkIfPresent(v, n.Value)
+	case *ReturnStatement:
+		walkIfPresent(v, n.Value)
+	case *AssignStatement:
+		walkIfPresent(v, n.Target)
+		walkIfPresent(v, n.Value)
+	case *WhileStatement:
+		walkIfPresent(v, n.Condition)
+		Walk(v, n.Body)
+	case *ForStatement:
+		if n.Key != nil {
+			Walk(v, n.Key)
+		}
+		Walk(v, n.Value)
+		walkIfPresent(v, n.Iterable)
+		Walk(v, n.Body)
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			Walk(v, stmt)
+		}
+	case *PrefixExpression:
+		walkIfPresent(v, n.Right)
+	case *InfixExpression:
+		walkIfPresent(v, n.Left)
+		walkIfPresent(v, n.Right)
+	case *IfExpression:
+		walkIfPresent(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			Walk(v, param)
+			if n.Defaults != nil && n.Defaults[i] != nil {
+				Walk(v, n.Defaults[i])
+			}
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+		Walk(v, n.Body)
+	case *MacroLiteral:
+		for _, param := range n.Parameters {
+			Walk(v, param)
+		}
+		Walk(v, n.Body)
+	case *CallExpression:
+		walkIfPresent(v, n.Function)
+		for _, arg := range n.Arguments {
+			Walk(v, arg)
+		}
+	case *SpreadExpression:
+		walkIfPresent(v, n.Value)
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			Walk(v, el)
+		}
+	case *IndexExpression:
+		walkIfPresent(v, n.Left)
+		walkIfPresent(v, n.Index)
+	case *HashLiteral:
+		for _, key := range n.SortedKeys() {
+			Walk(v, key)
+			Walk(v, n.Pairs[key])
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// walkIfPresent walks the expression unless it's missing, e.g. in trees
+// built by hand or recovered from parsing errors.
+func walkIfPresent(v Visitor, expr Expression) {
+	if expr != nil {
+		Walk(v, expr)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect traverses the tree rooted at the node in depth-first order:
+// it starts by calling f(node), if f returns true, Inspect invokes f
+// recursively for each of the children of the node, followed by f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
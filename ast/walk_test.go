@@ -0,0 +1,218 @@
+package ast_test
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+// everyNodeKind is a program containing every kind of node.
+const everyNodeKind = `
+// line comment
+let add = fn(a, b = 2, ...rest) { return a + b; };
+let m = macro(x) { quote(unquote(x)) };
+let h = {"one": 1, 2: 2.5};
+let arr = [1, true, "s"];
+arr[0] = -arr[1];
+while (true) { break; }
+for (k, v in h) { continue; }
+if (1 < 2) { add(...arr) } else { add(1) }
+/* block comment */
+`
+
+// nodeKinds returns every node type declared in the package, the types
+// with the Pos method, read from the package sources.
+func nodeKinds(t *testing.T) []string {
+	t.Helper()
+
+	pkgs, err := goparser.ParseDir(gotoken.NewFileSet(), ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("failed to parse the package: %s", err)
+	}
+
+	var kinds []string
+	for _, file := range pkgs["ast"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "Pos" {
+				continue
+			}
+			if star, ok := fn.Recv.List[0].Type.(*goast.StarExpr); ok {
+				kinds = append(kinds, "*ast."+star.X.(*goast.Ident).Name)
+			}
+		}
+	}
+
+	return kinds
+}
+
+func parseEveryNodeKind(t *testing.T) *ast.Root {
+	t.Helper()
+
+	p := parser.New(lexer.NewWithMode("", everyNodeKind, lexer.ScanComments))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func TestInspectCoversEveryNodeKind(t *testing.T) {
+	program := parseEveryNodeKind(t)
+
+	visited := make(map[ast.Node]bool)
+	kinds := make(map[string]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			visited[node] = true
+			kinds[fmt.Sprintf("%T", node)] = true
+		}
+
+		return true
+	})
+
+	kindsDeclared := nodeKinds(t)
+	if len(kindsDeclared) == 0 {
+		t.Fatalf("no node types found")
+	}
+	for _, kind := range kindsDeclared {
+		if !kinds[kind] {
+			t.Errorf("%s is not visited", kind)
+		}
+	}
+
+	// Every child node reachable through the fields of the visited nodes
+	// has to be visited as well.
+	for node := range visited {
+		for _, child := range children(node) {
+			if !visited[child] {
+				t.Errorf("child %T (%s) of %T is not visited", child, child, node)
+			}
+		}
+	}
+}
+
+func TestModifyCoversEveryNodeKind(t *testing.T) {
+	program := parseEveryNodeKind(t)
+
+	modified := make(map[ast.Node]bool)
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		modified[node] = true
+
+		return node
+	})
+
+	all := make(map[ast.Node]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			all[node] = true
+		}
+
+		return true
+	})
+
+	for node := range all {
+		if _, ok := node.(*ast.Comment); ok {
+			continue
+		}
+		if !modified[node] {
+			t.Errorf("%T (%s) is not passed to the modifier", node, node)
+		}
+	}
+}
+
+func TestWalkOrder(t *testing.T) {
+	p := parser.New(lexer.New(`let x = f(1, -y); {"b": 2, "a": 1}`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	var trace []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			trace = append(trace, ")")
+
+			return false
+		}
+		trace = append(trace, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")+"(")
+
+		return true
+	})
+
+	expected := "Root( LetStatement( Identifier( ) CallExpression( Identifier( ) IntegerLiteral( ) " +
+		"PrefixExpression( Identifier( ) ) ) ) ExpressionStatement( HashLiteral( StringLiteral( ) IntegerLiteral( ) " +
+		"StringLiteral( ) IntegerLiteral( ) ) ) )"
+	if got := strings.Join(trace, " "); got != expected {
+		t.Errorf("wrong order.\nwant=%s\ngot= %s", expected, got)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	p := parser.New(lexer.New(`let f = fn(x) { x + 1 }; f(2)`))
+	program := p.ParseProgram()
+
+	var integers []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.FunctionLiteral); ok {
+			return false
+		}
+		if integer, ok := node.(*ast.IntegerLiteral); ok {
+			integers = append(integers, integer.String())
+		}
+
+		return true
+	})
+
+	if !reflect.DeepEqual(integers, []string{"2"}) {
+		t.Errorf("wrong integers found. got=%v", integers)
+	}
+}
+
+// children returns nodes stored in the fields of the node, found by reflection
+// independently of Walk.
+func children(node ast.Node) []ast.Node {
+	nodeType := reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+	var result []ast.Node
+	var collect func(v reflect.Value)
+	collect = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr:
+			if v.IsNil() {
+				return
+			}
+			if v.Type().Implements(nodeType) {
+				result = append(result, v.Interface().(ast.Node))
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				collect(v.Index(i))
+			}
+		case reflect.Map:
+			for _, key := range v.MapKeys() {
+				collect(key)
+				collect(v.MapIndex(key))
+			}
+		}
+	}
+
+	v := reflect.ValueOf(node).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		collect(v.Field(i))
+	}
+
+	return result
+}
@@ -0,0 +1,410 @@
+// Package check implements static checks of Scroopy programs: it resolves
+// identifiers against the bindings visible to them and reports undefined
+// names, unused bindings, shadowed build-in functions and unreachable code.
+//
+// Names are resolved the way the engines resolve them at runtime: blocks
+// don't introduce scopes, functions do, and a function body sees every
+// binding of the enclosing scopes, including the ones defined after
+// the function itself.
+package check
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+	"github.com/dstdfx/scroopy/token"
+)
+
+// Code identifies the kind of the problem a diagnostic reports.
+type Code string
+
+const (
+	Undefined       Code = "undefined"        // identifier not bound anywhere
+	UnusedVariable  Code = "unused-variable"  // `let` binding never read
+	UnusedParameter Code = "unused-parameter" // parameter never read
+	ShadowedBuiltin Code = "shadowed-builtin" // binding hides a build-in function
+	Unreachable     Code = "unreachable"      // statement following `return`, `break` or `continue`
+	Syntax          Code = "syntax"           // the source code can't be parsed
+)
+
+// Severity tells whether a diagnostic reports an error or a suspicious
+// construct that doesn't fail the program by itself.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Diagnostic describes a single problem found in the program.
+type Diagnostic struct {
+	Pos      token.Position // position of the first character of the offending node
+	End      token.Position // position immediately after the offending node
+	Code     Code
+	Severity Severity
+	Msg      string
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Msg, d.Code)
+}
+
+// specialNames are the names the evaluator handles on its own rather than
+// through the build-in functions.
+var specialNames = map[string]bool{
+	"quote":   true,
+	"unquote": true,
+}
+
+type bindingKind int
+
+const (
+	variable bindingKind = iota
+	parameter
+	loopVariable
+)
+
+type binding struct {
+	ident *ast.Identifier // the first identifier the name is bound with in the scope
+	kind  bindingKind
+	used  bool
+}
+
+// scope holds bindings of the program or of a single function.
+type scope struct {
+	outer    *scope
+	bindings map[string]*binding
+	order    []*binding // bindings in the order they're defined
+	info     *Scope
+}
+
+// Scope describes the names bound by the program or by a single function.
+type Scope struct {
+	Node     ast.Node          // *ast.Root, *ast.FunctionLiteral or *ast.MacroLiteral
+	Outer    *Scope            // enclosing scope, nil for the program
+	Bindings []*ast.Identifier // identifiers the names are first bound with, in the source order
+}
+
+// Info describes how names of the program are resolved.
+type Info struct {
+	// Uses maps identifiers referring to bindings, including assignment
+	// targets and repeated definitions, to the identifiers the names
+	// are first bound with. Build-in functions and undefined names are
+	// left out.
+	Uses map[*ast.Identifier]*ast.Identifier
+
+	// Scopes lists the program scope followed by scopes of the functions
+	// in the source order.
+	Scopes []*Scope
+}
+
+// Innermost returns the deepest scope containing the position.
+func (info *Info) Innermost(pos token.Position) *Scope {
+	innermost := info.Scopes[0]
+	for _, s := range info.Scopes[1:] {
+		// Nested scopes follow the enclosing ones, the last one containing
+		// the position is the deepest.
+		if s.Node.Pos().Offset <= pos.Offset && pos.Offset < s.Node.End().Offset {
+			innermost = s
+		}
+	}
+
+	return innermost
+}
+
+func (c *checker) newScope(outer *scope, node ast.Node) *scope {
+	s := &scope{outer: outer, bindings: make(map[string]*binding), info: &Scope{Node: node}}
+	if outer != nil {
+		s.info.Outer = outer.info
+	}
+	c.info.Scopes = append(c.info.Scopes, s.info)
+
+	return s
+}
+
+func (s *scope) lookup(name string) (*binding, bool) {
+	for sc := s; sc != nil; sc = sc.outer {
+		if b, ok := sc.bindings[name]; ok {
+			return b, true
+		}
+	}
+
+	return nil, false
+}
+
+type checker struct {
+	diagnostics []*Diagnostic
+	info        *Info
+}
+
+// Source parses the source code and checks the program. Syntax errors are
+// returned as diagnostics, the program isn't checked if there are any.
+func Source(filename string, src []byte, predeclared ...string) []*Diagnostic {
+	p := parser.New(lexer.NewWithFilename(filename, string(src)))
+	root := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		diagnostics := make([]*Diagnostic, 0, len(errs))
+		for _, err := range errs {
+			diagnostics = append(diagnostics, &Diagnostic{
+				Pos:      err.Pos,
+				End:      err.Pos,
+				Code:     Syntax,
+				Severity: Error,
+				Msg:      err.Msg,
+			})
+		}
+
+		return diagnostics
+	}
+
+	return Program(root, predeclared...)
+}
+
+// Program checks the program and returns the diagnostics in the source order.
+// Predeclared names are treated as global bindings defined outside
+// of the program, such as `args` of scripts run from the command line.
+func Program(root *ast.Root, predeclared ...string) []*Diagnostic {
+	c := run(root, predeclared)
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		return c.diagnostics[i].Pos.Offset < c.diagnostics[j].Pos.Offset
+	})
+
+	return c.diagnostics
+}
+
+// Resolve resolves names of the program the same way Program does.
+func Resolve(root *ast.Root, predeclared ...string) *Info {
+	return run(root, predeclared).info
+}
+
+func run(root *ast.Root, predeclared []string) *checker {
+	c := &checker{info: &Info{Uses: make(map[*ast.Identifier]*ast.Identifier)}}
+
+	global := c.newScope(nil, root)
+	for _, name := range predeclared {
+		// Predeclared bindings are never reported as unused.
+		global.bindings[name] = &binding{ident: &ast.Identifier{Value: name}, kind: variable, used: true}
+	}
+
+	c.declare(global, root)
+	c.resolve(global, root)
+	c.unreachable(root.Statements)
+	c.reportUnused(global)
+
+	return c
+}
+
+func (c *checker) report(node ast.Node, code Code, severity Severity, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, &Diagnostic{
+		Pos:      node.Pos(),
+		End:      node.End(),
+		Code:     code,
+		Severity: severity,
+		Msg:      fmt.Sprintf(format, a...),
+	})
+}
+
+// define binds the identifier in the scope, names bound more than once
+// in the same scope share the binding.
+func (c *checker) define(s *scope, ident *ast.Identifier, kind bindingKind) {
+	if object.GetBuildInByName(ident.Value) != nil {
+		c.report(ident, ShadowedBuiltin, Warning, "%s shadows the build-in function", ident.Value)
+	}
+
+	if b, ok := s.bindings[ident.Value]; ok {
+		c.refer(ident, b)
+
+		return
+	}
+
+	b := &binding{ident: ident, kind: kind}
+	s.bindings[ident.Value] = b
+	s.order = append(s.order, b)
+	s.info.Bindings = append(s.info.Bindings, ident)
+}
+
+// declare defines the bindings the node introduces to the scope, nested
+// functions are skipped since they have scopes of their own.
+func (c *checker) declare(s *scope, node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.CallExpression:
+			return !isCallOf(n, "quote")
+		case *ast.LetStatement:
+			c.define(s, n.Name, variable)
+		case *ast.ForStatement:
+			if n.Key != nil {
+				c.define(s, n.Key, loopVariable)
+			}
+			c.define(s, n.Value, loopVariable)
+		}
+
+		return true
+	})
+}
+
+// resolve looks up the identifiers the node reads in the scope.
+func (c *checker) resolve(s *scope, node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			c.use(s, n)
+		case *ast.LetStatement:
+			c.resolve(s, n.Value)
+
+			return false
+		case *ast.ForStatement:
+			c.resolve(s, n.Iterable)
+			c.resolve(s, n.Body)
+
+			return false
+		case *ast.AssignStatement:
+			// Plain assignments don't read the variable, but it has to exist.
+			if ident, ok := n.Target.(*ast.Identifier); ok && n.Operator == "" {
+				if b, ok := s.lookup(ident.Value); ok {
+					c.refer(ident, b)
+				} else {
+					c.report(ident, Undefined, Error, "undefined: %s", ident.Value)
+				}
+			} else {
+				c.resolve(s, n.Target)
+			}
+			c.resolve(s, n.Value)
+
+			return false
+		case *ast.BlockStatement:
+			c.unreachable(n.Statements)
+		case *ast.FunctionLiteral:
+			c.function(s, n, n.Parameters, n.Defaults, n.Rest, n.Body)
+
+			return false
+		case *ast.MacroLiteral:
+			c.function(s, n, n.Parameters, nil, nil, n.Body)
+
+			return false
+		case *ast.CallExpression:
+			if isCallOf(n, "quote") {
+				c.resolveUnquoted(s, n)
+
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// function checks the function in a scope of its own. Default values are
+// evaluated in the scope of the function, they see the preceding parameters.
+func (c *checker) function(
+	outer *scope, node ast.Node,
+	params []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement,
+) {
+	s := c.newScope(outer, node)
+	for i, param := range params {
+		if defaults != nil && defaults[i] != nil {
+			c.resolve(s, defaults[i])
+		}
+		c.define(s, param, parameter)
+	}
+	if rest != nil {
+		c.define(s, rest, parameter)
+	}
+
+	c.declare(s, body)
+	c.resolve(s, body)
+	c.reportUnused(s)
+}
+
+// resolveUnquoted resolves arguments of the `unquote` calls inside
+// of the quoted code, the rest of it isn't evaluated.
+func (c *checker) resolveUnquoted(s *scope, quote *ast.CallExpression) {
+	for _, arg := range quote.Arguments {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpression); ok && isCallOf(call, "unquote") {
+				for _, unquoted := range call.Arguments {
+					c.resolve(s, unquoted)
+				}
+
+				return false
+			}
+
+			return true
+		})
+	}
+}
+
+func (c *checker) use(s *scope, ident *ast.Identifier) {
+	if b, ok := s.lookup(ident.Value); ok {
+		b.used = true
+		c.refer(ident, b)
+
+		return
+	}
+
+	if object.GetBuildInByName(ident.Value) != nil || specialNames[ident.Value] {
+		return
+	}
+
+	c.report(ident, Undefined, Error, "undefined: %s", ident.Value)
+}
+
+// refer records the identifier refers to the binding, bindings of the
+// predeclared names aren't recorded since they have no source position.
+func (c *checker) refer(ident *ast.Identifier, b *binding) {
+	if b.ident.Token.Pos.IsValid() {
+		c.info.Uses[ident] = b.ident
+	}
+}
+
+// unreachable reports the first statement following `return`, `break`
+// or `continue` in the list.
+func (c *checker) unreachable(stmts []ast.Statement) {
+	for i, stmt := range stmts[:max(len(stmts)-1, 0)] {
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.BranchStatement:
+			c.report(stmts[i+1], Unreachable, Warning, "unreachable statement")
+
+			return
+		}
+	}
+}
+
+func (c *checker) reportUnused(s *scope) {
+	for _, b := range s.order {
+		if b.used || strings.HasPrefix(b.ident.Value, "_") {
+			continue
+		}
+
+		switch b.kind {
+		case variable:
+			c.report(b.ident, UnusedVariable, Warning, "%s is defined but never used", b.ident.Value)
+		case parameter:
+			c.report(b.ident, UnusedParameter, Warning, "parameter %s is never used", b.ident.Value)
+		case loopVariable:
+			// Loops often bind variables only to repeat the body.
+		}
+	}
+}
+
+func isCallOf(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+
+	return ok && ident.Value == name
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
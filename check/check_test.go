@@ -0,0 +1,212 @@
+package check_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/check"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/parser"
+	"github.com/dstdfx/scroopy/token"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "clean program",
+			input:    "let add = fn(x, y) { x + y }; print(add(1, 2));",
+			expected: nil,
+		},
+		{
+			name:     "undefined name",
+			input:    "let x = 1; print(x + y);",
+			expected: []string{"1:22: undefined: y (undefined)"},
+		},
+		{
+			name:     "assignment to undefined name",
+			input:    "x = 1;",
+			expected: []string{"1:1: undefined: x (undefined)"},
+		},
+		{
+			name:     "unused let binding",
+			input:    "let f = fn() { let x = 1; 2 }; f();",
+			expected: []string{"1:20: x is defined but never used (unused-variable)"},
+		},
+		{
+			name:     "assignment doesn't use the binding",
+			input:    "let x = 1; x = 2;",
+			expected: []string{"1:5: x is defined but never used (unused-variable)"},
+		},
+		{
+			name:     "compound assignment uses the binding",
+			input:    "let x = 1; x += 2;",
+			expected: nil,
+		},
+		{
+			name:  "unused parameters",
+			input: "let f = fn(a, b = 1, ...others) { 0 }; f(1);",
+			expected: []string{
+				"1:12: parameter a is never used (unused-parameter)",
+				"1:15: parameter b is never used (unused-parameter)",
+				"1:25: parameter others is never used (unused-parameter)",
+			},
+		},
+		{
+			name:     "underscore names aren't reported",
+			input:    "let _f = fn(_a) { 0 };",
+			expected: nil,
+		},
+		{
+			name:     "defaults see preceding parameters",
+			input:    "let f = fn(a, b = a + c) { b }; f(1);",
+			expected: []string{"1:23: undefined: c (undefined)"},
+		},
+		{
+			name:  "shadowed builtins",
+			input: "let len = fn(print) { print }; for (first in [len]) { first(1) }",
+			expected: []string{
+				"1:5: len shadows the build-in function (shadowed-builtin)",
+				"1:14: print shadows the build-in function (shadowed-builtin)",
+				"1:37: first shadows the build-in function (shadowed-builtin)",
+			},
+		},
+		{
+			name:     "functions see bindings defined after them",
+			input:    "let f = fn() { g() }; let g = fn() { f() }; f();",
+			expected: nil,
+		},
+		{
+			name:     "recursion",
+			input:    "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5);",
+			expected: nil,
+		},
+		{
+			name:     "blocks don't introduce scopes",
+			input:    "if (true) { let x = 1; } print(x);",
+			expected: nil,
+		},
+		{
+			name:     "function bindings aren't visible outside",
+			input:    "let f = fn() { let x = 1; x }; f(); print(x);",
+			expected: []string{"1:43: undefined: x (undefined)"},
+		},
+		{
+			name:     "loop variables",
+			input:    "for (i, v in [1]) { print(v) } print(i, k);",
+			expected: []string{"1:41: undefined: k (undefined)"},
+		},
+		{
+			name:  "unreachable statements",
+			input: "let f = fn() { return 1; print(2); print(3); }; while (true) { break; print(1); }; f();",
+			expected: []string{
+				"1:26: unreachable statement (unreachable)",
+				"1:71: unreachable statement (unreachable)",
+			},
+		},
+		{
+			name:     "quoted code isn't resolved",
+			input:    "let m = macro(a) { quote(unquote(a) + b + unquote(c)) }; m(1);",
+			expected: []string{"1:51: undefined: c (undefined)"},
+		},
+		{
+			name:  "syntax errors",
+			input: "let x 1;",
+			expected: []string{
+				"1:7: expected next token to be '=', got 'INT' instead (syntax)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := check.Source("", []byte(tt.input))
+			if len(diagnostics) != len(tt.expected) {
+				t.Fatalf("wrong number of diagnostics. expected=%d, got=%d (%v)",
+					len(tt.expected), len(diagnostics), diagnostics)
+			}
+
+			for i, d := range diagnostics {
+				if d.String() != tt.expected[i] {
+					t.Errorf("wrong diagnostic %d. expected=%q, got=%q", i, tt.expected[i], d.String())
+				}
+			}
+		})
+	}
+}
+
+func TestProgram_Predeclared(t *testing.T) {
+	diagnostics := check.Source("", []byte("print(args);"), "args")
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got=%v", diagnostics)
+	}
+}
+
+func TestDiagnostic_Severity(t *testing.T) {
+	diagnostics := check.Source("", []byte("let x = y;"))
+	if len(diagnostics) != 2 {
+		t.Fatalf("wrong number of diagnostics. expected=2, got=%v", diagnostics)
+	}
+
+	if diagnostics[0].Severity != check.Warning || diagnostics[0].Code != check.UnusedVariable {
+		t.Errorf("wrong first diagnostic. got=%s %s", diagnostics[0].Severity, diagnostics[0].Code)
+	}
+
+	if diagnostics[1].Severity != check.Error || diagnostics[1].Code != check.Undefined {
+		t.Errorf("wrong second diagnostic. got=%s %s", diagnostics[1].Severity, diagnostics[1].Code)
+	}
+
+	if diagnostics[1].End.Column != 10 {
+		t.Errorf("wrong end column. expected=10, got=%d", diagnostics[1].End.Column)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p := parser.New(lexer.New("let f = fn(x) { let y = x; y }; let y = f(1); f(y); y = 2;"))
+	root := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	info := check.Resolve(root)
+
+	uses := make([]string, 0, len(info.Uses))
+	for ident, def := range info.Uses {
+		uses = append(uses, fmt.Sprintf("%s@%d->%d", ident.Value, ident.Pos().Offset, def.Pos().Offset))
+	}
+	sort.Strings(uses)
+
+	expected := []string{"f@40->4", "f@46->4", "x@24->11", "y@27->20", "y@48->36", "y@52->36"}
+	if strings.Join(uses, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong uses. expected=%v, got=%v", expected, uses)
+	}
+
+	if len(info.Scopes) != 2 {
+		t.Fatalf("wrong number of scopes. expected=2, got=%d", len(info.Scopes))
+	}
+	if info.Scopes[1].Outer != info.Scopes[0] || info.Scopes[0].Node != root {
+		t.Errorf("wrong scope nesting")
+	}
+
+	// Offset 25 is inside of the function body.
+	if scope := info.Innermost(token.Position{Offset: 25}); scope != info.Scopes[1] {
+		t.Errorf("wrong innermost scope inside of the function")
+	}
+	if scope := info.Innermost(token.Position{Offset: 45}); scope != info.Scopes[0] {
+		t.Errorf("wrong innermost scope outside of the function")
+	}
+
+	names := make([]string, 0)
+	for _, ident := range info.Scopes[1].Bindings {
+		names = append(names, ident.Value)
+	}
+	if strings.Join(names, " ") != "x y" {
+		t.Errorf("wrong function bindings. got=%v", names)
+	}
+}
@@ -1,7 +0,0 @@
-package main
-
-import "github.com/dstdfx/scroopy/cmd/repl/app"
-
-func main() {
-	app.Run()
-}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dstdfx/scroopy/check"
+)
+
+// jsonDiagnostic is the machine-readable form of check.Diagnostic.
+type jsonDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// runCheck statically checks the given source files and prints the problems
+// found, as a JSON array if -json is set. The exit code is ExitError if there
+// are any problems.
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, "Usage: scroopy check [-json] <files...>\n")
+	}
+	asJSON := flags.Bool("json", false, "print the problems as a JSON array")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+
+		return ExitUsage
+	}
+
+	code := ExitOK
+	diagnostics := make([]*check.Diagnostic, 0)
+	for _, filename := range flags.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+			code = ExitError
+
+			continue
+		}
+
+		// Scripts are run with their arguments bound to `args`.
+		diagnostics = append(diagnostics, check.Source(filename, src, scriptArgsName)...)
+	}
+
+	if len(diagnostics) > 0 {
+		code = ExitError
+	}
+
+	if !*asJSON {
+		for _, d := range diagnostics {
+			_, _ = fmt.Fprintln(stdout, d)
+		}
+
+		return code
+	}
+
+	out := make([]jsonDiagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		out = append(out, jsonDiagnostic{
+			File:      d.Pos.Filename,
+			Line:      d.Pos.Line,
+			Column:    d.Pos.Column,
+			EndLine:   d.End.Line,
+			EndColumn: d.End.Column,
+			Severity:  string(d.Severity),
+			Code:      string(d.Code),
+			Message:   d.Msg,
+		})
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	return code
+}
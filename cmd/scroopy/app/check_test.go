@@ -0,0 +1,75 @@
+package app_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/cmd/scroopy/app"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.scr")
+	if err := os.WriteFile(clean, []byte("print(len(args));\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.scr")
+	if err := os.WriteFile(broken, []byte("let x = 1;\nprint(y);\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if code := app.Run([]string{"check", clean}, strings.NewReader(""), stdout, stderr); code != app.ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stdout: %q)", app.ExitOK, code, stdout.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected stdout. got=%q", stdout.String())
+	}
+
+	if code := app.Run([]string{"check", clean, broken}, strings.NewReader(""), stdout, stderr); code != app.ExitError {
+		t.Fatalf("wrong exit code. expected=%d, got=%d", app.ExitError, code)
+	}
+	expected := broken + ":1:5: x is defined but never used (unused-variable)\n" +
+		broken + ":2:7: undefined: y (undefined)\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong stdout. expected=%q, got=%q", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := app.Run([]string{"check", "-json", broken}, strings.NewReader(""), stdout, stderr); code != app.ExitError {
+		t.Fatalf("wrong exit code. expected=%d, got=%d", app.ExitError, code)
+	}
+
+	var diagnostics []map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &diagnostics); err != nil {
+		t.Fatalf("output is not valid JSON: %s (%q)", err, stdout.String())
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("wrong number of diagnostics. expected=2, got=%d", len(diagnostics))
+	}
+
+	expectedLast := map[string]interface{}{
+		"file":      broken,
+		"line":      float64(2),
+		"column":    float64(7),
+		"endLine":   float64(2),
+		"endColumn": float64(8),
+		"severity":  "error",
+		"code":      "undefined",
+		"message":   "undefined: y",
+	}
+	for key, value := range expectedLast {
+		if diagnostics[1][key] != value {
+			t.Errorf("wrong %s. expected=%v, got=%v", key, value, diagnostics[1][key])
+		}
+	}
+
+	if code := app.Run([]string{"check"}, strings.NewReader(""), stdout, stderr); code != app.ExitUsage {
+		t.Errorf("wrong exit code without files. expected=%d, got=%d", app.ExitUsage, code)
+	}
+}
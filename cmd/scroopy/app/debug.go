@@ -0,0 +1,260 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dstdfx/scroopy/debug"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+const debugHelp = `Commands:
+  break <line>, b    set a breakpoint at the line
+  clear <line>       remove the breakpoint at the line
+  breakpoints        list breakpoints
+  continue, c        run until the next breakpoint
+  step, s            step to the next statement, into function calls
+  next, n            step to the next statement, over function calls
+  out, o             step out of the current function
+  stack, bt          print the call stack
+  env, e             print variables of the current environment chain
+  print <expr>, p    evaluate the expression in the current environment
+  list, l            print the source around the current line
+  quit, q            terminate the program
+`
+
+// runDebug runs the source file under the interactive debugger, the program
+// is paused before the first statement. With -dap the Debug Adapter Protocol
+// is served over stdin and stdout instead, the program is given by the client.
+func runDebug(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, "Usage: scroopy debug <file> [args...]\n       scroopy debug -dap\n")
+	}
+	dap := flags.Bool("dap", false, "serve the Debug Adapter Protocol over stdin and stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if *dap {
+		return serveDAP(stdin, stdout, stderr)
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+
+		return ExitUsage
+	}
+
+	filename := flags.Arg(0)
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	p := parser.New(lexer.NewWithFilename(filename, string(src)))
+	root := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		_, _ = fmt.Fprint(stderr, p.Errors().Render(string(src)))
+
+		return ExitError
+	}
+
+	session := &debugSession{
+		src:    strings.Split(string(src), "\n"),
+		input:  bufio.NewScanner(stdin),
+		stdout: stdout,
+	}
+	config := evaluator.Config{Builtins: object.BuiltinsWritingTo(stdout)}
+	session.debugger = debug.NewWithConfig(root, session.paused, config)
+
+	env := object.NewEnvironment()
+	env.Set(scriptArgsName, stringsToArray(flags.Args()[1:]))
+
+	evaluated, err := session.debugger.Run(env, true)
+	if errors.Is(err, debug.ErrTerminated) {
+		_, _ = fmt.Fprintln(stdout, "Program terminated.")
+
+		return ExitError
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		printRuntimeError(stderr, string(src), errObj)
+
+		return ExitError
+	}
+
+	_, _ = fmt.Fprintln(stdout, "Program exited.")
+
+	return ExitOK
+}
+
+func serveDAP(stdin io.Reader, stdout, stderr io.Writer) int {
+	server := debug.NewDAPServer(stdin, stdout, scriptArgsName)
+	if err := server.Serve(); err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+// debugSession reads commands of the interactive debugger while the program is paused.
+type debugSession struct {
+	debugger *debug.Debugger
+	src      []string // source lines
+	input    *bufio.Scanner
+	stdout   io.Writer
+}
+
+func (s *debugSession) paused(stop *debug.Stop) debug.Action {
+	pos := stop.Pos()
+	_, _ = fmt.Fprintf(s.stdout, "Paused at %s (%s)\n", pos, stop.Reason)
+	s.listLines(pos.Line, pos.Line)
+
+	for {
+		_, _ = fmt.Fprint(s.stdout, "(debug) ")
+		if !s.input.Scan() {
+			_, _ = fmt.Fprintln(s.stdout)
+
+			return debug.Terminate
+		}
+
+		command, arg := splitCommand(s.input.Text())
+		switch command {
+		case "":
+		case "break", "b":
+			s.setBreakpoint(arg)
+		case "clear":
+			s.clearBreakpoint(arg)
+		case "breakpoints":
+			for _, line := range s.debugger.Breakpoints() {
+				_, _ = fmt.Fprintf(s.stdout, "line %d\n", line)
+			}
+		case "continue", "c":
+			return debug.Continue
+		case "step", "s":
+			return debug.StepIn
+		case "next", "n":
+			return debug.StepOver
+		case "out", "o":
+			return debug.StepOut
+		case "stack", "bt":
+			for _, frame := range stop.Frames() {
+				_, _ = fmt.Fprintf(s.stdout, "%s at %s\n", frame.Function, frame.Pos)
+			}
+		case "env", "e":
+			s.printEnv(stop)
+		case "print", "p":
+			s.print(stop, arg)
+		case "list", "l":
+			s.listLines(pos.Line-3, pos.Line+3)
+		case "quit", "q":
+			return debug.Terminate
+		case "help", "h":
+			_, _ = fmt.Fprint(s.stdout, debugHelp)
+		default:
+			_, _ = fmt.Fprintf(s.stdout, "unknown command %q, type help to list commands\n", command)
+		}
+	}
+}
+
+func splitCommand(line string) (command, arg string) {
+	line = strings.TrimSpace(line)
+	if space := strings.IndexAny(line, " \t"); space >= 0 {
+		return line[:space], strings.TrimSpace(line[space+1:])
+	}
+
+	return line, ""
+}
+
+func (s *debugSession) setBreakpoint(arg string) {
+	line, err := strconv.Atoi(arg)
+	if err != nil {
+		_, _ = fmt.Fprintf(s.stdout, "invalid line %q\n", arg)
+
+		return
+	}
+
+	lines := append(s.debugger.Breakpoints(), line)
+	actual := s.debugger.SetBreakpoints(lines)
+	if actual[len(actual)-1] == 0 {
+		_, _ = fmt.Fprintf(s.stdout, "no statements at or after line %d\n", line)
+
+		return
+	}
+
+	_, _ = fmt.Fprintf(s.stdout, "Breakpoint set at line %d\n", actual[len(actual)-1])
+}
+
+func (s *debugSession) clearBreakpoint(arg string) {
+	line, err := strconv.Atoi(arg)
+	if err != nil {
+		_, _ = fmt.Fprintf(s.stdout, "invalid line %q\n", arg)
+
+		return
+	}
+
+	lines := make([]int, 0)
+	for _, bp := range s.debugger.Breakpoints() {
+		if bp != line {
+			lines = append(lines, bp)
+		}
+	}
+	s.debugger.SetBreakpoints(lines)
+}
+
+// printEnv prints variables of every environment the paused statement
+// sees, the innermost environment first.
+func (s *debugSession) printEnv(stop *debug.Stop) {
+	scopes := stop.Scopes()
+	for i, env := range scopes {
+		name := "closure"
+		switch {
+		case i == len(scopes)-1:
+			name = "globals"
+		case i == 0:
+			name = "locals"
+		}
+		_, _ = fmt.Fprintf(s.stdout, "%s:\n", name)
+
+		for _, varName := range env.Names() {
+			value, _ := env.Get(varName)
+			_, _ = fmt.Fprintf(s.stdout, "  %s = %s\n", varName, value.Inspect())
+		}
+	}
+}
+
+func (s *debugSession) print(stop *debug.Stop, expression string) {
+	evaluated, err := stop.Eval(expression)
+	if err != nil {
+		_, _ = fmt.Fprintln(s.stdout, err)
+
+		return
+	}
+
+	_, _ = fmt.Fprintln(s.stdout, evaluated.Inspect())
+}
+
+// listLines prints the source lines in the range, both ends are included.
+func (s *debugSession) listLines(from, to int) {
+	for line := from; line <= to; line++ {
+		if line < 1 || line > len(s.src) {
+			continue
+		}
+		_, _ = fmt.Fprintf(s.stdout, "%4d  %s\n", line, s.src[line-1])
+	}
+}
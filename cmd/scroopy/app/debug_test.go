@@ -0,0 +1,90 @@
+package app_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/cmd/scroopy/app"
+)
+
+func TestDebug(t *testing.T) {
+	const src = "let add = fn(a, b) {\n  let sum = a + b;\n  sum\n};\nlet x = add(1, 2);\nprint(x)\n"
+
+	filename := filepath.Join(t.TempDir(), "script.scr")
+	if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		commands     string
+		expectedCode int
+		expected     string
+	}{
+		{
+			name:         "breakpoint and inspection",
+			commands:     "b 2\nc\nbt\ne\np a + b\np print(a)\nc\n",
+			expectedCode: app.ExitOK,
+			expected: "Paused at %s:1:1 (entry)\n" +
+				"   1  let add = fn(a, b) {\n" +
+				"(debug) Breakpoint set at line 2\n" +
+				"(debug) Paused at %s:2:3 (breakpoint)\n" +
+				"   2    let sum = a + b;\n" +
+				"(debug) add at %s:2:3\n" +
+				"main at %s:5:9\n" +
+				"(debug) locals:\n" +
+				"  a = 1\n" +
+				"  b = 2\n" +
+				"globals:\n" +
+				"  add = fn(a, b) {\nlet sum = (a + b);sum\n}\n" +
+				"  args = []\n" +
+				"(debug) 3\n" +
+				"(debug) 1\nnull\n" +
+				"(debug) 3\nProgram exited.\n",
+		},
+		{
+			name:         "stepping",
+			commands:     "n\ns\ns\no\n\nc\n",
+			expectedCode: app.ExitOK,
+			expected: "Paused at %s:1:1 (entry)\n" +
+				"   1  let add = fn(a, b) {\n" +
+				"(debug) Paused at %s:5:1 (step)\n" +
+				"   5  let x = add(1, 2);\n" +
+				"(debug) Paused at %s:2:3 (step)\n" +
+				"   2    let sum = a + b;\n" +
+				"(debug) Paused at %s:3:3 (step)\n" +
+				"   3    sum\n" +
+				"(debug) Paused at %s:6:1 (step)\n" +
+				"   6  print(x)\n" +
+				"(debug) (debug) 3\nProgram exited.\n",
+		},
+		{
+			name:         "quit",
+			commands:     "q\n",
+			expectedCode: app.ExitError,
+			expected: "Paused at %s:1:1 (entry)\n" +
+				"   1  let add = fn(a, b) {\n" +
+				"(debug) Program terminated.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			code := app.Run([]string{"debug", filename}, strings.NewReader(tt.commands), stdout, stderr)
+			if code != tt.expectedCode {
+				t.Errorf("wrong exit code. expected=%d, got=%d (stderr: %q)", tt.expectedCode, code, stderr.String())
+			}
+
+			expected := strings.ReplaceAll(tt.expected, "%s", filename)
+			if stdout.String() != expected {
+				t.Errorf("wrong stdout. expected=%q, got=%q", expected, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dstdfx/scroopy/format"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+// runFmt formats the given source files, the formatted source is printed
+// to stdout unless -w is set, in which case files are rewritten in place.
+// The standard input is formatted if no files are given.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, "Usage: scroopy fmt [-w] [files...]\n")
+	}
+	write := flags.Bool("w", false, "write the result to the source files instead of stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			_, _ = fmt.Fprint(stderr, "scroopy: cannot use -w with standard input\n")
+
+			return ExitUsage
+		}
+
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+			return ExitError
+		}
+
+		return formatSource("<stdin>", src, stdout, stderr)
+	}
+
+	code := ExitOK
+	for _, filename := range flags.Args() {
+		if fileCode := formatFile(filename, *write, stdout, stderr); fileCode != ExitOK {
+			code = fileCode
+		}
+	}
+
+	return code
+}
+
+func formatFile(filename string, write bool, stdout, stderr io.Writer) int {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	if !write {
+		return formatSource(filename, src, stdout, stderr)
+	}
+
+	formatted := &bytes.Buffer{}
+	if code := formatSource(filename, src, formatted, stderr); code != ExitOK {
+		return code
+	}
+
+	// Files already formatted are left untouched.
+	if bytes.Equal(src, formatted.Bytes()) {
+		return ExitOK
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	if err := os.WriteFile(filename, formatted.Bytes(), info.Mode().Perm()); err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+func formatSource(filename string, src []byte, out, stderr io.Writer) int {
+	formatted, err := format.Source(filename, src)
+	if err != nil {
+		var errList parser.ErrorList
+		if errors.As(err, &errList) {
+			_, _ = fmt.Fprint(stderr, errList.Render(string(src)))
+		} else {
+			_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+		}
+
+		return ExitError
+	}
+
+	if _, err := out.Write(formatted); err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	return ExitOK
+}
@@ -0,0 +1,83 @@
+package app_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/cmd/scroopy/app"
+)
+
+func TestFmt(t *testing.T) {
+	const (
+		src       = "let add=fn(x,y){x+y}\nadd(1,2)\n"
+		formatted = "let add = fn(x, y) {\n  x + y;\n};\nadd(1, 2);\n"
+	)
+
+	filename := filepath.Join(t.TempDir(), "script.scr")
+	if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if code := app.Run([]string{"fmt", filename}, strings.NewReader(""), stdout, stderr); code != app.ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stderr: %q)", app.ExitOK, code, stderr.String())
+	}
+	if stdout.String() != formatted {
+		t.Errorf("wrong stdout. expected=%q, got=%q", formatted, stdout.String())
+	}
+
+	// The file is left untouched without -w.
+	if content, _ := os.ReadFile(filename); string(content) != src {
+		t.Errorf("file is modified without -w. got=%q", content)
+	}
+
+	stdout.Reset()
+	if code := app.Run([]string{"fmt", "-w", filename}, strings.NewReader(""), stdout, stderr); code != app.ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stderr: %q)", app.ExitOK, code, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected stdout with -w. got=%q", stdout.String())
+	}
+	if content, _ := os.ReadFile(filename); string(content) != formatted {
+		t.Errorf("file is not formatted. got=%q", content)
+	}
+
+	stdout.Reset()
+	if code := app.Run([]string{"fmt"}, strings.NewReader(src), stdout, stderr); code != app.ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stderr: %q)", app.ExitOK, code, stderr.String())
+	}
+	if stdout.String() != formatted {
+		t.Errorf("wrong stdout for standard input. expected=%q, got=%q", formatted, stdout.String())
+	}
+}
+
+func TestFmt_Errors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "broken.scr")
+	if err := os.WriteFile(filename, []byte("let x 1;\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"fmt", "-w", filename}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError {
+		t.Errorf("wrong exit code. expected=%d, got=%d", app.ExitError, code)
+	}
+
+	expected := filename + ":1:7: expected next token to be '=', got 'INT' instead\nlet x 1;\n      ^\n"
+	if stderr.String() != expected {
+		t.Errorf("wrong stderr. expected=%q, got=%q", expected, stderr.String())
+	}
+
+	if content, _ := os.ReadFile(filename); string(content) != "let x 1;\n" {
+		t.Errorf("broken file is modified. got=%q", content)
+	}
+
+	code = app.Run([]string{"fmt", "-w"}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	if code != app.ExitUsage {
+		t.Errorf("wrong exit code for -w without files. expected=%d, got=%d", app.ExitUsage, code)
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/dstdfx/scroopy/lsp"
+)
+
+// runLSP serves the Language Server Protocol over stdin and stdout
+// until the editor asks the server to exit.
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, "Usage: scroopy lsp\n")
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+
+		return ExitUsage
+	}
+
+	// Scripts are run with their arguments bound to `args`.
+	if err := lsp.NewServer(stdin, stdout, scriptArgsName).Serve(); err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	return ExitOK
+}
@@ -0,0 +1,37 @@
+package app_test
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/cmd/scroopy/app"
+)
+
+func TestLSP(t *testing.T) {
+	frame := func(content string) string {
+		return "Content-Length: " + strconv.Itoa(len(content)) + "\r\n\r\n" + content
+	}
+	stdin := strings.NewReader(
+		frame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`) +
+			frame(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`) +
+			frame(`{"jsonrpc":"2.0","method":"exit"}`),
+	)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if code := app.Run([]string{"lsp"}, stdin, stdout, stderr); code != app.ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stderr: %q)", app.ExitOK, code, stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), `"capabilities"`) ||
+		!strings.Contains(stdout.String(), `{"jsonrpc":"2.0","id":2,"result":null}`) {
+		t.Errorf("unexpected stdout. got=%q", stdout.String())
+	}
+
+	code := app.Run([]string{"lsp"}, strings.NewReader(frame(`{"jsonrpc":"2.0","method":"exit"}`)), stdout, stderr)
+	if code != app.ExitError {
+		t.Errorf("wrong exit code for exit without shutdown. expected=%d, got=%d", app.ExitError, code)
+	}
+}
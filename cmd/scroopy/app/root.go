@@ -0,0 +1,117 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os/user"
+	"runtime"
+
+	"github.com/dstdfx/scroopy/engine"
+	"github.com/dstdfx/scroopy/repl"
+)
+
+// Variables that are injected in build time.
+//nolint
+var (
+	buildGitCommit string
+	buildGitTag    string
+	buildDate      string
+	buildCompiler  = runtime.Version()
+)
+
+// TODO: add build-in function that prints build info
+
+const scroopyASCIIName = `
+  ______ ___________  ____   ____ ______ ___.__.
+ /  ___// ___\_  __ \/  _ \ /  _ \\____ <   |  |
+ \___ \\  \___|  | \(  <_> |  <_> )  |_> >___  |
+/____  >\___  >__|   \____/ \____/|   __// ____|
+     \/     \/                    |__|   \/
+`
+
+const usage = `Usage:
+
+	scroopy [repl] [-engine name]               start the interactive shell
+	scroopy run [-engine name] <file> [args...] run the given Scroopy source file
+	scroopy fmt [-w] [files...]                 format source files, or the standard input
+	scroopy check [-json] <files...>            report undefined names, unused bindings and unreachable code
+	scroopy lsp                                 serve the Language Server Protocol over stdin and stdout
+	scroopy debug <file> [args...]              run the given Scroopy source file under the debugger
+	scroopy debug -dap                          serve the Debug Adapter Protocol over stdin and stdout
+	scroopy help                                print this help
+
+Flags:
+
+	-engine name    engine executing programs: "eval" (default) walks the AST,
+	                "vm" compiles programs to bytecode and runs them on the VM
+	-w              write formatted sources back to the files instead of stdout
+	-json           print problems found by check as a JSON array
+`
+
+// Exit codes returned by Run.
+const (
+	ExitOK    = 0 // the command succeeded
+	ExitError = 1 // the program failed to parse or evaluate
+	ExitUsage = 2 // the command line is invalid
+)
+
+// Run executes the command described by the given command line arguments
+// (without the program name) and returns the process exit code.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return runREPL(args, stdin, stdout, stderr)
+	}
+
+	switch args[0] {
+	case "repl":
+		return runREPL(args[1:], stdin, stdout, stderr)
+	case "run":
+		return runFile(args[1:], stdout, stderr)
+	case "fmt":
+		return runFmt(args[1:], stdin, stdout, stderr)
+	case "check":
+		return runCheck(args[1:], stdout, stderr)
+	case "lsp":
+		return runLSP(args[1:], stdin, stdout, stderr)
+	case "debug":
+		return runDebug(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		_, _ = fmt.Fprint(stdout, usage)
+
+		return ExitOK
+	default:
+		_, _ = fmt.Fprintf(stderr, "scroopy: unknown command %q\n\n%s", args[0], usage)
+
+		return ExitUsage
+	}
+}
+
+func runREPL(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engineName := flags.String("engine", engine.EvaluatorName, "engine executing programs")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	eng, err := engine.New(*engineName, stdout)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitUsage
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+	_, _ = fmt.Fprint(stdout, scroopyASCIIName+"\n")
+	_, _ = fmt.Fprintf(stdout, "Hello %s! This is the Scroopy programming language!\n", currentUser.Username)
+	_, _ = fmt.Fprintf(stdout, "Feel free to type in commands\n")
+	repl.StartWithEngine(stdin, stdout, eng)
+
+	return ExitOK
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dstdfx/scroopy/engine"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+// scriptArgsName is the name of the global binding that holds
+// command line arguments passed to the script.
+const scriptArgsName = "args"
+
+// runFile lexes, parses and evaluates a whole source file.
+// The arguments following the file name are exposed to the program
+// as an array of strings bound to `args`.
+func runFile(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, "Usage: scroopy run [-engine name] <file> [args...]\n")
+	}
+	engineName := flags.String("engine", engine.EvaluatorName, "engine executing programs")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+
+		return ExitUsage
+	}
+
+	eng, err := engine.New(*engineName, stdout)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitUsage
+	}
+
+	filename := flags.Arg(0)
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scroopy: %s\n", err)
+
+		return ExitError
+	}
+
+	p := parser.New(lexer.NewWithFilename(filename, string(src)))
+	root := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		_, _ = fmt.Fprint(stderr, p.Errors().Render(string(src)))
+
+		return ExitError
+	}
+
+	eng.Define(scriptArgsName, stringsToArray(flags.Args()[1:]))
+
+	evaluated := eng.Run(root)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		printRuntimeError(stderr, string(src), errObj)
+
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+// printRuntimeError prints the error with the source line it refers to
+// followed by the call stack.
+func printRuntimeError(out io.Writer, src string, errObj *object.Error) {
+	_, _ = fmt.Fprintf(out, "%s: runtime error: %s\n", errObj.Pos, errObj.Message)
+	if excerpt := errObj.Pos.Excerpt(src); excerpt != "" {
+		_, _ = fmt.Fprintln(out, excerpt)
+	}
+	_, _ = fmt.Fprint(out, errObj.Traceback())
+}
+
+func stringsToArray(values []string) *object.Array {
+	elements := make([]object.Object, 0, len(values))
+	for _, v := range values {
+		elements = append(elements, &object.String{Value: v})
+	}
+
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,137 @@
+package app_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/cmd/scroopy/app"
+	"github.com/dstdfx/scroopy/evaluator"
+)
+
+func TestRun_File(t *testing.T) {
+	tests := []struct {
+		name             string
+		src              string
+		args             []string
+		expectedCode     int
+		expectedStdout   string
+		expectedStderr   string
+		expectedContains bool
+	}{
+		{
+			name:           "ok",
+			src:            "let add = fn(x, y) { x + y };\nprint(add(1, 2));\n",
+			expectedCode:   app.ExitOK,
+			expectedStdout: "3\n",
+		},
+		{
+			name:           "script args",
+			src:            "print(args);\n",
+			args:           []string{"input.txt", "-v"},
+			expectedCode:   app.ExitOK,
+			expectedStdout: "[\"input.txt\", \"-v\"]\n",
+		},
+		{
+			name:         "parse error",
+			src:          "let x = 1;\nlet y 2;\n",
+			expectedCode: app.ExitError,
+			expectedStderr: "%s:2:7: expected next token to be '=', got 'INT' instead\n" +
+				"let y 2;\n" +
+				"      ^\n",
+		},
+		{
+			name:         "runtime error",
+			src:          "let f = fn(x) {\n  x + true\n};\nf(1);\n",
+			expectedCode: app.ExitError,
+			expectedStderr: "%s:2:3: runtime error: type mismatch: INTEGER + BOOLEAN\n" +
+				"  x + true\n" +
+				"  ^\n" +
+				"\tat f (called at %s:4:1)\n",
+		},
+		{
+			// The VM reports it while compiling, at the same position.
+			name:         "macro literal",
+			src:          "let x = 1;\nprint(macro(y) { y });\n",
+			expectedCode: app.ExitError,
+			expectedStderr: "%s:2:7: runtime error: macros can only be defined with top-level let statements\n" +
+				"print(macro(y) { y });\n" +
+				"      ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engineName := range []string{"eval", "vm"} {
+			tt := tt
+			t.Run(tt.name+"/"+engineName, func(t *testing.T) {
+				filename := filepath.Join(t.TempDir(), "script.scr")
+				if err := os.WriteFile(filename, []byte(tt.src), 0o600); err != nil {
+					t.Fatal(err)
+				}
+
+				stdout := &bytes.Buffer{}
+				stderr := &bytes.Buffer{}
+				args := append([]string{"run", "-engine", engineName, filename}, tt.args...)
+				code := app.Run(args, strings.NewReader(""), stdout, stderr)
+				if code != tt.expectedCode {
+					t.Errorf("wrong exit code. expected=%d, got=%d (stderr: %q)", tt.expectedCode, code, stderr.String())
+				}
+
+				if stdout.String() != tt.expectedStdout {
+					t.Errorf("wrong stdout. expected=%q, got=%q", tt.expectedStdout, stdout.String())
+				}
+
+				expectedStderr := strings.ReplaceAll(tt.expectedStderr, "%s", filename)
+				if stderr.String() != expectedStderr {
+					t.Errorf("wrong stderr. expected=%q, got=%q", expectedStderr, stderr.String())
+				}
+			})
+		}
+	}
+}
+
+func TestRun_Recursion(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "script.scr")
+	if err := os.WriteFile(filename, []byte("let f = fn(n) { f(n + 1) };\nf(0);\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"run", filename}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError {
+		t.Errorf("wrong exit code. expected=%d, got=%d (stderr: %q)", app.ExitError, code, stderr.String())
+	}
+
+	expectedStderr := strings.ReplaceAll("%s:1:17: runtime error: stack depth exceeded\n"+
+		"let f = fn(n) { f(n + 1) };\n"+
+		"                ^\n"+
+		"\tat f (called at %s:1:17)\n"+
+		fmt.Sprintf("\t... repeated %d more times\n", evaluator.DefaultMaxDepth-2)+
+		"\tat f (called at %s:2:1)\n", "%s", filename)
+	if stderr.String() != expectedStderr {
+		t.Errorf("wrong stderr. expected=%q, got=%q", expectedStderr, stderr.String())
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := []struct {
+		args         []string
+		expectedCode int
+	}{
+		{args: []string{"run"}, expectedCode: app.ExitUsage},
+		{args: []string{"unknown"}, expectedCode: app.ExitUsage},
+		{args: []string{"help"}, expectedCode: app.ExitOK},
+		{args: []string{"run", "-engine", "unknown", "script.scr"}, expectedCode: app.ExitUsage},
+		{args: []string{"run", filepath.Join(os.TempDir(), "does-not-exist.scr")}, expectedCode: app.ExitError},
+	}
+
+	for _, tt := range tests {
+		code := app.Run(tt.args, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d", tt.args, tt.expectedCode, code)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/dstdfx/scroopy/cmd/scroopy/app"
+)
+
+func main() {
+	os.Exit(app.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,307 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dstdfx/scroopy/token"
+)
+
+// Instructions represents a sequence of bytecode instructions.
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			_, _ = fmt.Fprintf(&out, "ERROR: %s\n", err)
+
+			return out.String()
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		_, _ = fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+// Opcode represents an operation the virtual machine performs.
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	// Infix operators. The right operand is evaluated first, so it lies
+	// under the left operand on the stack.
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+
+	// Prefix operators.
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJumpNotTruthy
+	OpJump
+	// OpJumpTruthyOrPop and OpJumpNotTruthyOrPop jump keeping the value on
+	// top of the stack as the result of `||` and `&&`, and pop it otherwise.
+	OpJumpTruthyOrPop
+	OpJumpNotTruthyOrPop
+	// OpJumpBound jumps to the first operand if the parameter whose local
+	// index is the second operand got an argument, skipping its default value.
+	OpJumpBound
+
+	OpGetGlobal
+	OpSetGlobal
+	// OpAssignGlobal is OpSetGlobal failing if the variable is not defined yet.
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpGetFree
+	OpSetFree
+
+	OpArray
+	OpHash
+	OpIndex
+	// OpSetIndex stores the value under the index, the operand is the opcode
+	// of the infix operator a compound assignment applies, 0 for `=`.
+	OpSetIndex
+
+	OpCall
+	// OpCallSpread calls the function with the elements of the arrays on top
+	// of the stack as arguments, the operand is the number of the arrays.
+	OpCallSpread
+	OpReturnValue
+	OpReturn
+	OpClosure
+
+	// OpIter replaces the iterable on top of the stack with an iterator.
+	OpIter
+	// OpIterNext pushes the next one or two values of the iterator on top
+	// of the stack, once it's exhausted the iterator is popped and
+	// the execution jumps to the first operand.
+	OpIterNext
+	// OpQuote pushes the value of the `quote` call kept in the constant the first
+	// operand refers to, the values of its `unquote` calls are on top of the stack,
+	// the second operand is their number.
+	OpQuote
+)
+
+// Definition describes an opcode: its name and the number of bytes
+// each of its operands takes.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:         {"OpAdd", []int{}},
+	OpSub:         {"OpSub", []int{}},
+	OpMul:         {"OpMul", []int{}},
+	OpDiv:         {"OpDiv", []int{}},
+	OpMod:         {"OpMod", []int{}},
+	OpPow:         {"OpPow", []int{}},
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpJumpBound:          {"OpJumpBound", []int{2, 1}},
+
+	OpGetGlobal:  {"OpGetGlobal", []int{2}},
+	OpSetGlobal:  {"OpSetGlobal", []int{2}},
+	OpGetLocal:   {"OpGetLocal", []int{1}},
+	OpSetLocal:   {"OpSetLocal", []int{1}},
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+	OpGetFree:    {"OpGetFree", []int{1}},
+	OpSetFree:    {"OpSetFree", []int{1}},
+
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+
+	OpSetIndex: {"OpSetIndex", []int{1}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpCallSpread:  {"OpCallSpread", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2}},
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+
+	OpQuote: {"OpQuote", []int{2, 2}},
+}
+
+// ErrUndefinedOpcode is returned when looking up an unknown opcode.
+var ErrUndefinedOpcode = errors.New("undefined opcode")
+
+// Lookup returns the definition of the given opcode.
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUndefinedOpcode, op)
+	}
+
+	return def, nil
+}
+
+// Make encodes the opcode and its operands into an instruction.
+// An empty instruction is returned for unknown opcodes.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 1:
+			instruction[offset] = byte(o)
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes operands of the instruction described by def,
+// it returns the operands and the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint8 decodes a single byte operand.
+func ReadUint8(ins Instructions) uint8 {
+	return ins[0]
+}
+
+// ReadUint16 decodes a two bytes operand.
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// SourceMap maps offsets of instructions to positions of the source code
+// they were compiled from. Entries are sorted by offset.
+type SourceMap []SourceMapEntry
+
+// SourceMapEntry tells that instructions starting at Offset were produced
+// from the source code at Pos.
+type SourceMapEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// Add records the position of the instruction at the given offset.
+// Offsets must be added in increasing order.
+func (sm SourceMap) Add(offset int, pos token.Position) SourceMap {
+	if n := len(sm); n > 0 && sm[n-1].Offset == offset {
+		sm[n-1].Pos = pos
+
+		return sm
+	}
+
+	return append(sm, SourceMapEntry{Offset: offset, Pos: pos})
+}
+
+// Lookup returns the position of the instruction at the given offset.
+func (sm SourceMap) Lookup(offset int) token.Position {
+	idx := sort.Search(len(sm), func(i int) bool {
+		return sm[i].Offset > offset
+	})
+	if idx == 0 {
+		return token.Position{}
+	}
+
+	return sm[idx-1].Pos
+}
@@ -0,0 +1,133 @@
+package code_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dstdfx/scroopy/code"
+	"github.com/dstdfx/scroopy/token"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       code.Opcode
+		operands []int
+		expected []byte
+	}{
+		{code.OpConstant, []int{65534}, []byte{byte(code.OpConstant), 255, 254}},
+		{code.OpAdd, []int{}, []byte{byte(code.OpAdd)}},
+		{code.OpGetLocal, []int{255}, []byte{byte(code.OpGetLocal), 255}},
+		{code.OpClosure, []int{65534}, []byte{byte(code.OpClosure), 255, 254}},
+	}
+
+	for _, tt := range tests {
+		instruction := code.Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Fatalf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+		}
+
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []code.Instructions{
+		code.Make(code.OpAdd),
+		code.Make(code.OpGetLocal, 1),
+		code.Make(code.OpConstant, 2),
+		code.Make(code.OpConstant, 65535),
+		code.Make(code.OpClosure, 65535),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535
+`
+
+	concatted := code.Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        code.Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{code.OpConstant, []int{65535}, 2},
+		{code.OpGetLocal, []int{255}, 1},
+	}
+
+	for _, tt := range tests {
+		instruction := code.Make(tt.op, tt.operands...)
+
+		def, err := code.Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q", err)
+		}
+
+		operandsRead, n := code.ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tt.bytesRead, n)
+		}
+
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestLookup_Undefined(t *testing.T) {
+	if _, err := code.Lookup(255); !errors.Is(err, code.ErrUndefinedOpcode) {
+		t.Errorf("expected ErrUndefinedOpcode, got=%v", err)
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	first := token.Position{Offset: 0, Line: 1, Column: 1}
+	second := token.Position{Offset: 4, Line: 1, Column: 5}
+	third := token.Position{Offset: 8, Line: 2, Column: 1}
+
+	var sm code.SourceMap
+	sm = sm.Add(0, first)
+	sm = sm.Add(3, first)
+	sm = sm.Add(3, second) // replaces the previous entry
+	sm = sm.Add(7, third)
+
+	tests := []struct {
+		offset   int
+		expected token.Position
+	}{
+		{0, first},
+		{2, first},
+		{3, second},
+		{6, second},
+		{7, third},
+		{100, third},
+	}
+
+	for _, tt := range tests {
+		if pos := sm.Lookup(tt.offset); pos != tt.expected {
+			t.Errorf("wrong position for offset %d. want=%s, got=%s", tt.offset, tt.expected, pos)
+		}
+	}
+
+	if pos := (code.SourceMap{}).Lookup(0); pos.IsValid() {
+		t.Errorf("expected invalid position for empty source map, got=%s", pos)
+	}
+}
@@ -0,0 +1,846 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/code"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/token"
+)
+
+// Limits imposed by the width of instruction operands.
+const (
+	maxConstants = 1 << 16
+	maxGlobals   = 1 << 16
+	maxLocals    = 1 << 8
+	maxArguments = 1 << 8
+)
+
+var (
+	// ErrTooManyConstants is returned when a program doesn't fit into the constant pool.
+	ErrTooManyConstants = errors.New("too many constants")
+	// ErrTooManyVariables is returned when a scope defines more variables than the VM can address.
+	ErrTooManyVariables = errors.New("too many variables")
+	// ErrTooManyArguments is returned when a call passes more arguments than the VM can address.
+	ErrTooManyArguments = errors.New("too many arguments")
+	// ErrUnknownOperator is returned for operators the compiler doesn't support.
+	ErrUnknownOperator = errors.New("unknown operator")
+	// ErrAssignToBuiltin is returned when a build-in function is assigned to.
+	ErrAssignToBuiltin = errors.New("cannot assign to build-in function")
+	// ErrInvalidAssignment is returned when the target of an assignment is neither
+	// an identifier nor an index expression.
+	ErrInvalidAssignment = errors.New("cannot assign to")
+	// ErrOutsideLoop is returned for `break` and `continue` statements found outside of loops.
+	ErrOutsideLoop = errors.New("outside of a loop")
+	// ErrMacroLiteral is returned for macro literals left in the program, macros
+	// have to be defined and expanded before the program is compiled.
+	ErrMacroLiteral = errors.New("macros can only be defined with top-level let statements")
+)
+
+// Error is a compilation error of the node at the position.
+type Error struct {
+	Pos token.Position // position of the node that failed to compile
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+}
+
+// logicalOperators maps short-circuit operators to the jumps skipping
+// their right operands.
+var logicalOperators = map[string]code.Opcode{
+	"&&": code.OpJumpNotTruthyOrPop,
+	"||": code.OpJumpTruthyOrPop,
+}
+
+var prefixOperators = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+}
+
+// Bytecode represents a compiled program.
+type Bytecode struct {
+	Instructions code.Instructions
+	SourceMap    code.SourceMap
+	Constants    []object.Object
+	GlobalNames  []string // names of global variables indexed by their slots
+}
+
+// EmittedInstruction describes an instruction emitted by the compiler.
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds instructions of a function being compiled.
+type CompilationScope struct {
+	instructions        code.Instructions
+	sourceMap           code.SourceMap
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop // loops enclosing the instruction being compiled, innermost last
+}
+
+// loop keeps track of jumps of a loop being compiled.
+type loop struct {
+	continueTarget int   // offset `continue` jumps to
+	breakJumps     []int // offsets of jumps out of the loop, patched once the loop is compiled
+	hasIterator    bool  // the loop keeps an iterator on the stack, `break` has to pop it
+}
+
+// Compiler compiles AST to bytecode.
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	pos token.Position // position of the node being compiled
+}
+
+// New returns new instance of Compiler.
+func New() *Compiler {
+	return NewWithState(NewGlobalSymbolTable(), []object.Object{})
+}
+
+// NewWithState returns new instance of Compiler that continues compilation
+// with the given global symbols and constants, e.g. for the next REPL line.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+// Compile compiles the given node. Compiling *ast.Root produces a program
+// returning the value of its last statement, as the evaluator does.
+// Errors are returned as *Error positioned at the innermost node that
+// failed to compile.
+func (c *Compiler) Compile(node ast.Node) error {
+	prevPos := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = prevPos }()
+
+	err := c.compile(node)
+	if err == nil {
+		return nil
+	}
+
+	var compileErr *Error
+	if errors.As(err, &compileErr) {
+		return err
+	}
+
+	return &Error{Pos: node.Pos(), Err: err}
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Root:
+		return c.compileRoot(node)
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.AssignStatement:
+		return c.compileAssignStatement(node)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BranchStatement:
+		return c.compileBranchStatement(node)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			// Globals may be defined later on, e.g. by the next REPL line,
+			// the VM reports an error if it's still undefined when read.
+			symbol = c.symbolTable.DefineGlobal(node.Value)
+		}
+
+		return c.loadSymbol(symbol)
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return c.emitConstant(&object.BigInt{Value: node.Big})
+		}
+
+		return c.emitConstant(&object.Integer{Value: node.Value})
+	case *ast.FloatLiteral:
+		return c.emitConstant(&object.Float{Value: node.Value})
+	case *ast.StringLiteral:
+		return c.emitConstant(&object.String{Value: node.Value})
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownOperator, node.Operator)
+		}
+
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.InfixExpression:
+		if jump, ok := logicalOperators[node.Operator]; ok {
+			return c.compileLogicalExpression(node, jump)
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownOperator, node.Operator)
+		}
+
+		// The right operand is evaluated first, the same way the evaluator does.
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+	case *ast.MacroLiteral:
+		return ErrMacroLiteral
+	case *ast.CallExpression:
+		if object.IsCallOf(node, "quote") {
+			return c.compileQuote(node)
+		}
+		if len(node.Arguments) >= maxArguments {
+			return fmt.Errorf("%w: %d", ErrTooManyArguments, len(node.Arguments))
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		if hasSpread(node.Arguments) {
+			return c.compileSpreadCall(node)
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		return c.compileHashLiteral(node)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	}
+
+	return nil
+}
+
+// Bytecode returns the compiled program.
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.GlobalNames(),
+	}
+}
+
+// SymbolTable returns the symbol table of the current scope.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) compileRoot(root *ast.Root) error {
+	for _, s := range root.Statements {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	if c.symbolTable.NumDefinitions() > maxGlobals {
+		return ErrTooManyVariables
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	var symbol Symbol
+
+	if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+		// Define the name first, so the function is able to refer to itself.
+		symbol = c.symbolTable.Define(node.Name.Value)
+		if err := c.compileFunctionLiteral(fn, node.Name.Value); err != nil {
+			return err
+		}
+	} else {
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol = c.symbolTable.Define(node.Name.Value)
+	}
+
+	c.setVariable(symbol)
+
+	return nil
+}
+
+// setVariable emits the instruction storing the value on top of the stack
+// in the variable.
+func (c *Compiler) setVariable(symbol Symbol) {
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else {
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+}
+
+// compileAssignStatement compiles the value first, then the target,
+// the same way infix expressions compile the right operand first.
+func (c *Compiler) compileAssignStatement(node *ast.AssignStatement) error {
+	var operator code.Opcode
+	if node.Operator != "" {
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownOperator, node.Operator)
+		}
+		operator = op
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			symbol = c.symbolTable.DefineGlobal(target.Value)
+		}
+		if symbol.Scope == BuiltinScope {
+			return fmt.Errorf("%w: %s", ErrAssignToBuiltin, target.Value)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if node.Operator != "" {
+			if err := c.Compile(target); err != nil {
+				return err
+			}
+			c.emit(operator)
+		}
+
+		switch symbol.Scope {
+		case GlobalScope:
+			c.emit(code.OpAssignGlobal, symbol.Index)
+		case LocalScope:
+			c.emit(code.OpSetLocal, symbol.Index)
+		case FreeScope:
+			c.emit(code.OpSetFree, symbol.Index)
+		}
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex, int(operator))
+	default:
+		return fmt.Errorf("%w %s", ErrInvalidAssignment, node.Target)
+	}
+
+	return nil
+}
+
+// compileLogicalExpression compiles `&&` and `||`, the right operand is
+// skipped if the left one decides the result.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression, jump code.Opcode) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(jump, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	loopStart := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	exitJumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileLoopBody(node.Body, &loop{continueTarget: loopStart}); err != nil {
+		return err
+	}
+
+	c.changeOperand(exitJumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileForStatement compiles the loop keeping the iterator on the stack
+// for the whole loop, the iterator is popped by OpIterNext once exhausted.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	numVars := 1
+	if node.Key != nil {
+		numVars = 2
+	}
+
+	loopStart := c.emit(code.OpIterNext, 9999, numVars)
+
+	// The value is on top of the key.
+	c.setVariable(c.symbolTable.Define(node.Value.Value))
+	if node.Key != nil {
+		c.setVariable(c.symbolTable.Define(node.Key.Value))
+	}
+
+	if err := c.compileLoopBody(node.Body, &loop{continueTarget: loopStart, hasIterator: true}); err != nil {
+		return err
+	}
+
+	c.changeOperand(loopStart, len(c.currentInstructions()), numVars)
+
+	return nil
+}
+
+// compileLoopBody compiles the body followed by the jump to the next
+// iteration, then points jumps of `break` statements past the loop.
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, l *loop) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, l)
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, l.continueTarget)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range l.breakJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileBranchStatement(node *ast.BranchStatement) error {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return fmt.Errorf("%s %w", node.TokenLiteral(), ErrOutsideLoop)
+	}
+	l := loops[len(loops)-1]
+
+	if node.Token.Type == token.CONTINUE {
+		c.emit(code.OpJump, l.continueTarget)
+
+		return nil
+	}
+
+	if l.hasIterator {
+		c.emit(code.OpPop)
+	}
+	l.breakJumps = append(l.breakJumps, c.emit(code.OpJump, 9999))
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit with bogus offsets, they're patched once the branches are compiled.
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBranch(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBranch(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileBranch compiles the block so it leaves exactly one value,
+// the value of its last expression or null, on the stack.
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	startsAt := len(c.currentInstructions())
+
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if len(c.currentInstructions()) > startsAt && c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	// Parameters are defined one by one, so default values only see
+	// the preceding ones.
+	for i, p := range node.Parameters {
+		if node.Defaults != nil && node.Defaults[i] != nil {
+			jumpPos := c.emit(code.OpJumpBound, 9999, i)
+			if err := c.Compile(node.Defaults[i]); err != nil {
+				return err
+			}
+			c.emit(code.OpSetLocal, i)
+			c.changeOperand(jumpPos, len(c.currentInstructions()), i)
+		}
+		c.symbolTable.Define(p.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	if numLocals > maxLocals || len(freeSymbols) > maxLocals {
+		return ErrTooManyVariables
+	}
+
+	instructions, sourceMap := c.leaveScope()
+
+	captures := make([]object.Capture, 0, len(freeSymbols))
+	for _, s := range freeSymbols {
+		captures = append(captures, object.Capture{IsLocal: s.Scope == LocalScope, Index: s.Index})
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          name,
+		Instructions:  instructions,
+		SourceMap:     sourceMap,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   numDefaults(node),
+		Variadic:      node.Rest != nil,
+		Captures:      captures,
+	}
+
+	idx, err := c.addConstant(compiledFn)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpClosure, idx)
+
+	return nil
+}
+
+// compileSpreadCall compiles a call with spread arguments: every other
+// argument is wrapped into an array of its own, so OpCallSpread gets
+// arrays only.
+func (c *Compiler) compileSpreadCall(node *ast.CallExpression) error {
+	for _, arg := range node.Arguments {
+		if spread, ok := arg.(*ast.SpreadExpression); ok {
+			if err := c.Compile(spread.Value); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+		c.emit(code.OpArray, 1)
+	}
+	c.emit(code.OpCallSpread, len(node.Arguments))
+
+	return nil
+}
+
+// compileQuote compiles the arguments of the `unquote` calls of the quoted node,
+// the quote is built from their values when the call is executed.
+func (c *Compiler) compileQuote(call *ast.CallExpression) error {
+	numValues := 0
+	if len(call.Arguments) == 1 {
+		for _, unquote := range object.UnquoteCalls(call.Arguments[0]) {
+			if len(unquote.Arguments) != 1 {
+				break
+			}
+
+			if err := c.Compile(unquote.Arguments[0]); err != nil {
+				return err
+			}
+			numValues++
+		}
+	}
+
+	idx, err := c.addConstant(&object.Quote{Node: call})
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpQuote, idx, numValues)
+
+	return nil
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func numDefaults(node *ast.FunctionLiteral) int {
+	n := 0
+	for _, d := range node.Defaults {
+		if d != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (c *Compiler) compileHashLiteral(node *ast.HashLiteral) error {
+	keys := make([]ast.Expression, 0, len(node.Pairs))
+	for k := range node.Pairs {
+		keys = append(keys, k)
+	}
+
+	// Sort keys to emit instructions in a deterministic order.
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, k := range keys {
+		if err := c.Compile(k); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Pairs[k]); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpHash, len(node.Pairs)*2)
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) error {
+	switch s.Scope {
+	case GlobalScope:
+		if s.Index >= maxGlobals {
+			return ErrTooManyVariables
+		}
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+
+	return nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	idx, err := c.addConstant(obj)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, idx)
+
+	return nil
+}
+
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) >= maxConstants {
+		return 0, ErrTooManyConstants
+	}
+	c.constants = append(c.constants, obj)
+
+	return len(c.constants) - 1, nil
+}
+
+// emit appends the instruction to the current scope and returns its offset.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+	posNewInstruction := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+	scope.sourceMap = scope.sourceMap.Add(posNewInstruction, c.pos)
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.previousInstruction = scope.lastInstruction
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction
+
+	scope.instructions = scope.instructions[:last.Position]
+	for len(scope.sourceMap) > 0 && scope.sourceMap[len(scope.sourceMap)-1].Offset >= last.Position {
+		scope.sourceMap = scope.sourceMap[:len(scope.sourceMap)-1]
+	}
+	scope.lastInstruction = scope.previousInstruction
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	copy(ins[pos:], newInstruction)
+}
+
+func (c *Compiler) changeOperand(opPos int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.replaceInstruction(opPos, code.Make(op, operands...))
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.SourceMap) {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope.instructions, scope.sourceMap
+}
@@ -0,0 +1,550 @@
+package compiler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dstdfx/scroopy/code"
+	"github.com/dstdfx/scroopy/compiler"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; -2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMinus),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (true) { let a = 1; } else { 20 }",
+			expectedConstants: []interface{}{1, 20},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 17),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = one; two;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let one = 1;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { break; continue; 1 }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 17),
+				// 0004
+				code.Make(code.OpJump, 17),
+				// 0007
+				code.Make(code.OpJump, 0),
+				// 0010
+				code.Make(code.OpConstant, 0),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpJump, 0),
+				// 0017
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "for (k, v in []) { break; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpArray, 0),
+				// 0003
+				code.Make(code.OpIter),
+				// 0004
+				code.Make(code.OpIterNext, 21, 2),
+				// 0008
+				code.Make(code.OpSetGlobal, 0),
+				// 0011
+				code.Make(code.OpSetGlobal, 1),
+				// 0014
+				code.Make(code.OpPop),
+				// 0015
+				code.Make(code.OpJump, 21),
+				// 0018
+				code.Make(code.OpJump, 4),
+				// 0021
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && false; 1 <= 2",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthyOrPop, 5),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpPop),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpLessEqual),
+				// 0013
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "false || 1 >= 2",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpFalse),
+				// 0001
+				code.Make(code.OpJumpTruthyOrPop, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpGreaterEqual),
+				// 0011
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x += 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "arr[0] *= 2",
+			expectedConstants: []interface{}{2, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetIndex, int(code.OpMul)),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input: "fn(a) { fn() { a = 1 } }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpReturn),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { let b = a; b }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a, b = a) { b }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpJumpBound, 8, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "push(1, ...[])",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 5),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCallSpread, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "len([]); push([], 1);",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 1),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetBuiltin, 5),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosureCaptures(t *testing.T) {
+	input := "fn(a) { let b = 1; fn(c) { fn() { a + b + c } } }"
+
+	c := compileInput(t, input)
+	constants := c.Bytecode().Constants
+
+	// Operands are compiled right to left, so c is captured first,
+	// then b and a are captured from the free variables of the middle function.
+	innermost := constants[1].(*object.CompiledFunction)
+	expected := []object.Capture{{IsLocal: true, Index: 0}, {IsLocal: false, Index: 0}, {IsLocal: false, Index: 1}}
+	if len(innermost.Captures) != len(expected) {
+		t.Fatalf("wrong number of captures. want=%d, got=%d", len(expected), len(innermost.Captures))
+	}
+
+	for i, capture := range expected {
+		if innermost.Captures[i] != capture {
+			t.Errorf("wrong capture[%d]. want=%+v, got=%+v", i, capture, innermost.Captures[i])
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// Only arguments of the `unquote` calls are compiled.
+			input:             "quote(a + unquote(1) * unquote(2))",
+			expectedConstants: []interface{}{1, 2, "QUOTE(quote((a + (unquote(1) * unquote(2)))))"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpQuote, 2, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// Values of `unquote` calls following a malformed one are not needed.
+			input:             "quote(unquote(1) + unquote(2, 3) + unquote(4))",
+			expectedConstants: []interface{}{1, "QUOTE(quote(((unquote(1) + unquote(2, 3)) + unquote(4))))"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpQuote, 1, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr error
+		expected    string
+	}{
+		{"let f = fn() {\n  print(macro(x) { x });\n};", compiler.ErrMacroLiteral,
+			"2:9: macros can only be defined with top-level let statements"},
+		{"if (true) {\n  break;\n}", compiler.ErrOutsideLoop, "2:3: break outside of a loop"},
+		{"len = 1", compiler.ErrAssignToBuiltin, "1:1: cannot assign to build-in function: len"},
+	}
+
+	for _, tt := range tests {
+		err := compiler.New().Compile(parser.New(lexer.New(tt.input)).ParseProgram())
+
+		var compileErr *compiler.Error
+		if !errors.As(err, &compileErr) || !errors.Is(err, tt.expectedErr) {
+			t.Fatalf("wrong error for %q. got=%v", tt.input, err)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	c := compileInput(t, "let x = 1;\nx + true")
+	bytecode := c.Bytecode()
+
+	// OpAdd follows OpConstant, OpSetGlobal, OpTrue and OpGetGlobal.
+	addOffset := 3 + 3 + 1 + 3
+	if op := code.Opcode(bytecode.Instructions[addOffset]); op != code.OpAdd {
+		t.Fatalf("unexpected instruction at %d: %d", addOffset, op)
+	}
+
+	if pos := bytecode.SourceMap.Lookup(addOffset); pos.String() != "2:1" {
+		t.Errorf("wrong position of OpAdd. want=2:1, got=%s", pos)
+	}
+}
+
+func TestCompilerKeepsState(t *testing.T) {
+	first := compileInput(t, "let a = 1;")
+	bytecode := first.Bytecode()
+
+	second := compiler.NewWithState(first.SymbolTable(), bytecode.Constants)
+	root := parser.New(lexer.New("let b = 2; a + b")).ParseProgram()
+	if err := second.Compile(root); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expectedGlobals := []string{"a", "b"}
+	globalNames := second.Bytecode().GlobalNames
+	if len(globalNames) != len(expectedGlobals) {
+		t.Fatalf("wrong globals. want=%v, got=%v", expectedGlobals, globalNames)
+	}
+
+	for i, name := range expectedGlobals {
+		if globalNames[i] != name {
+			t.Errorf("wrong global %d. want=%s, got=%s", i, name, globalNames[i])
+		}
+	}
+}
+
+func compileInput(t *testing.T, input string) *compiler.Compiler {
+	t.Helper()
+
+	root := parser.New(lexer.New(input)).ParseProgram()
+	c := compiler.New()
+	if err := c.Compile(root); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return c
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		bytecode := compileInput(t, tt.input).Bytecode()
+
+		testInstructions(t, tt.input, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(t, tt.input, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func testInstructions(t *testing.T, input string, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != actual.String() {
+		t.Errorf("wrong instructions for %q.\nwant=\n%s\ngot=\n%s", input, concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, input string, expected []interface{}, actual []object.Object) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("wrong number of constants for %q. want=%d, got=%d", input, len(expected), len(actual))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				t.Errorf("constant %d of %q is not %d. got=%+v", i, input, constant, actual[i])
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("constant %d of %q is not a function. got=%T", i, input, actual[i])
+
+				continue
+			}
+
+			testInstructions(t, input, constant, fn.Instructions)
+		case string:
+			if actual[i].Inspect() != constant {
+				t.Errorf("constant %d of %q is not %s. got=%s", i, input, constant, actual[i].Inspect())
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package compiler
+
+import "github.com/dstdfx/scroopy/object"
+
+// SymbolScope represents the kind of storage a symbol lives in.
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+// Symbol represents a resolved identifier.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable keeps track of identifiers defined in a single scope.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	// FreeSymbols holds the original symbols of the enclosing scopes
+	// the scope refers to, in the order of their free indexes.
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	numDefinitions int
+}
+
+// NewSymbolTable returns new instance of SymbolTable.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		FreeSymbols: make([]Symbol, 0),
+		store:       make(map[string]Symbol),
+	}
+}
+
+// NewGlobalSymbolTable returns new instance of SymbolTable with every
+// build-in function defined in it.
+func NewGlobalSymbolTable() *SymbolTable {
+	s := NewSymbolTable()
+	for idx, def := range object.Builtins {
+		s.DefineBuiltin(idx, def.Name)
+	}
+
+	return s
+}
+
+// NewEnclosedSymbolTable returns new instance of SymbolTable for a scope
+// nested into the outer one.
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+
+	return s
+}
+
+// Define defines the name in the scope. Defining a name that is already
+// defined in the same scope returns the existing symbol, so code compiled
+// earlier keeps referring to the same variable.
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := LocalScope
+	if s.Outer == nil {
+		scope = GlobalScope
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope}
+	s.store[name] = symbol
+	s.numDefinitions++
+
+	return symbol
+}
+
+// DefineGlobal defines the name in the outermost scope.
+func (s *SymbolTable) DefineGlobal(name string) Symbol {
+	global := s
+	for global.Outer != nil {
+		global = global.Outer
+	}
+
+	return global.Define(name)
+}
+
+// DefineBuiltin defines the build-in function with the given index.
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+
+	return symbol
+}
+
+// Resolve looks the name up in the scope and the enclosing ones.
+// Local variables of enclosing functions become free symbols of the scope.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// NumDefinitions returns the number of variables defined in the scope.
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
+
+// GlobalNames returns names of the global variables indexed by
+// their symbol indexes.
+func (s *SymbolTable) GlobalNames() []string {
+	global := s
+	for global.Outer != nil {
+		global = global.Outer
+	}
+
+	names := make([]string, global.numDefinitions)
+	for name, symbol := range global.store {
+		if symbol.Scope == GlobalScope && names[symbol.Index] == "" {
+			names[symbol.Index] = name
+		}
+	}
+
+	return names
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+
+	return symbol
+}
@@ -0,0 +1,70 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/dstdfx/scroopy/compiler"
+)
+
+func TestResolve(t *testing.T) {
+	global := compiler.NewGlobalSymbolTable()
+	global.Define("a")
+
+	first := compiler.NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := compiler.NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	expected := []compiler.Symbol{
+		{Name: "a", Scope: compiler.GlobalScope, Index: 0},
+		{Name: "c", Scope: compiler.LocalScope, Index: 0},
+		{Name: "b", Scope: compiler.FreeScope, Index: 0},
+		{Name: "len", Scope: compiler.BuiltinScope, Index: 1},
+	}
+
+	for _, sym := range expected {
+		result, ok := second.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+
+			continue
+		}
+
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0].Scope != compiler.LocalScope {
+		t.Errorf("wrong free symbols: %+v", second.FreeSymbols)
+	}
+
+	if _, ok := second.Resolve("d"); ok {
+		t.Errorf("name d resolved, but was never defined")
+	}
+}
+
+func TestDefine_Redefinition(t *testing.T) {
+	global := compiler.NewGlobalSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+
+	if redefined := global.Define("a"); redefined != a {
+		t.Errorf("redefinition created new symbol. want=%+v, got=%+v", a, redefined)
+	}
+
+	// Shadowing a build-in function defines a global variable.
+	if shadowed := global.Define("len"); shadowed.Scope != compiler.GlobalScope || shadowed.Index != 2 {
+		t.Errorf("wrong symbol for shadowed build-in: %+v", shadowed)
+	}
+
+	local := compiler.NewEnclosedSymbolTable(global)
+	if symbol := local.DefineGlobal("c"); symbol.Scope != compiler.GlobalScope || symbol.Index != 3 {
+		t.Errorf("wrong symbol defined with DefineGlobal: %+v", symbol)
+	}
+
+	if global.NumDefinitions() != 4 {
+		t.Errorf("wrong number of definitions. want=4, got=%d", global.NumDefinitions())
+	}
+}
@@ -0,0 +1,549 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/internal/frame"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+// ErrMalformedHeader is returned for messages without a valid Content-Length header.
+var ErrMalformedHeader = frame.ErrMalformedHeader
+
+// threadID is the ID of the only thread programs have.
+const threadID = 1
+
+// request is a Debug Adapter Protocol request sent by the client.
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// resumeActions maps the requests resuming the paused program to the actions.
+var resumeActions = map[string]Action{
+	"continue": Continue,
+	"next":     StepOver,
+	"stepIn":   StepIn,
+	"stepOut":  StepOut,
+}
+
+// DAPServer is a Debug Adapter Protocol front end of the debugger, it lets
+// editors launch a program, set breakpoints, step through the program and
+// inspect it. A single program is debugged per server.
+type DAPServer struct {
+	r        *bufio.Reader
+	w        io.Writer
+	argsName string
+
+	writeMu sync.Mutex
+	seq     int
+
+	mu          sync.Mutex
+	debugger    *Debugger
+	src         string // source code of the launched program
+	stopOnEntry bool
+	scriptArgs  []string
+	stop        *Stop // current stop, nil while the program runs
+	terminating bool
+	resume      chan Action
+	done        chan struct{} // closed when the program ends
+}
+
+// NewDAPServer returns a server reading requests from r and writing responses
+// and events to w. The `args` of the launch request are bound to argsName
+// as an array of strings.
+func NewDAPServer(r io.Reader, w io.Writer, argsName string) *DAPServer {
+	return &DAPServer{
+		r:        bufio.NewReader(r),
+		w:        w,
+		argsName: argsName,
+		resume:   make(chan Action),
+	}
+}
+
+// Output returns a writer sending everything written to it to the client
+// as output events of the category, e.g. "stdout".
+func (s *DAPServer) Output(category string) io.Writer {
+	return outputWriter{s: s, category: category}
+}
+
+type outputWriter struct {
+	s        *DAPServer
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	if err := w.s.event("output", map[string]interface{}{"category": w.category, "output": string(p)}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Serve handles requests until the client disconnects or the input ends,
+// the program still running is terminated.
+func (s *DAPServer) Serve() error {
+	defer s.terminate()
+
+	for {
+		content, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		req := &request{}
+		if err := json.Unmarshal(content, req); err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(req)
+		if err := s.respond(req, body, err); err != nil {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+
+		// Events following the response are sent after it.
+		if action, ok := resumeActions[req.Command]; ok {
+			s.continueWith(action)
+		}
+
+		switch req.Command {
+		case "configurationDone":
+			s.start()
+		case "launch":
+			// Breakpoints can be set once the program is loaded.
+			if err := s.event("initialized", nil); err != nil {
+				return err
+			}
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+func (s *DAPServer) handle(req *request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		return nil, s.launch(req.Arguments)
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+	case "configurationDone":
+		return nil, s.prepare()
+	case "threads":
+		return map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": threadID, "name": "main"}},
+		}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(req.Arguments)
+	case "variables":
+		return s.variables(req.Arguments)
+	case "evaluate":
+		return s.evaluate(req.Arguments)
+	case "continue":
+		_, err := s.pausedStop()
+
+		return map[string]interface{}{"allThreadsContinued": true}, err
+	case "next", "stepIn", "stepOut":
+		_, err := s.pausedStop()
+
+		return nil, err
+	case "pause":
+		debugger, err := s.loaded()
+		if err != nil {
+			return nil, err
+		}
+		debugger.Pause()
+
+		return nil, nil
+	case "terminate", "disconnect":
+		s.terminate()
+
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported command %q", req.Command)
+	}
+}
+
+func (s *DAPServer) launch(arguments json.RawMessage) error {
+	var args struct {
+		Program     string   `json:"program"`
+		Args        []string `json:"args"`
+		StopOnEntry bool     `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	src, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	p := parser.New(lexer.NewWithFilename(args.Program, string(src)))
+	root := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return errors.New(p.Errors().Render(string(src)))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Stdout carries the protocol, the output of the program is sent
+	// to the client in output events.
+	config := evaluator.Config{Builtins: object.BuiltinsWritingTo(s.Output("stdout"))}
+	s.debugger = NewWithConfig(root, s.paused, config)
+	s.src = string(src)
+	s.stopOnEntry = args.StopOnEntry
+	s.scriptArgs = args.Args
+
+	return nil
+}
+
+func (s *DAPServer) loaded() (*Debugger, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.debugger == nil {
+		return nil, errors.New("no program is launched")
+	}
+
+	return s.debugger, nil
+}
+
+func (s *DAPServer) setBreakpoints(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	debugger, err := s.loaded()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]int, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		lines = append(lines, bp.Line)
+	}
+
+	breakpoints := make([]map[string]interface{}, 0, len(lines))
+	for _, line := range debugger.SetBreakpoints(lines) {
+		breakpoints = append(breakpoints, map[string]interface{}{"verified": line != 0, "line": line})
+	}
+
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// prepare checks the launched program can be started.
+func (s *DAPServer) prepare() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.debugger == nil {
+		return errors.New("no program is launched")
+	}
+	if s.done != nil {
+		return errors.New("program is already started")
+	}
+
+	return nil
+}
+
+// start runs the launched program on its own goroutine.
+func (s *DAPServer) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elements := make([]object.Object, 0, len(s.scriptArgs))
+	for _, arg := range s.scriptArgs {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	env := object.NewEnvironment()
+	env.Set(s.argsName, &object.Array{Elements: elements})
+
+	debugger, stopOnEntry, src := s.debugger, s.stopOnEntry, s.src
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		evaluated, err := debugger.Run(env, stopOnEntry)
+		if errObj, ok := evaluated.(*object.Error); ok && err == nil {
+			exitCode = 1
+			_, _ = s.Output("stderr").Write([]byte(runtimeError(src, errObj)))
+		}
+
+		_ = s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		_ = s.event("terminated", nil)
+	}()
+}
+
+func runtimeError(src string, errObj *object.Error) string {
+	msg := fmt.Sprintf("%s: runtime error: %s\n", errObj.Pos, errObj.Message)
+	if excerpt := errObj.Pos.Excerpt(src); excerpt != "" {
+		msg += excerpt + "\n"
+	}
+
+	return msg + errObj.Traceback()
+}
+
+// paused is the handler of the debugger, it's called on the program goroutine
+// and waits for the client to resume the program.
+func (s *DAPServer) paused(stop *Stop) Action {
+	s.mu.Lock()
+	if s.terminating {
+		s.mu.Unlock()
+
+		return Terminate
+	}
+	s.stop = stop
+	s.mu.Unlock()
+
+	_ = s.event("stopped", map[string]interface{}{
+		"reason":            string(stop.Reason),
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	return <-s.resume
+}
+
+// continueWith hands the action to the paused program goroutine. The stop is
+// cleared before, so a request that follows never sees a stale pause.
+func (s *DAPServer) continueWith(action Action) {
+	s.mu.Lock()
+	s.stop = nil
+	s.mu.Unlock()
+
+	s.resume <- action
+}
+
+// terminate aborts the program at the next statement and waits for it to end.
+func (s *DAPServer) terminate() {
+	s.mu.Lock()
+	s.terminating = true
+	paused := s.stop != nil
+	debugger, done := s.debugger, s.done
+	s.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+
+	if paused {
+		s.continueWith(Terminate)
+	} else {
+		debugger.Pause()
+	}
+	<-done
+}
+
+func (s *DAPServer) pausedStop() (*Stop, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return nil, errors.New("program is not paused")
+	}
+
+	return s.stop, nil
+}
+
+func (s *DAPServer) stackTrace() (interface{}, error) {
+	stop, err := s.pausedStop()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]map[string]interface{}, 0)
+	for i, frame := range stop.Frames() {
+		frames = append(frames, map[string]interface{}{
+			"id":     i,
+			"name":   frame.Function,
+			"line":   frame.Pos.Line,
+			"column": frame.Pos.Column,
+			"source": map[string]interface{}{
+				"name": filepath.Base(frame.Pos.Filename),
+				"path": frame.Pos.Filename,
+			},
+		})
+	}
+
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// scopes returns the environment chain of the paused statement for the
+// innermost frame, environments of the callers aren't kept.
+func (s *DAPServer) scopes(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	stop, err := s.pausedStop()
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]map[string]interface{}, 0)
+	if args.FrameID != 0 {
+		return map[string]interface{}{"scopes": scopes}, nil
+	}
+
+	envs := stop.Scopes()
+	for i := range envs {
+		name := "Closure"
+		switch {
+		case i == len(envs)-1:
+			name = "Globals"
+		case i == 0:
+			name = "Locals"
+		}
+
+		// References start at 1, 0 means there are no variables.
+		scopes = append(scopes, map[string]interface{}{
+			"name":               name,
+			"variablesReference": i + 1,
+			"expensive":          false,
+		})
+	}
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *DAPServer) variables(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	stop, err := s.pausedStop()
+	if err != nil {
+		return nil, err
+	}
+
+	envs := stop.Scopes()
+	if args.VariablesReference < 1 || args.VariablesReference > len(envs) {
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+
+	env := envs[args.VariablesReference-1]
+	variables := make([]map[string]interface{}, 0)
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		variables = append(variables, map[string]interface{}{
+			"name":               name,
+			"value":              value.Inspect(),
+			"type":               string(value.Type()),
+			"variablesReference": 0,
+		})
+	}
+
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (s *DAPServer) evaluate(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	stop, err := s.pausedStop()
+	if err != nil {
+		return nil, err
+	}
+
+	evaluated, err := stop.Eval(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+
+	return map[string]interface{}{"result": evaluated.Inspect(), "variablesReference": 0}, nil
+}
+
+func (s *DAPServer) respond(req *request, body interface{}, err error) error {
+	resp := map[string]interface{}{
+		"type":        "response",
+		"request_seq": req.Seq,
+		"command":     req.Command,
+		"success":     err == nil,
+	}
+	if body != nil {
+		resp["body"] = body
+	}
+	if err != nil {
+		resp["message"] = err.Error()
+	}
+
+	return s.write(resp)
+}
+
+func (s *DAPServer) event(event string, body interface{}) error {
+	msg := map[string]interface{}{"type": "event", "event": event}
+	if body != nil {
+		msg["body"] = body
+	}
+
+	return s.write(msg)
+}
+
+// read returns the content of the next message framed with the Content-Length
+// header, io.EOF is returned if the input ends between messages.
+func (s *DAPServer) read() ([]byte, error) {
+	return frame.Read(s.r)
+}
+
+func (s *DAPServer) write(msg map[string]interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	msg["seq"] = s.seq
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return frame.Write(s.w, content)
+}
@@ -0,0 +1,328 @@
+package debug_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/scroopy/debug"
+)
+
+// dapClient talks to a DAP server running in the same process.
+type dapClient struct {
+	t        *testing.T
+	w        io.WriteCloser
+	seq      int
+	messages chan map[string]interface{}
+	done     chan error
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &dapClient{
+		t:        t,
+		w:        clientOut,
+		messages: make(chan map[string]interface{}, 64),
+		done:     make(chan error, 1),
+	}
+
+	server := debug.NewDAPServer(serverIn, serverOut, "args")
+
+	go func() {
+		c.done <- server.Serve()
+		_ = serverOut.Close()
+	}()
+	go c.readLoop(bufio.NewReader(clientIn))
+
+	t.Cleanup(func() {
+		_ = clientOut.Close()
+		<-c.done
+	})
+
+	return c
+}
+
+func (c *dapClient) readLoop(r *bufio.Reader) {
+	for {
+		length := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if strings.HasPrefix(line, "Content-Length:") {
+				length, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+			}
+		}
+
+		content := make([]byte, length)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return
+		}
+
+		msg := make(map[string]interface{})
+		if err := json.Unmarshal(content, &msg); err != nil {
+			return
+		}
+		c.messages <- msg
+	}
+}
+
+// request sends the request and returns the response, which must come next.
+func (c *dapClient) request(command string, arguments interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	c.send(command, arguments)
+
+	return c.response(command)
+}
+
+func (c *dapClient) send(command string, arguments interface{}) {
+	c.t.Helper()
+
+	c.seq++
+	content, err := json.Marshal(map[string]interface{}{
+		"seq": c.seq, "type": "request", "command": command, "arguments": arguments,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(content), content); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// response waits for the response to the last request, which must come next.
+func (c *dapClient) response(command string) map[string]interface{} {
+	c.t.Helper()
+
+	resp := c.next()
+	if resp["type"] != "response" || resp["command"] != command || resp["request_seq"] != float64(c.seq) {
+		c.t.Fatalf("unexpected message instead of the %s response: %v", command, resp)
+	}
+
+	return resp
+}
+
+// success sends the request and returns the body of the successful response.
+func (c *dapClient) success(command string, arguments interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	resp := c.request(command, arguments)
+	if resp["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, resp["message"])
+	}
+
+	body, _ := resp["body"].(map[string]interface{})
+
+	return body
+}
+
+// event waits for the event, which must come next, and returns its body.
+func (c *dapClient) event(event string) map[string]interface{} {
+	c.t.Helper()
+
+	msg := c.next()
+	if msg["type"] != "event" || msg["event"] != event {
+		c.t.Fatalf("unexpected message instead of the %s event: %v", event, msg)
+	}
+
+	body, _ := msg["body"].(map[string]interface{})
+
+	return body
+}
+
+func (c *dapClient) next() map[string]interface{} {
+	c.t.Helper()
+
+	select {
+	case msg := <-c.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+
+		return nil
+	}
+}
+
+func TestDAPServer(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "script.scr")
+	if err := os.WriteFile(filename, []byte(program+"print(y);\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newDAPClient(t)
+
+	capabilities := c.success("initialize", map[string]interface{}{"adapterID": "scroopy"})
+	if capabilities["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("wrong capabilities. got=%v", capabilities)
+	}
+
+	c.success("launch", map[string]interface{}{"program": filename})
+	c.event("initialized")
+
+	body := c.success("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": filename},
+		"breakpoints": []map[string]interface{}{{"line": 2}, {"line": 100}},
+	})
+	expectedBreakpoints := `[map[line:2 verified:true] map[line:0 verified:false]]`
+	if got := fmt.Sprint(body["breakpoints"]); got != expectedBreakpoints {
+		t.Errorf("wrong breakpoints. expected=%s, got=%s", expectedBreakpoints, got)
+	}
+
+	c.success("configurationDone", nil)
+	if stopped := c.event("stopped"); stopped["reason"] != "breakpoint" || stopped["threadId"] != float64(1) {
+		t.Errorf("wrong stopped event. got=%v", stopped)
+	}
+
+	body = c.success("stackTrace", map[string]interface{}{"threadId": 1})
+	frames := body["stackFrames"].([]interface{})
+	if len(frames) != 2 {
+		t.Fatalf("wrong number of frames. expected=2, got=%v", frames)
+	}
+	top := frames[0].(map[string]interface{})
+	if top["name"] != "add" || top["line"] != float64(2) || top["column"] != float64(3) {
+		t.Errorf("wrong top frame. got=%v", top)
+	}
+
+	body = c.success("scopes", map[string]interface{}{"frameId": 0})
+	if got := fmt.Sprint(body["scopes"]); got != "[map[expensive:false name:Locals variablesReference:1] "+
+		"map[expensive:false name:Globals variablesReference:2]]" {
+		t.Errorf("wrong scopes. got=%s", got)
+	}
+
+	body = c.success("variables", map[string]interface{}{"variablesReference": 1})
+	if got := fmt.Sprint(body["variables"]); got != "[map[name:a type:INTEGER value:1 variablesReference:0] "+
+		"map[name:b type:INTEGER value:2 variablesReference:0]]" {
+		t.Errorf("wrong variables. got=%s", got)
+	}
+
+	body = c.success("evaluate", map[string]interface{}{"expression": "a + b", "frameId": 0})
+	if body["result"] != "3" {
+		t.Errorf("wrong evaluation result. expected=3, got=%v", body["result"])
+	}
+	if resp := c.request("evaluate", map[string]interface{}{"expression": "missing"}); resp["success"] != false ||
+		resp["message"] != "identifier not found: missing" {
+		t.Errorf("wrong evaluation error. got=%v", resp)
+	}
+
+	c.success("next", map[string]interface{}{"threadId": 1})
+	if stopped := c.event("stopped"); stopped["reason"] != "step" {
+		t.Errorf("wrong stopped event. got=%v", stopped)
+	}
+	body = c.success("stackTrace", map[string]interface{}{"threadId": 1})
+	if line := body["stackFrames"].([]interface{})[0].(map[string]interface{})["line"]; line != float64(3) {
+		t.Errorf("wrong line after the step. expected=3, got=%v", line)
+	}
+
+	// The breakpoint is hit by the second call too.
+	c.success("continue", map[string]interface{}{"threadId": 1})
+	c.event("stopped")
+	c.success("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": filename},
+		"breakpoints": []map[string]interface{}{},
+	})
+	c.success("continue", map[string]interface{}{"threadId": 1})
+
+	if output := c.event("output"); output["category"] != "stdout" || output["output"] != "6\n" {
+		t.Errorf("wrong output event. got=%v", output)
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(0) {
+		t.Errorf("wrong exit code. got=%v", exited)
+	}
+	c.event("terminated")
+
+	if resp := c.request("continue", map[string]interface{}{"threadId": 1}); resp["success"] != false {
+		t.Errorf("expected continue to fail after the program ended. got=%v", resp)
+	}
+
+	c.success("disconnect", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	c.done <- nil
+}
+
+func TestDAPServer_Terminate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "loop.scr")
+	if err := os.WriteFile(filename, []byte("let x = 0;\nwhile (true) { x += 1; }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newDAPClient(t)
+	c.success("initialize", nil)
+	c.success("launch", map[string]interface{}{"program": filename, "stopOnEntry": true})
+	c.event("initialized")
+	c.success("configurationDone", nil)
+	if stopped := c.event("stopped"); stopped["reason"] != "entry" {
+		t.Errorf("wrong stopped event. got=%v", stopped)
+	}
+
+	c.success("continue", map[string]interface{}{"threadId": 1})
+	c.success("pause", map[string]interface{}{"threadId": 1})
+	if stopped := c.event("stopped"); stopped["reason"] != "pause" {
+		t.Errorf("wrong stopped event. got=%v", stopped)
+	}
+
+	c.success("continue", map[string]interface{}{"threadId": 1})
+	c.send("disconnect", map[string]interface{}{"terminateDebuggee": true})
+
+	// The running program is terminated before the disconnect is answered.
+	c.event("exited")
+	c.event("terminated")
+	if resp := c.response("disconnect"); resp["success"] != true {
+		t.Errorf("disconnect failed: %v", resp["message"])
+	}
+
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	c.done <- nil
+}
+
+func TestDAPServer_MalformedHeader(t *testing.T) {
+	c := newDAPClient(t)
+	if _, err := io.WriteString(c.w, "Content-Length: 9999999999999999\r\n\r\n{}"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-c.done; !errors.Is(err, debug.ErrMalformedHeader) {
+		t.Errorf("wrong error. expected=%v, got=%v", debug.ErrMalformedHeader, err)
+	}
+	c.done <- nil
+}
+
+func TestDAPServer_LaunchErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "broken.scr")
+	if err := os.WriteFile(filename, []byte("let x 1;\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newDAPClient(t)
+	c.success("initialize", nil)
+
+	resp := c.request("launch", map[string]interface{}{"program": filename})
+	expected := filename + ":1:7: expected next token to be '=', got 'INT' instead\nlet x 1;\n      ^\n"
+	if resp["success"] != false || resp["message"] != expected {
+		t.Errorf("wrong launch response. got=%v", resp)
+	}
+
+	if resp := c.request("configurationDone", nil); resp["success"] != false {
+		t.Errorf("expected configurationDone to fail without a program. got=%v", resp)
+	}
+}
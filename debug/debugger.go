@@ -0,0 +1,317 @@
+// Package debug implements a debugger for Scroopy programs executed
+// by the tree-walking evaluator: breakpoints by line, stepping into,
+// over and out of functions, inspection of the environment and the call
+// stack of the paused program and evaluation of expressions in it.
+//
+// The program runs on the goroutine calling Debugger.Run. Whenever it's
+// paused, the handler given to New is called on the same goroutine and the
+// program stays paused until the handler returns the next action.
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+	"github.com/dstdfx/scroopy/token"
+)
+
+// ErrTerminated is returned by Run if the handler terminates the program.
+var ErrTerminated = errors.New("program terminated by the debugger")
+
+// ErrInvalidExpression is returned by Stop.Eval for expressions that don't parse.
+var ErrInvalidExpression = errors.New("invalid expression")
+
+// Action tells the debugger how to resume the paused program.
+type Action int
+
+const (
+	Continue  Action = iota // run until a breakpoint or a pause request
+	StepIn                  // pause at the next statement
+	StepOver                // pause at the next statement of the current function or its callers
+	StepOut                 // pause at the next statement of a caller of the current function
+	Terminate               // abort the program
+)
+
+// Reason tells why the program is paused.
+type Reason string
+
+const (
+	ReasonEntry      Reason = "entry"
+	ReasonBreakpoint Reason = "breakpoint"
+	ReasonStep       Reason = "step"
+	ReasonPause      Reason = "pause"
+)
+
+// Handler is called whenever the program is paused and returns the action
+// resuming it.
+type Handler func(stop *Stop) Action
+
+// Debugger runs a program and pauses it on breakpoints and steps.
+// Breakpoints may be changed and pauses requested from any goroutine.
+type Debugger struct {
+	root    *ast.Root
+	handler Handler
+	config  evaluator.Config
+	ev      *evaluator.Evaluator
+	lines   []int // lines statements start at, in ascending order
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	pause       bool
+
+	// Stepping state, accessed on the program goroutine only.
+	entry     bool // the program is paused at the first statement
+	action    Action
+	depth     int           // call depth the last step started at
+	last      ast.Statement // statement the hook was called for last
+	lastDepth int
+}
+
+// New returns a debugger of the program calling the handler when it's paused.
+func New(root *ast.Root, handler Handler) *Debugger {
+	return NewWithConfig(root, handler, evaluator.Config{})
+}
+
+// NewWithConfig returns a debugger of the program evaluating it with
+// the config, e.g. the build-in functions writing to the output of the program.
+// Expressions evaluated in the paused program use the config as well.
+func NewWithConfig(root *ast.Root, handler Handler, config evaluator.Config) *Debugger {
+	d := &Debugger{
+		root:        root,
+		handler:     handler,
+		config:      config,
+		ev:          evaluator.NewWithConfig(config),
+		breakpoints: make(map[int]bool),
+		action:      Continue,
+	}
+
+	seen := make(map[int]bool)
+	ast.Inspect(root, func(n ast.Node) bool {
+		if stmt, ok := pausable(n); ok && !seen[stmt.Pos().Line] {
+			seen[stmt.Pos().Line] = true
+			d.lines = append(d.lines, stmt.Pos().Line)
+		}
+
+		return true
+	})
+	sort.Ints(d.lines)
+
+	return d
+}
+
+// SetBreakpoints replaces the breakpoints with the given lines. Lines without
+// statements are moved to the next line with one, the lines breakpoints are
+// actually set at are returned in the same order, 0 for the lines after
+// the last statement.
+func (d *Debugger) SetBreakpoints(lines []int) []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = make(map[int]bool)
+	actual := make([]int, len(lines))
+	for i, line := range lines {
+		idx := sort.SearchInts(d.lines, line)
+		if idx == len(d.lines) {
+			continue
+		}
+
+		actual[i] = d.lines[idx]
+		d.breakpoints[d.lines[idx]] = true
+	}
+
+	return actual
+}
+
+// Breakpoints returns the lines breakpoints are set at in ascending order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+// Pause pauses the running program at the next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pause = true
+}
+
+// Run expands macros of the program and evaluates it in the environment,
+// pausing it at the first statement if stopOnEntry is set. The value of the
+// last statement is returned, runtime errors are returned as *object.Error.
+func (d *Debugger) Run(env *object.Environment, stopOnEntry bool) (object.Object, error) {
+	d.entry = stopOnEntry
+
+	macros := object.NewEnvironment()
+	evaluator.DefineMacros(d.root, macros)
+	expanded, errObj := d.ev.ExpandMacros(d.root, macros)
+	if errObj != nil {
+		return errObj, nil
+	}
+
+	terminated := false
+	d.ev.SetHook(func(node ast.Node, env *object.Environment) *object.Error {
+		if terminated || !d.hook(node, env) {
+			terminated = true
+
+			return &object.Error{Message: ErrTerminated.Error()}
+		}
+
+		return nil
+	})
+	defer d.ev.SetHook(nil)
+
+	evaluated := d.ev.Eval(expanded, env)
+	if terminated {
+		return nil, ErrTerminated
+	}
+
+	return evaluated, nil
+}
+
+// hook pauses the program at the statement if it has to, false is returned
+// if the program has to be terminated.
+func (d *Debugger) hook(node ast.Node, env *object.Environment) bool {
+	stmt, ok := pausable(node)
+	if !ok {
+		return true
+	}
+
+	depth := len(d.ev.CallStack())
+	line := stmt.Pos().Line
+
+	// Statements following the previous statement on its line don't hit
+	// breakpoints again, e.g. `let a = 1; let b = 2;`, while statements
+	// evaluated again, e.g. in the next iteration of a loop, do.
+	sameLine := d.last != nil && depth == d.lastDepth &&
+		line == d.last.Pos().Line && stmt.Pos().Offset > d.last.Pos().Offset
+	d.last, d.lastDepth = stmt, depth
+
+	d.mu.Lock()
+	breakpoint := d.breakpoints[line] && !sameLine
+	pause := d.pause
+	d.pause = false
+	d.mu.Unlock()
+
+	var reason Reason
+	switch {
+	case d.entry:
+		reason = ReasonEntry
+		d.entry = false
+	case pause:
+		reason = ReasonPause
+	case breakpoint:
+		reason = ReasonBreakpoint
+	case d.action == StepIn,
+		d.action == StepOver && depth <= d.depth,
+		d.action == StepOut && depth < d.depth:
+		reason = ReasonStep
+	default:
+		return true
+	}
+
+	d.action = d.handler(&Stop{Reason: reason, Node: stmt, Env: env, CallStack: d.ev.CallStack(), config: d.config})
+	d.depth = depth
+
+	return d.action != Terminate
+}
+
+// pausable reports whether the program can be paused before the node.
+// Blocks are skipped, the program is paused at their statements instead.
+func pausable(node ast.Node) (ast.Statement, bool) {
+	stmt, ok := node.(ast.Statement)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := stmt.(*ast.BlockStatement); ok {
+		return nil, false
+	}
+
+	return stmt, true
+}
+
+// Stop describes the paused program. It's valid until the handler returns.
+type Stop struct {
+	Reason    Reason
+	Node      ast.Statement       // statement the program is paused before
+	Env       *object.Environment // environment the statement is evaluated in
+	CallStack []object.Frame      // function calls, innermost first
+
+	config evaluator.Config
+}
+
+// Pos returns the position of the statement the program is paused before.
+func (s *Stop) Pos() token.Position {
+	return s.Node.Pos()
+}
+
+// StackFrame is a function call the paused program is inside of.
+type StackFrame struct {
+	Function string         // name of the function, "main" for the program itself
+	Pos      token.Position // position the function is executing
+}
+
+// Frames returns the stack frames, the innermost one executing the paused
+// statement comes first and the program comes last.
+func (s *Stop) Frames() []StackFrame {
+	frames := make([]StackFrame, 0, len(s.CallStack)+1)
+	pos := s.Pos()
+	for _, call := range s.CallStack {
+		frames = append(frames, StackFrame{Function: functionName(call.Function), Pos: pos})
+		pos = call.Pos
+	}
+
+	return append(frames, StackFrame{Function: "main", Pos: pos})
+}
+
+func functionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+
+	return name
+}
+
+// Scopes returns the environment chain of the paused statement, the innermost
+// environment comes first and the global one comes last.
+func (s *Stop) Scopes() []*object.Environment {
+	var scopes []*object.Environment
+	for env := s.Env; env != nil; env = env.Parent() {
+		scopes = append(scopes, env)
+	}
+
+	return scopes
+}
+
+// Eval evaluates the expression in the environment of the paused statement.
+// Evaluation may change the environment, e.g. with assignments, the debugger
+// doesn't pause inside of it. Runtime errors are returned as *object.Error.
+func (s *Stop) Eval(expression string) (object.Object, error) {
+	p := parser.New(lexer.New(expression))
+	root := p.ParseProgram()
+	if err := p.Errors().Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidExpression, err)
+	}
+
+	evaluated := evaluator.NewWithConfig(s.config).Eval(root, s.Env)
+	if evaluated == nil {
+		evaluated = object.NULL
+	}
+
+	return evaluated, nil
+}
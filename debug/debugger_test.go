@@ -0,0 +1,239 @@
+package debug_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/debug"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = add(x, 3);
+y
+`
+
+func parse(t *testing.T, src string) *ast.Root {
+	t.Helper()
+
+	p := parser.New(lexer.NewWithFilename("test.scr", src))
+	root := p.ParseProgram()
+	if err := p.Errors().Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestDebugger_Stepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		stopOnEntry bool
+		breakpoints []int
+		actions     []debug.Action
+		expected    []string
+	}{
+		{
+			name:        "breakpoints",
+			breakpoints: []int{2},
+			actions:     []debug.Action{debug.Continue, debug.Continue},
+			expected:    []string{"2 breakpoint", "2 breakpoint"},
+		},
+		{
+			name:        "step in",
+			stopOnEntry: true,
+			actions:     []debug.Action{debug.StepIn},
+			expected:    []string{"1 entry", "5 step", "2 step", "3 step", "6 step", "2 step", "3 step", "7 step"},
+		},
+		{
+			name:        "step over",
+			stopOnEntry: true,
+			actions:     []debug.Action{debug.StepOver},
+			expected:    []string{"1 entry", "5 step", "6 step", "7 step"},
+		},
+		{
+			name:        "step over breakpoint",
+			stopOnEntry: true,
+			breakpoints: []int{3},
+			actions:     []debug.Action{debug.StepOver, debug.StepOver, debug.StepOver, debug.Continue},
+			expected:    []string{"1 entry", "5 step", "3 breakpoint", "6 step", "3 breakpoint"},
+		},
+		{
+			name:        "step out",
+			breakpoints: []int{2},
+			actions:     []debug.Action{debug.StepOut, debug.Continue},
+			expected:    []string{"2 breakpoint", "6 step", "2 breakpoint"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var stops []string
+			d := debug.New(parse(t, program), func(stop *debug.Stop) debug.Action {
+				stops = append(stops, fmt.Sprintf("%d %s", stop.Pos().Line, stop.Reason))
+				if len(stops) > 20 {
+					t.Fatal("too many stops")
+				}
+
+				// The last action is repeated.
+				if len(stops) <= len(tt.actions) {
+					return tt.actions[len(stops)-1]
+				}
+
+				return tt.actions[len(tt.actions)-1]
+			})
+			d.SetBreakpoints(tt.breakpoints)
+
+			evaluated, err := d.Run(object.NewEnvironment(), tt.stopOnEntry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evaluated.Inspect() != "6" {
+				t.Errorf("wrong result. expected=6, got=%s", evaluated.Inspect())
+			}
+
+			if !reflect.DeepEqual(stops, tt.expected) {
+				t.Errorf("wrong stops. expected=%q, got=%q", tt.expected, stops)
+			}
+		})
+	}
+}
+
+func TestDebugger_Inspection(t *testing.T) {
+	stops := 0
+	d := debug.New(parse(t, program), func(stop *debug.Stop) debug.Action {
+		// Only the first call of `add` is inspected.
+		stops++
+		if stops > 1 {
+			return debug.Continue
+		}
+
+		frames := make([]string, 0)
+		for _, frame := range stop.Frames() {
+			frames = append(frames, fmt.Sprintf("%s at %s", frame.Function, frame.Pos))
+		}
+		expectedFrames := []string{"add at test.scr:3:3", "main at test.scr:5:9"}
+		if !reflect.DeepEqual(frames, expectedFrames) {
+			t.Errorf("wrong frames. expected=%q, got=%q", expectedFrames, frames)
+		}
+
+		scopes := stop.Scopes()
+		if len(scopes) != 2 {
+			t.Fatalf("wrong number of scopes. expected=2, got=%d", len(scopes))
+		}
+		if names := strings.Join(scopes[0].Names(), " "); names != "a b sum" {
+			t.Errorf("wrong local names. expected=%q, got=%q", "a b sum", names)
+		}
+		if names := strings.Join(scopes[1].Names(), " "); names != "add" {
+			t.Errorf("wrong global names. expected=%q, got=%q", "add", names)
+		}
+
+		evaluated, err := stop.Eval("sum * 10")
+		if err != nil || evaluated.Inspect() != "30" {
+			t.Errorf("wrong evaluation. expected=30, got=%v (%v)", evaluated, err)
+		}
+
+		// Assignments change the paused program.
+		if _, err := stop.Eval("sum = 100"); err != nil {
+			t.Error(err)
+		}
+
+		evaluated, err = stop.Eval("missing")
+		if err != nil || evaluated.Inspect() != "ERROR: 1:1: identifier not found: missing" {
+			t.Errorf("wrong evaluation error. got=%v (%v)", evaluated, err)
+		}
+
+		if _, err := stop.Eval("let"); !errors.Is(err, debug.ErrInvalidExpression) {
+			t.Errorf("wrong error. expected=%v, got=%v", debug.ErrInvalidExpression, err)
+		}
+
+		return debug.Continue
+	})
+
+	if lines := d.SetBreakpoints([]int{3}); !reflect.DeepEqual(lines, []int{3}) {
+		t.Fatalf("wrong breakpoint lines. got=%v", lines)
+	}
+
+	// The first call returns 100 after the assignment.
+	evaluated, err := d.Run(object.NewEnvironment(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stops != 2 {
+		t.Fatalf("wrong number of stops. expected=2, got=%d", stops)
+	}
+	if evaluated.Inspect() != "103" {
+		t.Errorf("wrong result. expected=103, got=%s", evaluated.Inspect())
+	}
+}
+
+func TestDebugger_Breakpoints(t *testing.T) {
+	d := debug.New(parse(t, program), func(*debug.Stop) debug.Action { return debug.Continue })
+
+	lines := d.SetBreakpoints([]int{4, 7, 100})
+	if !reflect.DeepEqual(lines, []int{5, 7, 0}) {
+		t.Errorf("wrong breakpoint lines. expected=[5 7 0], got=%v", lines)
+	}
+	if breakpoints := d.Breakpoints(); !reflect.DeepEqual(breakpoints, []int{5, 7}) {
+		t.Errorf("wrong breakpoints. expected=[5 7], got=%v", breakpoints)
+	}
+}
+
+func TestDebugger_BreakpointsInLoop(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"single statement", "let i = 0;\nwhile (i < 3) {\n  i += 1;\n}\n", []int{0, 1, 2}},
+		{"statements on one line", "let i = 0;\nwhile (i < 3) {\n  i += 1; i;\n}\n", []int{0, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var values []int
+			d := debug.New(parse(t, tt.input), func(stop *debug.Stop) debug.Action {
+				i, _ := stop.Env.Get("i")
+				values = append(values, int(i.(*object.Integer).Value))
+
+				return debug.Continue
+			})
+			d.SetBreakpoints([]int{3})
+
+			if _, err := d.Run(object.NewEnvironment(), false); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tt.expected) {
+				t.Errorf("wrong stops. expected=%v, got=%v", tt.expected, values)
+			}
+		})
+	}
+}
+
+func TestDebugger_PauseAndTerminate(t *testing.T) {
+	var reasons []debug.Reason
+	d := debug.New(parse(t, "let x = 1;\nwhile (true) { x += 1; }\n"), func(stop *debug.Stop) debug.Action {
+		reasons = append(reasons, stop.Reason)
+
+		return debug.Terminate
+	})
+	d.Pause()
+
+	if _, err := d.Run(object.NewEnvironment(), false); !errors.Is(err, debug.ErrTerminated) {
+		t.Errorf("wrong error. expected=%v, got=%v", debug.ErrTerminated, err)
+	}
+	if !reflect.DeepEqual(reasons, []debug.Reason{debug.ReasonPause}) {
+		t.Errorf("wrong stops. got=%v", reasons)
+	}
+}
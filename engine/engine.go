@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/compiler"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/vm"
+)
+
+// Names of the available engines.
+const (
+	EvaluatorName = "eval"
+	VMName        = "vm"
+)
+
+// ErrUnknownEngine is returned when an engine with the given name doesn't exist.
+var ErrUnknownEngine = errors.New("unknown engine")
+
+// Engine executes programs. Global variables defined by a program stay
+// available to the programs run after it, e.g. the following REPL lines.
+type Engine interface {
+	// Run expands macros of the program, executes it and returns the value
+	// of its last statement. Macros stay defined for the following programs.
+	Run(root *ast.Root) object.Object
+	// Define binds the value to the global name.
+	Define(name string, value object.Object)
+}
+
+// New returns new instance of the engine with the given name,
+// `print` of the programs writes to stdout.
+func New(name string, stdout io.Writer) (Engine, error) {
+	builtins := object.BuiltinsWritingTo(stdout)
+
+	switch name {
+	case EvaluatorName:
+		return NewEvaluatorWithConfig(evaluator.Config{Builtins: builtins}), nil
+	case VMName:
+		return NewVMWithBuiltins(builtins), nil
+	default:
+		return nil, fmt.Errorf("%w: %q, want %q or %q", ErrUnknownEngine, name, EvaluatorName, VMName)
+	}
+}
+
+// Evaluator is the engine that walks the AST.
+type Evaluator struct {
+	ev     *evaluator.Evaluator
+	env    *object.Environment
+	macros *object.Environment
+}
+
+// NewEvaluator returns new instance of Evaluator.
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		ev:     evaluator.New(),
+		env:    object.NewEnvironment(),
+		macros: object.NewEnvironment(),
+	}
+}
+
+// NewEvaluatorWithConfig returns new instance of Evaluator evaluating
+// programs with the config.
+func NewEvaluatorWithConfig(config evaluator.Config) *Evaluator {
+	return &Evaluator{
+		ev:     evaluator.NewWithConfig(config),
+		env:    object.NewEnvironment(),
+		macros: object.NewEnvironment(),
+	}
+}
+
+func (e *Evaluator) Run(root *ast.Root) object.Object {
+	root, errObj := expandMacros(e.ev, root, e.macros)
+	if errObj != nil {
+		return errObj
+	}
+
+	return e.ev.Eval(root, e.env)
+}
+
+func (e *Evaluator) Define(name string, value object.Object) {
+	e.env.Set(name, value)
+}
+
+// Reset starts counting the steps and allocations of the following programs
+// over and makes ctx abort them, see evaluator.Evaluator.Reset.
+func (e *Evaluator) Reset(ctx context.Context) {
+	e.ev.Reset(ctx)
+}
+
+// Get returns the value bound to the global name.
+func (e *Evaluator) Get(name string) (object.Object, bool) {
+	return e.env.Get(name)
+}
+
+// VM is the engine that compiles the AST to bytecode and executes it
+// with the virtual machine.
+type VM struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	macros      *object.Environment
+	builtins    map[string]*object.BuildIn
+}
+
+// NewVM returns new instance of VM.
+func NewVM() *VM {
+	return NewVMWithBuiltins(nil)
+}
+
+// NewVMWithBuiltins returns new instance of VM executing programs and
+// expanding macros with the build-in functions, the same way
+// evaluator.Config.Builtins are used.
+func NewVMWithBuiltins(builtins map[string]*object.BuildIn) *VM {
+	return &VM{
+		symbolTable: compiler.NewGlobalSymbolTable(),
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalsSize),
+		macros:      object.NewEnvironment(),
+		builtins:    builtins,
+	}
+}
+
+func (e *VM) Run(root *ast.Root) object.Object {
+	root, errObj := expandMacros(evaluator.NewWithConfig(evaluator.Config{Builtins: e.builtins}), root, e.macros)
+	if errObj != nil {
+		return errObj
+	}
+
+	c := compiler.NewWithState(e.symbolTable, e.constants)
+	if err := c.Compile(root); err != nil {
+		errObj := &object.Error{Message: err.Error()}
+
+		var compileErr *compiler.Error
+		if errors.As(err, &compileErr) {
+			errObj.Message = compileErr.Err.Error()
+			errObj.Pos = compileErr.Pos
+		}
+
+		return errObj
+	}
+
+	bytecode := c.Bytecode()
+	e.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, e.globals)
+	machine.SetBuiltins(e.builtins)
+
+	return machine.Run()
+}
+
+func (e *VM) Define(name string, value object.Object) {
+	symbol := e.symbolTable.Define(name)
+	e.globals[symbol.Index] = value
+}
+
+// expandMacros defines the macros of the program in the environment
+// and expands their calls with the evaluator.
+func expandMacros(ev *evaluator.Evaluator, root *ast.Root, macros *object.Environment) (*ast.Root, *object.Error) {
+	evaluator.DefineMacros(root, macros)
+
+	expanded, errObj := ev.ExpandMacros(root, macros)
+	if errObj != nil {
+		return nil, errObj
+	}
+
+	return expanded.(*ast.Root), nil
+}
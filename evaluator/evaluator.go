@@ -1,242 +1,211 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
-	"math"
 
 	"github.com/dstdfx/scroopy/ast"
 	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/token"
 )
 
-var buildInFuncs = map[string]*object.BuildIn{
-	"print": {
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
-
-			return nil
-		},
-	},
-	// TODO: add set func for hashmaps
-	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			lenArgs := len(args)
-			if lenArgs != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
-			}
-
-			// TODO: add an interface for objects that support len funcs
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			case *object.HashMap:
-				return &object.Integer{Value: int64(len(arg.Pairs))}
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
-			}
-		}},
-	"first": {
-		Fn: func(args ...object.Object) object.Object {
-			lenArgs := len(args)
-			if lenArgs != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
-			}
-
-			if args[0].Type() != object.ArrayObj {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arrayObj := args[0].(*object.Array)
-			if len(arrayObj.Elements) > 0 {
-				return arrayObj.Elements[0]
-			}
-
-			return object.NULL
-		},
-	},
-	"last": {
-		Fn: func(args ...object.Object) object.Object {
-			lenArgs := len(args)
-			if lenArgs != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
-			}
-
-			if args[0].Type() != object.ArrayObj {
-				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arrayObj := args[0].(*object.Array)
-			arrayLength := len(arrayObj.Elements)
-			if arrayLength > 0 {
-				return arrayObj.Elements[arrayLength-1]
-			}
+// Evaluator evaluates AST nodes and keeps track of the evaluation state,
+// such as the stack of function calls.
+type Evaluator struct {
+	frames      []object.Frame
+	hook        Hook
+	config      Config
+	ctx         context.Context // aborts the evaluation, see Reset
+	steps       int
+	allocations int
+}
 
-			return object.NULL
-		},
-	},
-	"rest": {
-		Fn: func(args ...object.Object) object.Object {
-			lenArgs := len(args)
-			if lenArgs != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
-			}
+// Hook is called before every statement and expression is evaluated,
+// with the environment it's evaluated in. Evaluation goes on if the hook
+// returns nil, otherwise the node evaluates to the returned error, which
+// aborts the program the same way runtime errors do.
+type Hook func(node ast.Node, env *object.Environment) *object.Error
 
-			if args[0].Type() != object.ArrayObj {
-				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arrayObj := args[0].(*object.Array)
-			arrayLength := len(arrayObj.Elements)
-			if arrayLength > 0 {
-				newElements := make([]object.Object, arrayLength-1)
-				copy(newElements, arrayObj.Elements[1:arrayLength])
+// New returns new instance of Evaluator.
+func New() *Evaluator {
+	return NewWithConfig(Config{})
+}
 
-				return &object.Array{Elements: newElements}
-			}
+// Eval function evaluates the given node with a new Evaluator and returns
+// it's "objective" representation.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
 
-			return object.NULL
-		},
-	},
-	"push": {
-		Fn: func(args ...object.Object) object.Object {
-			lenArgs := len(args)
-			if lenArgs != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", lenArgs)
-			}
+// SetHook sets the hook called before evaluation of every node,
+// nil removes the hook.
+func (ev *Evaluator) SetHook(hook Hook) {
+	ev.hook = hook
+}
 
-			if args[0].Type() != object.ArrayObj {
-				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
-			}
+// Eval method evaluates the given node and returns it's "objective"
+// representation.
+// Errors produced while evaluating the node are annotated with the position
+// of the innermost node they originate from and the call stack at that moment.
+func (ev *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	var evaluated object.Object
+	if errObj := ev.step(); errObj != nil {
+		evaluated = errObj
+	} else if errObj := ev.callHook(node, env); errObj != nil {
+		evaluated = errObj
+	} else {
+		evaluated = ev.eval(node, env)
+	}
+	if errObj, ok := evaluated.(*object.Error); ok && !errObj.Pos.IsValid() && node != nil {
+		errObj.Pos = node.Pos()
+		errObj.Trace = ev.CallStack()
+	}
 
-			arrayObj := args[0].(*object.Array)
-			arrayLength := len(arrayObj.Elements)
-			newArray := make([]object.Object, arrayLength+1)
-			copy(newArray, arrayObj.Elements)
-			newArray[arrayLength] = args[1]
-
-			return &object.Array{Elements: newArray}
-		},
-	},
-	"delete": {
-		Fn: func(args ...object.Object) object.Object {
-			lenArgs := len(args)
-			if lenArgs != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", lenArgs)
-			}
+	return evaluated
+}
 
-			if args[0].Type() != object.HashObj {
-				return newError("argument to `delete` must be HASHMAP, got %s", args[0].Type())
-			}
+func (ev *Evaluator) callHook(node ast.Node, env *object.Environment) *object.Error {
+	if ev.hook == nil || node == nil {
+		return nil
+	}
 
-			hashable, ok := args[1].(object.Hashable)
-			if !ok {
-				return newError("second argument to `delete` must be HASHABLE, got %s", args[1].Type())
-			}
+	return ev.hook(node, env)
+}
 
-			hmObj := args[0].(*object.HashMap)
-			delete(hmObj.Pairs, hashable.HashKey())
+// CallStack returns a copy of the current call stack, innermost call first.
+func (ev *Evaluator) CallStack() []object.Frame {
+	stack := make([]object.Frame, 0, len(ev.frames))
+	for i := len(ev.frames) - 1; i >= 0; i-- {
+		stack = append(stack, ev.frames[i])
+	}
 
-			return hmObj
-		},
-	},
+	return stack
 }
 
-// Eval function evaluates the given node and returns it's "objective"
-// representation.
-func Eval(node ast.Node, env *object.Environment) object.Object {
+func (ev *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch n := node.(type) {
 	// Statements
 	case *ast.Root:
-		return evalRoot(n, env)
+		return ev.evalRoot(n, env)
 	case *ast.ExpressionStatement:
-		return Eval(n.Expression, env)
+		return ev.Eval(n.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatements(n, env)
+		return ev.evalBlockStatements(n, env)
 	case *ast.ReturnStatement:
-		evaluated := Eval(n.Value, env)
+		evaluated := ev.Eval(n.Value, env)
 		if isError(evaluated) {
 			return evaluated
 		}
 
 		return &object.ReturnValue{Value: evaluated}
 	case *ast.LetStatement:
-		evaluated := Eval(n.Value, env)
+		evaluated := ev.Eval(n.Value, env)
 		if isError(evaluated) {
 			return evaluated
 		}
+		if fn, ok := evaluated.(*object.Function); ok && fn.Name == "" {
+			fn.Name = n.Name.Value
+		}
 		env.Set(n.Name.Value, evaluated)
+	case *ast.AssignStatement:
+		return ev.evalAssignStatement(n, env)
+	case *ast.WhileStatement:
+		return ev.evalWhileStatement(n, env)
+	case *ast.ForStatement:
+		return ev.evalForStatement(n, env)
+	case *ast.BranchStatement:
+		if n.Token.Type == token.BREAK {
+			return object.BREAK
+		}
+
+		return object.CONTINUE
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined with top-level let statements")
 	case *ast.FunctionLiteral:
-		return &object.Function{
+		return ev.allocated(&object.Function{
 			Parameters: n.Parameters,
+			Defaults:   n.Defaults,
+			Rest:       n.Rest,
 			Body:       n.Body,
 			Env:        env,
-		}
+		})
 
 	// Expressions
 	case *ast.IntegerLiteral:
+		if n.Big != nil {
+			return &object.BigInt{Value: n.Big}
+		}
+
 		return &object.Integer{Value: n.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: n.Value}
 	case *ast.BooleanLiteral:
-		return boolToBooleanObject(n.Value)
+		return object.NativeBoolToBooleanObject(n.Value)
 	case *ast.IfExpression:
-		return evalIfExpression(n, env)
+		return ev.evalIfExpression(n, env)
 	case *ast.PrefixExpression:
-		evaluated := Eval(n.Right, env)
+		evaluated := ev.Eval(n.Right, env)
 		if isError(evaluated) {
 			return evaluated
 		}
 
-		return evalPrefixExpression(n.Operator, evaluated)
+		return object.PrefixOperation(n.Operator, evaluated)
 	case *ast.InfixExpression:
-		rightEvaluated := Eval(n.Right, env)
+		if n.Operator == "&&" || n.Operator == "||" {
+			return ev.evalLogicalExpression(n, env)
+		}
+
+		rightEvaluated := ev.Eval(n.Right, env)
 		if isError(rightEvaluated) {
 			return rightEvaluated
 		}
 
-		leftEvaluated := Eval(n.Left, env)
+		leftEvaluated := ev.Eval(n.Left, env)
 		if isError(leftEvaluated) {
 			return leftEvaluated
 		}
 
-		return evalInfixExpression(n.Operator, leftEvaluated, rightEvaluated)
+		return ev.allocated(object.InfixOperation(n.Operator, leftEvaluated, rightEvaluated))
 	case *ast.Identifier:
-		return evalIdentifier(n, env)
+		return ev.evalIdentifier(n, env)
 	case *ast.StringLiteral:
-		return &object.String{Value: n.Value}
+		return ev.allocated(&object.String{Value: n.Value})
 	case *ast.CallExpression:
-		function := Eval(n.Function, env)
+		if object.IsCallOf(n, "quote") {
+			return ev.quote(n, env)
+		}
+
+		function := ev.Eval(n.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(n.Arguments, env)
+		args := ev.evalArguments(n.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return ev.applyFunction(function, args, n.Pos())
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(n.Elements, env)
+		elements := ev.evalExpressions(n.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 
-		return &object.Array{Elements: elements}
+		return ev.allocated(&object.Array{Elements: elements})
 	case *ast.IndexExpression:
-		left := Eval(n.Left, env)
+		left := ev.Eval(n.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(n.Index, env)
+		index := ev.Eval(n.Index, env)
 		if isError(index) {
 			return index
 		}
 
-		return evalIndexExpression(left, index)
+		return object.IndexOperation(left, index)
 	case *ast.HashLiteral:
-		return evalHashMapLiteral(n, env)
+		return ev.allocated(ev.evalHashMapLiteral(n, env))
 	}
 
 	return nil
@@ -250,27 +219,88 @@ func isError(obj object.Object) bool {
 	return obj != nil && obj.Type() == object.ErrorObj
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (ev *Evaluator) applyFunction(fn object.Object, args []object.Object, callPos token.Position) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		required := requiredParameters(fn)
+		if err := object.CheckArity(required, len(fn.Parameters)-required, fn.Rest != nil, len(args)); err != nil {
+			return err
+		}
+
+		if errObj := ev.enter(); errObj != nil {
+			return errObj
+		}
+
+		ev.frames = append(ev.frames, object.Frame{Function: fn.Name, Pos: callPos})
+		defer func() { ev.frames = ev.frames[:len(ev.frames)-1] }()
 
-		return unwrapReturnValue(evaluated)
+		extendedEnv, errObj := ev.extendFunctionEnv(fn, args)
+		if errObj != nil {
+			return errObj
+		}
+		evaluated := ev.Eval(fn.Body, extendedEnv)
+
+		return orNull(unwrapReturnValue(evaluated))
 	case *object.BuildIn:
-		return fn.Fn(args...)
+		return ev.allocated(orNull(fn.Fn(args...)))
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the arguments to the parameters of the function.
+// Default values of the parameters without arguments are evaluated on each call,
+// they see the parameters preceding them.
+func (ev *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+
+			continue
+		}
+
+		value := ev.Eval(fn.Defaults[paramIdx], env)
+		if isError(value) {
+			return nil, value
+		}
+		if value == nil {
+			value = object.NULL
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := make([]object.Object, 0)
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	return env, nil
+}
+
+// requiredParameters returns the number of parameters without default values,
+// only trailing parameters may have them.
+func requiredParameters(fn *object.Function) int {
+	for i, d := range fn.Defaults {
+		if d != nil {
+			return i
+		}
+	}
+
+	return len(fn.Parameters)
+}
+
+// orNull returns NULL in place of nil, which statements without value
+// evaluate to, so that calls and blocks always produce a value.
+func orNull(obj object.Object) object.Object {
+	if obj == nil {
+		return object.NULL
+	}
+
+	return obj
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -281,10 +311,10 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (ev *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	result := make([]object.Object, 0, len(exps))
 	for _, e := range exps {
-		evaluated := Eval(e, env)
+		evaluated := ev.Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -294,147 +324,62 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Value); ok {
-		return val
-	}
-
-	if buildin, ok := buildInFuncs[node.Value]; ok {
-		return buildin
-	}
-
-	return newError("identifier not found: " + node.Value)
-}
+// evalArguments evaluates arguments of a call expanding the spread ones
+// into the elements of the arrays they evaluate to.
+func (ev *Evaluator) evalArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := make([]object.Object, 0, len(exps))
+	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if !ok {
+			evaluated := ev.Eval(e, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			result = append(result, evaluated)
 
-func evalInfixExpression(op string, left, right object.Object) object.Object {
-	switch {
-	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
-		return evalIntegerInfixExpression(op, left, right)
-	case left.Type() == object.StringObj && right.Type() == object.StringObj:
-		return evalStringInfixExpression(op, left, right)
-	case op == "==" || op == "!=":
-		// boolean [infix op] integer
-		if left.Type() == object.IntegerObj && right.Type() == object.BooleanObj {
-			return evalIntegerBooleanInfixExpression(op, left, right)
+			continue
 		}
 
-		// integer [infix op] boolean
-		if left.Type() == object.BooleanObj && right.Type() == object.IntegerObj {
-			return evalIntegerBooleanInfixExpression(op, right, left)
+		evaluated := ev.Eval(spread.Value, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
 		}
 
-		var boolean bool
-		if op == "==" {
-			boolean = left == right
-		} else {
-			boolean = left != right
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			return []object.Object{newError("spread argument must be ARRAY, got %s", typeOf(evaluated))}
 		}
-
-		return boolToBooleanObject(boolean)
-	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
-	}
-}
-
-func evalStringInfixExpression(op string, left, right object.Object) object.Object {
-	leftVal := left.(*object.String)
-	rightVal := right.(*object.String)
-
-	switch op {
-	case "+":
-		return &object.String{Value: leftVal.Value + rightVal.Value}
-	case "==":
-		return &object.Boolean{Value: leftVal.Value == rightVal.Value}
-	case "!=":
-		return &object.Boolean{Value: leftVal.Value != rightVal.Value}
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+		result = append(result, array.Elements...)
 	}
-}
 
-func evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer)
-	rightVal := right.(*object.Integer)
-
-	switch op {
-	case "+":
-		return &object.Integer{Value: leftVal.Value + rightVal.Value}
-	case "-":
-		return &object.Integer{Value: leftVal.Value - rightVal.Value}
-	case "/":
-		return &object.Integer{Value: leftVal.Value / rightVal.Value}
-	case "*":
-		return &object.Integer{Value: leftVal.Value * rightVal.Value}
-	case "**":
-		// TODO: fix me when floats are supported
-		return &object.Integer{Value: int64(math.Pow(float64(leftVal.Value), float64(rightVal.Value)))}
-	case ">":
-		return boolToBooleanObject(leftVal.Value > rightVal.Value)
-	case "<":
-		return boolToBooleanObject(leftVal.Value < rightVal.Value)
-	case "==":
-		return boolToBooleanObject(leftVal.Value == rightVal.Value)
-	case "!=":
-		return boolToBooleanObject(leftVal.Value != rightVal.Value)
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
-	}
+	return result
 }
 
-func evalIntegerBooleanInfixExpression(op string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer)
-	rightVal := right.(*object.Boolean)
-	switch op {
-	case "==":
-		return boolToBooleanObject((leftVal.Value != 0) == rightVal.Value)
-	case "!=":
-		return boolToBooleanObject((leftVal.Value != 0) != rightVal.Value)
-	default:
-		return object.NULL
+func (ev *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
 	}
-}
 
-func evalPrefixExpression(op string, right object.Object) object.Object {
-	switch op {
-	case "!":
-		return evalBangOperatorExpression(right)
-	case "-":
-		return evalMinusPrefixOperatorExpression(right)
-	default:
-		return newError("unknown operator: %s%s", op, right.Type())
+	if buildin := ev.buildIn(node.Value); buildin != nil {
+		return buildin
 	}
-}
 
-func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case object.TRUE:
-		return object.FALSE
-	case object.FALSE:
-		return object.TRUE
-	case object.NULL:
-		return object.TRUE
-	default:
-		return object.FALSE
-	}
+	return newError("identifier not found: " + node.Value)
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.IntegerObj {
-		return newError("unknown operator: -%s", right.Type())
+func (ev *Evaluator) buildIn(name string) *object.BuildIn {
+	if ev.config.Builtins != nil {
+		return ev.config.Builtins[name]
 	}
 
-	return &object.Integer{
-		Value: -right.(*object.Integer).Value,
-	}
+	return object.GetBuildInByName(name)
 }
 
-func evalRoot(root *ast.Root, env *object.Environment) object.Object {
+func (ev *Evaluator) evalRoot(root *ast.Root, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, s := range root.Statements {
-		result = Eval(s, env)
+		result = ev.Eval(s, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -447,15 +392,15 @@ func evalRoot(root *ast.Root, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (ev *Evaluator) evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, s := range block.Statements {
-		result = Eval(s, env)
+		result = ev.Eval(s, env)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.ReturnValueObj || rt == object.ErrorObj {
+			if rt == object.ReturnValueObj || rt == object.ErrorObj || rt == object.BreakObj || rt == object.ContinueObj {
 				break
 			}
 		}
@@ -464,87 +409,164 @@ func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) obj
 	return result
 }
 
-func boolToBooleanObject(input bool) *object.Boolean {
-	if input {
-		return object.TRUE
-	}
-
-	return object.FALSE
-}
-
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condEvaluated := Eval(ie.Condition, env)
+func (ev *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condEvaluated := ev.Eval(ie.Condition, env)
 	if isError(condEvaluated) {
 		return condEvaluated
 	}
 
-	if isTruthy(condEvaluated) {
-		return Eval(ie.Consequence, env)
+	if object.IsTruthy(condEvaluated) {
+		return orNull(ev.Eval(ie.Consequence, env))
 	}
 
 	if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return orNull(ev.Eval(ie.Alternative, env))
 	}
 
 	return object.NULL
 }
 
-func isTruthy(obj object.Object) bool {
-	switch obj {
-	case object.NULL:
-		return false
-	case object.TRUE:
-		return true
-	case object.FALSE:
-		return false
-	default:
-		return true
+// evalLogicalExpression evaluates `&&` and `||` to the operand deciding
+// the result, the right operand is not evaluated if the left one decides it.
+func (ev *Evaluator) evalLogicalExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := ev.Eval(ie.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if object.IsTruthy(left) == (ie.Operator == "||") {
+		return left
 	}
+
+	return ev.Eval(ie.Right, env)
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
-	switch {
-	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
-		return evalArrayIndexExpression(left, index)
-	case left.Type() == object.HashObj:
-		return evalHashIndexExpression(left, index)
+// evalAssignStatement evaluates the value first, then the target,
+// the same way infix expressions evaluate the right operand first.
+func (ev *Evaluator) evalAssignStatement(as *ast.AssignStatement, env *object.Environment) object.Object {
+	value := ev.Eval(as.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	switch target := as.Target.(type) {
+	case *ast.Identifier:
+		if as.Operator != "" {
+			current := ev.Eval(target, env)
+			if isError(current) {
+				return current
+			}
+
+			value = object.InfixOperation(as.Operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		if !env.Assign(target.Value, value) {
+			return newError("identifier not found: " + target.Value)
+		}
+	case *ast.IndexExpression:
+		left := ev.Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := ev.Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		if as.Operator != "" {
+			current := object.IndexOperation(left, index)
+			if isError(current) {
+				return current
+			}
+
+			value = object.InfixOperation(as.Operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		if err := object.SetIndexOperation(left, index, value); err != nil {
+			return err
+		}
 	default:
-		// TODO: check index type and write appropriate error msg
-		return newError("index operator not supported: %s", left.Type())
+		return newError("cannot assign to %s", as.Target)
 	}
+
+	return nil
 }
 
-func evalArrayIndexExpression(array, index object.Object) object.Object {
-	arrayObj := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+func (ev *Evaluator) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condEvaluated := ev.Eval(ws.Condition, env)
+		if isError(condEvaluated) {
+			return condEvaluated
+		}
 
-	if idx < 0 || idx > int64(len(arrayObj.Elements)-1) {
-		return object.NULL
-	}
+		if !object.IsTruthy(condEvaluated) {
+			return nil
+		}
 
-	return arrayObj.Elements[idx]
+		if result, stop := ev.evalLoopBody(ws.Body, env); stop {
+			return result
+		}
+	}
 }
 
-func evalHashIndexExpression(hashmap, index object.Object) object.Object {
-	hmObj := hashmap.(*object.HashMap)
-	key, ok := index.(object.Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+func (ev *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := ev.Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
 	}
 
-	pair, ok := hmObj.Pairs[key.HashKey()]
+	iterator, ok := object.NewIterator(iterable)
 	if !ok {
-		return object.NULL
+		return newError("cannot iterate over %s", iterable.Type())
 	}
 
-	return pair.Value
+	for {
+		if fs.Key != nil {
+			key, value, ok := iterator.NextPair()
+			if !ok {
+				return nil
+			}
+			env.Set(fs.Key.Value, key)
+			env.Set(fs.Value.Value, value)
+		} else {
+			value, ok := iterator.Next()
+			if !ok {
+				return nil
+			}
+			env.Set(fs.Value.Value, value)
+		}
+
+		if result, stop := ev.evalLoopBody(fs.Body, env); stop {
+			return result
+		}
+	}
+}
+
+// evalLoopBody evaluates a single iteration of a loop and reports whether
+// the loop has to stop, along with the result of the loop in that case.
+func (ev *Evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	switch result := ev.Eval(body, env).(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	default:
+		return nil, false
+	}
 }
 
-func evalHashMapLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+func (ev *Evaluator) evalHashMapLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for k, v := range node.Pairs {
-		key := Eval(k, env)
+		key := ev.Eval(k, env)
 		if isError(key) {
 			return key
 		}
@@ -554,7 +576,7 @@ func evalHashMapLiteral(node *ast.HashLiteral, env *object.Environment) object.O
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(v, env)
+		value := ev.Eval(v, env)
 		if isError(value) {
 			return value
 		}
@@ -1,12 +1,18 @@
 package evaluator_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/compiler"
 	"github.com/dstdfx/scroopy/evaluator"
 	"github.com/dstdfx/scroopy/lexer"
 	"github.com/dstdfx/scroopy/object"
 	"github.com/dstdfx/scroopy/parser"
+	"github.com/dstdfx/scroopy/token"
+	"github.com/dstdfx/scroopy/vm"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -32,14 +38,156 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
 		{"2 ** 3", 8},
+		{"3 ** 0", 1},
+		{"(-2) ** 5", -32},
+		{"10 ** 18", 1000000000000000000},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
 
+func TestDivisionAndModulo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Integer division rounds towards negative infinity, the remainder
+		// takes the sign of the divisor, so that a == b * (a / b) + a % b.
+		{"7 / 2", "3"},
+		{"-7 / 2", "-4"},
+		{"7 / -2", "-4"},
+		{"-7 / -2", "3"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "2"},
+		{"7 % -3", "-2"},
+		{"-7 % -3", "-1"},
+		{"6 % 3", "0"},
+		{"-6 / 3", "-2"},
+		{"let a = -17; let b = 5; b * (a / b) + a % b == a", "true"},
+		{"(-(2 ** 64) - 1) / 2", "-9223372036854775809"},
+		{"(-(2 ** 64) - 1) % 2", "1"},
+		{"(2 ** 64 + 1) % -2", "-1"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) % -1", "0"},
+		{"-7.5 % 2", "0.5"},
+		{"7.5 % -2", "-0.5"},
+		{"5 % 2.5", "0.0"},
+		{"let x = 17; x %= 5; x", "2"},
+		{"1 + 2 * 3 % 4", "3"},
+		// Float division follows IEEE 754.
+		{"1 / 0.0", "+Inf"},
+		{"1 / 0", "ERROR: 1:1: division by zero"},
+		{"1 % 0", "ERROR: 1:1: division by zero"},
+		{"(2 ** 64) / 0", "ERROR: 1:2: division by zero"},
+		{"(2 ** 64) % 0", "ERROR: 1:2: division by zero"},
+		{"let x = 1; x /= 0", "ERROR: 1:12: division by zero"},
+		{"let f = fn(n) { 10 / n }; f(0)", "ERROR: 1:17: division by zero"},
+		// Negative exponents produce floats.
+		{"2 ** -1", "0.5"},
+		{"(-2) ** -2", "0.25"},
+		{"0 ** -1", "ERROR: 1:1: division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e-9", 1e-9},
+		{"0.1 + 0.2", 0.30000000000000004},
+		{"1.5 * 4", 6},
+		{"1 + 0.5", 1.5},
+		{"10 / 4.0", 2.5},
+		{"7.5 - 10", -2.5},
+		{"2.0 ** 0.5", 1.4142135623730951},
+		{"2 ** -1", 0.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"4611686018427387904 * 2", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"2 ** 64", "18446744073709551616"},
+		{"3 ** 40", "12157665459056928801"},
+		{"0x1_0000_0000_0000_0000", "18446744073709551616"},
+		{"99999999999999999999 - 99999999999999999998", "1"},
+		{"(2 ** 64) / (2 ** 62)", "4"},
+		{"(2 ** 64) ** -1", "5.421010862427522e-20"},
+		{"2 ** (2 ** 64)", "ERROR: 1:1: result of ** is too large: exceeds 1048576 bits"},
+		{"(2 ** 64) ** 100000", "ERROR: 1:2: result of ** is too large: exceeds 1048576 bits"},
+		{"1 ** (2 ** 64)", "1"},
+		{"(-1) ** (2 ** 64 + 1)", "-1"},
+		{"2 ** 1048576 > 0", "true"},
+		{"2 ** 64 > 9223372036854775807", "true"},
+		{"2 ** 64 == 18446744073709551616", "true"},
+		{"(2 ** 64) * 0.5", "9.223372036854776e+18"},
+		{`let factorial = fn(n) { if (n == 1) { 1 } else { n * factorial(n - 1) } }; factorial(25)`,
+			"15511210043330985984000000"},
+		{"int(1e19)", "10000000000000000000"},
+		{`int("-123456789012345678901234567890")`, "-123456789012345678901234567890"},
+		{"float(2 ** 64)", "1.8446744073709552e+19"},
+		{`let h = {2 ** 64: "big", 1: "one"}; h[18446744073709551616] + h[(2 ** 64) / (2 ** 64)]`, `"bigone"`},
+		{"[1, 2][2 ** 64]", "null"},
+		{"let arr = [1]; arr[2 ** 64] = 1", "ERROR: 1:16: index out of range: 18446744073709551616"},
+		// Hash keys are iterated in the order of their values.
+		{"let s = 0; for (k, v in {2 ** 64: 1, 3: 2, -(2 ** 64): 3}) { s = s * 10 + v }; s", "321"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"0.5 + 0.25", "0.75"},
+		{"1e21", "1e+21"},
+		{"float(3)", "3.0"},
+		{"-0.0", "-0.0"},
+		{"1.0 / 0", "+Inf"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong representation of %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -71,14 +219,83 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{`"abc" == "abc"`, true},
 		{`"abc" != "xabc"`, true},
 		{`"abc" == "xabc"`, false},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1 != 1.0", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1.0 / 0 > 1e308", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"1 <= 0.5", false},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"abc" <= "abc"`, true},
+		{`"ab" >= "abc"`, false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 >= 3", false},
+		{"false && true || true", true},
+		{"true || false && false", true},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBooleanObject(t, evaluated, tt.expected)
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// The result is the operand deciding it.
+		{"1 && 2", 2},
+		{"1 || 2", 1},
+		{"false || 5", 5},
+		{"if (false) { 1 } && 5", nil},
+		// The right operand is not evaluated if the left one decides the result.
+		{"false && 1 + true", false},
+		{"true || 1 + true", true},
+		{"let x = 0; let inc = fn() { x += 1; true }; false && inc(); true || inc(); x", 0},
+		{"let x = 0; let inc = fn() { x += 1; true }; true && inc(); false || inc(); x", 2},
+		{"let f = fn(n) { n > 0 && f(n - 1) || n == 0 }; f(3)", true},
+		{"true && 1 + true", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -93,7 +310,7 @@ func TestBangOperator(t *testing.T) {
 		{"!!!5", false},
 	}
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBooleanObject(t, evaluated, tt.expected)
 	}
 }
@@ -110,10 +327,12 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{`if ("a" == "b") { 10 } else { 20 }`, 20},
+		{`if ("a" != "a") { 10 }`, nil},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -167,7 +386,7 @@ f(10);`,
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
@@ -223,7 +442,7 @@ return 1; }`,
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		errObj, ok := evaluated.(*object.Error)
 		if !ok {
 			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
@@ -238,6 +457,223 @@ return 1; }`,
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "1:1"},
+		{"let x = 1;\nlet y = x + -true;", "2:13"},
+		{"let f = fn(a) {\n  a + foobar\n};\nf(1)", "2:7"},
+		{"len(1)", "1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. expected=%s, got=%s", tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	input := `let divide = fn(a) {
+  a / "zero"
+};
+let apply = fn(f, x) { f(x) };
+let run = fn(g) { g(10) };
+run(fn(y) { apply(divide, y) });`
+
+	ev := evaluator.New()
+	evaluated := ev.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.Frame{
+		{Function: "divide", Pos: token.Position{Offset: 60, Line: 4, Column: 24}},
+		{Function: "apply", Pos: token.Position{Offset: 107, Line: 6, Column: 13}},
+		{Function: "", Pos: token.Position{Offset: 86, Line: 5, Column: 19}},
+		{Function: "run", Pos: token.Position{Offset: 95, Line: 6, Column: 1}},
+	}
+
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. expected=%d, got=%d (%+v)", len(expected), len(errObj.Trace), errObj.Trace)
+	}
+
+	for i, frame := range expected {
+		if errObj.Trace[i] != frame {
+			t.Errorf("wrong frame[%d]. expected=%+v, got=%+v", i, frame, errObj.Trace[i])
+		}
+	}
+
+	expectedTraceback := "\tat divide (called at 4:24)\n" +
+		"\tat apply (called at 6:13)\n" +
+		"\tat <anonymous> (called at 5:19)\n" +
+		"\tat run (called at 6:1)\n"
+	if errObj.Traceback() != expectedTraceback {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expectedTraceback, errObj.Traceback())
+	}
+
+	if len(ev.CallStack()) != 0 {
+		t.Errorf("call stack is not empty after evaluation: %+v", ev.CallStack())
+	}
+
+	// The virtual machine must report the same traceback.
+	testEval(t, input)
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let x = 1; let f = fn() { x = x + 1; }; f(); f(); x", 3},
+		{
+			"let counter = fn() { let c = 0; fn() { c += 1; c } }; let next = counter(); next(); next(); next()",
+			3,
+		},
+		{
+			// Closures sharing the variable see the updates of each other.
+			`let pair = fn() { let n = 0; [fn() { n += 10 }, fn() { n }] };
+let p = pair();
+p[0](); p[0]();
+p[1]()`,
+			20,
+		},
+		{"let f = fn(x) { x = x * 2; x }; f(21)", 42},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr[1] + arr[2]", 23},
+		{"let arr = [1, 2, 3]; arr[2] += 10; arr[2]", 13},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] += 5; h["a"] + h["b"]`, 8},
+		{"let grid = [[0, 0], [0, 0]]; grid[1][0] = 7; grid[1][0]", 7},
+		{"let sum = 0; let i = 0; while (i < 4) { i += 1; sum += i; }; sum", 10},
+		{"x = 1", "identifier not found: x"},
+		{"let f = fn() { y = 1 }; f()", "identifier not found: y"},
+		{"x += 1", "identifier not found: x"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let arr = [1]; arr[1] = 2", "index out of range: 1"},
+		{`let arr = [1]; arr["a"] = 2`, "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[fn() {}] = 1", "unusable as hash key: FUNCTION"},
+		{"let n = 1; n[0] = 1", "index assignment not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+
+				continue
+			}
+
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("object is not String %q. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (false) { let i = i + 1; }; i", 0},
+		{"let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x; }; sum", 6},
+		{"let sum = 0; for (i, x in [10, 20, 30]) { let sum = sum + i * x; }; sum", 80},
+		{`let keys = ""; for (k in {"b": 2, "a": 1, "c": 3}) { let keys = keys + k; }; keys`, "abc"},
+		{`let sum = 0; for (k, v in {"b": 2, "a": 1}) { let sum = sum + v; }; sum`, 3},
+		{`let out = ""; for (c in "héllo") { let out = c + out; }; out`, "olléh"},
+		{`let n = 0; for (i, c in "héllo") { let n = i; }; n`, 4},
+		{"let n = 0; for (x in []) { let n = n + 1; }; n", 0},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", 3},
+		{
+			"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } let sum = sum + x; }; sum",
+			8,
+		},
+		{
+			// break and continue affect the innermost loop only.
+			`let n = 0;
+for (x in [1, 2, 3]) {
+  let i = 0;
+  while (true) {
+    let i = i + 1;
+    if (i > x) { break; }
+    let n = n + 1;
+  }
+  if (x == 2) { continue; }
+  let n = n + 100;
+}
+n`,
+			206,
+		},
+		{
+			"let f = fn(arr) { for (x in arr) { if (x > 1) { return x * 10; } } }; f([1, 2, 3])",
+			20,
+		},
+		{"let f = fn() { let i = 0; while (i < 3) { let i = i + 1; } }; f()", object.NULL},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"let i = 0; while (i < 3) { let i = i + true; }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+
+				continue
+			}
+
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("object is not String %q. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		case *object.Null:
+			if evaluated != object.NULL {
+				t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestLoopClosures(t *testing.T) {
+	// Loops don't introduce scopes, so closures created in every iteration
+	// share the loop variable.
+	input := `let fns = [];
+for (x in [1, 2, 3]) {
+  let fns = push(fns, fn() { x });
+}
+fns[0]() + fns[1]() + fns[2]()`
+
+	testIntegerObject(t, testEval(t, input), 9)
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -249,13 +685,13 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
 	}
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
@@ -266,9 +702,52 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"tab\there\n"`, "tab\there\n"},
+		{`"say \"hi\" \\o/"`, `say "hi" \o/`},
+		{`"caf\u00e9"`, "café"},
+		{`"\U0001F600"`, "😀"},
+		{`len("café")`, 4},
+		{`len("\u00e9")`, 1},
+		{`"café"[3]`, "é"},
+		{`"日本語"[1]`, "本"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+		{`let s = ""; for (i, c in "añb") { s += c + ":" } s`, "a:ñ:b:"},
+		{`let n = 0; for (i, c in "añb") { n += i } n`, 3},
+		{`let größe = "ß"; let 日本 = fn(s) { s + s }; 日本(größe)`, "ßß"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+
+				continue
+			}
+
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
@@ -282,7 +761,7 @@ func TestStringConcatenation(t *testing.T) {
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	fn, ok := evaluated.(*object.Function)
 	if !ok {
 		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
@@ -314,7 +793,54 @@ func TestFunctionApplication(t *testing.T) {
 		{"fn(x) { x; }(5)", 5},
 	}
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestCallsWithoutValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() {}; f()", "null"},
+		{"let f = fn() { let x = 1; }; [f()]", "[null]"},
+		{"let f = fn() {}; f() == 1", "false"},
+		{"let f = fn() { return; }; [f()]", "[null]"},
+		{"let x = print(); [x]", "[null]"},
+		{"[if (true) { let x = 1; }, if (false) { 1 } else { }]", "[null, null]"},
+		{"if (true) { let x = 1; }", "null"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(t, tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDeepCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(2000)", "2000"},
+		{
+			`let a = []; let i = 0; while (i < 3000) { a = push(a, i); i += 1; }
+			let count = fn(...xs) { len(xs) }; count(...a)`,
+			"3000",
+		},
+		{
+			// Variables captured before the stack grows stay shared.
+			`let deep = fn(n) { if (n == 0) { 0 } else { 1 + deep(n - 1) } };
+			let f = fn() { let x = 1; let inc = fn() { x += 1 }; deep(3000); inc(); x }; f()`,
+			"2",
+		},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(t, tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
@@ -325,7 +851,73 @@ func TestClosures(t *testing.T) {
 };
 let addTwo = newAdder(2); addTwo(2);`
 
-	testIntegerObject(t, testEval(input), 4)
+	testIntegerObject(t, testEval(t, input), 4)
+}
+
+func TestNestedClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Closures nested several functions deep see every enclosing scope.
+		{"let g = 1; fn(a) { fn(b) { fn(c) { g + a + b + c } } }(10)(100)(1000)", "1111"},
+		{"let f = fn(a) { fn() { fn() { fn() { a } } } }; f(5)()()()", "5"},
+		{"let x = 1; let f = fn() { let x = 2; fn() { fn() { x } } }; f()()()", "2"},
+		// Recursion through names of the enclosing scopes.
+		{
+			`let outer = fn() {
+				let fact = fn(n) { if (n <= 1) { 1 } else { n * fact(n - 1) } };
+				fn() { fn(n) { fact(n) } }
+			};
+			outer()()(5)`,
+			"120",
+		},
+		{"let count = fn(n) { fn() { fn() { if (n == 0) { 0 } else { 1 + count(n - 1)()() } } } }; count(3)()()", "3"},
+		{"let n = 0; let f = fn() { fn() { fn() { n += 1 } } }; f()()(); f()()(); n", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b) { a }; f(1)", "ERROR: 1:25: wrong number of arguments: want=2, got=1"},
+		{"let f = fn(a) { a }; f(1, 2)", "ERROR: 1:22: wrong number of arguments: want=1, got=2"},
+		{"let f = fn(x, y = 10) { x + y }; f(1)", "11"},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2)", "3"},
+		{"let f = fn(x, y = 10) { x + y }; f()", "ERROR: 1:34: wrong number of arguments: want at least 1, got=0"},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2, 3)", "ERROR: 1:34: wrong number of arguments: want at most 2, got=3"},
+		// Default values are evaluated on each call and see the preceding parameters.
+		{"let f = fn(x, y = x * 2) { y }; f(4)", "8"},
+		{"let f = fn(xs = []) { push(xs, 1) }; f(); f()", "[1]"},
+		{"let f = fn(x = 1 / 0) { x }; f(2)", "2"},
+		{"let f = fn(x = 1 / 0) { x }; f()", "ERROR: 1:16: division by zero"},
+		{"let f = fn(first, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(first, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(first, ...rest) { first }; f()", "ERROR: 1:39: wrong number of arguments: want at least 1, got=0"},
+		{"let f = fn(x, y = 2, ...rest) { [x, y, rest] }; f(1)", "[1, 2, []]"},
+		{"let f = fn(x, y = 2, ...rest) { [x, y, rest] }; f(1, 3, 5, 7)", "[1, 3, [5, 7]]"},
+		{"let f = fn(a, b, c) { a + b + c }; let xs = [2, 3]; f(1, ...xs)", "6"},
+		{"let f = fn(...args) { args }; f(...[1, 2], 3, ...[], ...[4])", "[1, 2, 3, 4]"},
+		{"len(...[[1, 2]])", "2"},
+		{"let f = fn(a, b) { a }; f(...[1])", "ERROR: 1:25: wrong number of arguments: want=2, got=1"},
+		{"let f = fn(a) { a }; f(...1)", "ERROR: 1:22: spread argument must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
 }
 
 func TestBuiltinFunctions(t *testing.T) {
@@ -404,6 +996,16 @@ func TestBuiltinFunctions(t *testing.T) {
 				},
 			},
 		},
+		{`int(3.99)`, 3},
+		{`int(-3.99)`, -3},
+		{`int("42")`, 42},
+		{`int(7)`, 7},
+		{`int("4.2")`, `cannot convert "4.2" to INTEGER`},
+		{`int(1.0 / 0)`, `cannot convert +Inf to INTEGER`},
+		{`int([])`, "argument to `int` not supported, got ARRAY"},
+		{`float([])`, "argument to `float` not supported, got ARRAY"},
+		{`float("abc")`, `cannot convert "abc" to FLOAT`},
+		{`float(1, 2)`, "wrong number of arguments. got=2, want=1"},
 		{
 			`delete({}, "key1")`,
 			&object.HashMap{
@@ -413,7 +1015,7 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
@@ -452,7 +1054,7 @@ func TestBuiltinFunctions(t *testing.T) {
 
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 
 	result, ok := evaluated.(*object.Array)
 	if !ok {
@@ -517,7 +1119,7 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -536,7 +1138,7 @@ two: 1 + 1,
 true: 5,
 false: 6
 }`
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	result, ok := evaluated.(*object.HashMap)
 	if !ok {
 		t.Fatalf("Eval didn't return HashMap. got=%T (%+v)", evaluated, evaluated)
@@ -593,7 +1195,7 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -613,13 +1215,132 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	return true
 }
 
-func testEval(input string) object.Object {
+// testEval evaluates the input with the evaluator and checks that
+// the virtual machine produces the same result.
+func TestHook(t *testing.T) {
+	p := parser.New(lexer.New("let x = 1 + 2;\nlet y = x * 10;\nx + y"))
+	program := p.ParseProgram()
+
+	var visited []string
+	ev := evaluator.New()
+	ev.SetHook(func(node ast.Node, env *object.Environment) *object.Error {
+		if _, ok := node.(ast.Statement); ok {
+			visited = append(visited, fmt.Sprintf("%d:%v", node.Pos().Line, env.Names()))
+		}
+
+		return nil
+	})
+
+	evaluated := ev.Eval(program, object.NewEnvironment())
+	testIntegerObject(t, evaluated, 33)
+
+	expected := "1:[] 2:[x] 3:[x y]"
+	if strings.Join(visited, " ") != expected {
+		t.Errorf("wrong statements visited. expected=%q, got=%q", expected, strings.Join(visited, " "))
+	}
+
+	// The error returned by the hook aborts evaluation.
+	ev.SetHook(func(node ast.Node, env *object.Environment) *object.Error {
+		if node.Pos().Line == 2 {
+			return &object.Error{Message: "aborted"}
+		}
+
+		return nil
+	})
+
+	evaluated = ev.Eval(program, object.NewEnvironment())
+	if evaluated.Inspect() != "ERROR: 2:1: aborted" {
+		t.Errorf("wrong result of the aborted program. got=%s", evaluated.Inspect())
+	}
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
 
-	return evaluator.Eval(program, env)
+	// Macros are expanded before either engine runs the program.
+	macros := object.NewEnvironment()
+	evaluator.DefineMacros(program, macros)
+	if _, errObj := evaluator.ExpandMacros(program, macros); errObj != nil {
+		return errObj
+	}
+
+	evaluated := evaluator.Eval(program, env)
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compilation of %q failed: %s", input, err)
+	}
+	executed := vm.New(c.Bytecode()).Run()
+
+	if !sameObjects(evaluated, executed) {
+		t.Errorf("engines disagree on %q. evaluator=%s, vm=%s", input, describe(evaluated), describe(executed))
+	}
+
+	return evaluated
+}
+
+// sameObjects reports whether the objects produced by the evaluator and
+// the virtual machine are the same. Functions are considered equal since
+// the engines represent them differently.
+func sameObjects(evaluated, executed object.Object) bool {
+	switch evaluated := evaluated.(type) {
+	case *object.Function:
+		_, ok := executed.(*object.Closure)
+
+		return ok
+	case *object.Error:
+		executed, ok := executed.(*object.Error)
+
+		return ok && evaluated.Message == executed.Message &&
+			evaluated.Pos == executed.Pos &&
+			evaluated.Traceback() == executed.Traceback()
+	case *object.Array:
+		executed, ok := executed.(*object.Array)
+		if !ok || len(evaluated.Elements) != len(executed.Elements) {
+			return false
+		}
+
+		for i := range evaluated.Elements {
+			if !sameObjects(evaluated.Elements[i], executed.Elements[i]) {
+				return false
+			}
+		}
+
+		return true
+	case *object.HashMap:
+		executed, ok := executed.(*object.HashMap)
+		if !ok || len(evaluated.Pairs) != len(executed.Pairs) {
+			return false
+		}
+
+		for key, pair := range evaluated.Pairs {
+			executedPair, ok := executed.Pairs[key]
+			if !ok || !sameObjects(pair.Value, executedPair.Value) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return evaluated.Type() == executed.Type() && evaluated.Inspect() == executed.Inspect()
+	}
+}
+
+func describe(obj object.Object) string {
+	if errObj, ok := obj.(*object.Error); ok {
+		return errObj.Inspect() + "\n" + errObj.Traceback()
+	}
+
+	if obj == nil {
+		return "nil"
+	}
+
+	return obj.Inspect()
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -640,6 +1361,24 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -0,0 +1,122 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dstdfx/scroopy/object"
+)
+
+// Errors the evaluation is aborted with when the execution limits are exceeded,
+// they are set as the cause of the returned *object.Error.
+var (
+	ErrCancelled           = errors.New("execution cancelled")
+	ErrTimedOut            = errors.New("execution timed out")
+	ErrStepsExceeded       = errors.New("step limit exceeded")
+	ErrStackDepthExceeded  = errors.New("stack depth exceeded")
+	ErrAllocationsExceeded = errors.New("allocation limit exceeded")
+)
+
+// DefaultMaxDepth is the maximum number of nested function calls unless
+// the config sets another one. It stops runaway recursion well before it
+// overflows the Go stack.
+const DefaultMaxDepth = 10000
+
+// Config configures the evaluation of programs. Limits with zero values
+// mean no limit, except for MaxDepth.
+// Steps and allocations are counted over the lifetime of the Evaluator,
+// so programs evaluated one after another share them until it's reset.
+type Config struct {
+	// Context aborts the evaluation once it's cancelled or its deadline passes.
+	Context context.Context
+	// MaxSteps is the maximum number of evaluated statements and expressions.
+	MaxSteps int
+	// MaxDepth is the maximum number of nested function calls,
+	// DefaultMaxDepth is used if it's zero.
+	MaxDepth int
+	// MaxAllocations is the maximum number of arrays, hashmaps, strings and
+	// functions created by the program, each function call counts as well.
+	MaxAllocations int
+	// Builtins are the build-in functions available to programs by name,
+	// object.Builtins are used if it's nil.
+	Builtins map[string]*object.BuildIn
+}
+
+// NewWithConfig returns new instance of Evaluator limited by the config.
+func NewWithConfig(config Config) *Evaluator {
+	if config.MaxDepth == 0 {
+		config.MaxDepth = DefaultMaxDepth
+	}
+
+	return &Evaluator{config: config, ctx: config.Context}
+}
+
+// Reset starts counting steps and allocations over and makes ctx abort
+// the following evaluations in place of the context of the config,
+// nil means the evaluation isn't aborted by any context.
+func (ev *Evaluator) Reset(ctx context.Context) {
+	ev.steps = 0
+	ev.allocations = 0
+	ev.ctx = ctx
+}
+
+// limitError returns the error aborting the evaluation.
+func limitError(cause error) *object.Error {
+	return &object.Error{Message: cause.Error(), Cause: cause}
+}
+
+// step counts the evaluation of a node and checks the context,
+// the returned error aborts the evaluation.
+func (ev *Evaluator) step() *object.Error {
+	ev.steps++
+	if ev.config.MaxSteps > 0 && ev.steps > ev.config.MaxSteps {
+		return limitError(ErrStepsExceeded)
+	}
+
+	if ev.ctx == nil {
+		return nil
+	}
+
+	select {
+	case <-ev.ctx.Done():
+		if errors.Is(ev.ctx.Err(), context.DeadlineExceeded) {
+			return limitError(ErrTimedOut)
+		}
+
+		return limitError(ErrCancelled)
+	default:
+		return nil
+	}
+}
+
+// enter checks the depth of the function call about to be made.
+func (ev *Evaluator) enter() *object.Error {
+	if len(ev.frames) >= ev.config.MaxDepth {
+		return limitError(ErrStackDepthExceeded)
+	}
+
+	return ev.allocate()
+}
+
+// allocate counts a value created by the program.
+func (ev *Evaluator) allocate() *object.Error {
+	ev.allocations++
+	if ev.config.MaxAllocations > 0 && ev.allocations > ev.config.MaxAllocations {
+		return limitError(ErrAllocationsExceeded)
+	}
+
+	return nil
+}
+
+// allocated counts the object if it's a value the program allocates,
+// the object is returned unless the limit is exceeded.
+func (ev *Evaluator) allocated(obj object.Object) object.Object {
+	switch obj.(type) {
+	case *object.Array, *object.HashMap, *object.String, *object.Function:
+		if errObj := ev.allocate(); errObj != nil {
+			return errObj
+		}
+	}
+
+	return obj
+}
@@ -0,0 +1,163 @@
+package evaluator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+func TestLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelExpired()
+	<-expired.Done()
+
+	tests := []struct {
+		name          string
+		input         string
+		config        evaluator.Config
+		expectedCause error
+		expected      string
+	}{
+		{
+			name:     "within limits",
+			input:    `let f = fn(n) { if (n == 0) { [] } else { push(f(n - 1), "x") } }; len(f(3))`,
+			config:   evaluator.Config{MaxSteps: 1000, MaxDepth: 4, MaxAllocations: 100},
+			expected: "3",
+		},
+		{
+			name:          "cancelled context",
+			input:         "1 + 2",
+			config:        evaluator.Config{Context: cancelled},
+			expectedCause: evaluator.ErrCancelled,
+			expected:      "ERROR: 1:1: execution cancelled",
+		},
+		{
+			name:          "expired context",
+			input:         "1 + 2",
+			config:        evaluator.Config{Context: expired},
+			expectedCause: evaluator.ErrTimedOut,
+			expected:      "ERROR: 1:1: execution timed out",
+		},
+		{
+			name:          "steps",
+			input:         "let x = 0;\nwhile (true) { x += 1; }",
+			config:        evaluator.Config{MaxSteps: 100},
+			expectedCause: evaluator.ErrStepsExceeded,
+			expected:      "step limit exceeded",
+		},
+		{
+			name:          "depth",
+			input:         "let f = fn(n) { f(n + 1) };\nf(0)",
+			config:        evaluator.Config{MaxDepth: 50},
+			expectedCause: evaluator.ErrStackDepthExceeded,
+			expected:      "ERROR: 1:17: stack depth exceeded",
+		},
+		{
+			name:          "allocations",
+			input:         "let a = [];\nwhile (true) { a = push(a, [1]); }",
+			config:        evaluator.Config{MaxAllocations: 10},
+			expectedCause: evaluator.ErrAllocationsExceeded,
+			expected:      "allocation limit exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			program := parser.New(lexer.New(tt.input)).ParseProgram()
+			evaluated := evaluator.NewWithConfig(tt.config).Eval(program, object.NewEnvironment())
+
+			if tt.expectedCause == nil {
+				if evaluated.Inspect() != tt.expected {
+					t.Errorf("wrong result. expected=%s, got=%s", tt.expected, evaluated.Inspect())
+				}
+
+				return
+			}
+
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			}
+			if !errors.Is(errObj.Cause, tt.expectedCause) {
+				t.Errorf("wrong cause. expected=%v, got=%v", tt.expectedCause, errObj.Cause)
+			}
+			// Positions of the loops depend on where the limit trips,
+			// only the message is checked for them.
+			if errObj.Inspect() != tt.expected && errObj.Message != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errObj.Inspect())
+			}
+		})
+	}
+}
+
+func TestLimits_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	program := parser.New(lexer.New("while (true) {}")).ParseProgram()
+	evaluated := evaluator.NewWithConfig(evaluator.Config{Context: ctx}).Eval(program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || !errors.Is(errObj.Cause, evaluator.ErrTimedOut) {
+		t.Errorf("expected the loop to time out. got=%v", evaluated)
+	}
+}
+
+func TestLimits_Macros(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		name          string
+		config        evaluator.Config
+		expectedCause error
+	}{
+		{"timeout", evaluator.Config{Context: ctx}, evaluator.ErrTimedOut},
+		{"steps", evaluator.Config{MaxSteps: 1000}, evaluator.ErrStepsExceeded},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			program := parser.New(lexer.New("let m = macro() { while (true) { } };\nm();")).ParseProgram()
+			macros := object.NewEnvironment()
+			evaluator.DefineMacros(program, macros)
+
+			_, errObj := evaluator.NewWithConfig(tt.config).ExpandMacros(program, macros)
+			if errObj == nil || !errors.Is(errObj.Cause, tt.expectedCause) {
+				t.Errorf("expected the macro to be aborted with %v. got=%v", tt.expectedCause, errObj)
+			}
+		})
+	}
+}
+
+func TestLimits_Reset(t *testing.T) {
+	ev := evaluator.NewWithConfig(evaluator.Config{MaxSteps: 100})
+	program := parser.New(lexer.New("let i = 0; while (i < 5) { i += 1; }; i")).ParseProgram()
+
+	for run := 0; run < 10; run++ {
+		ev.Reset(nil)
+		if evaluated := ev.Eval(program, object.NewEnvironment()); evaluated.Inspect() != "5" {
+			t.Fatalf("run %d: wrong result. expected=5, got=%s", run, evaluated.Inspect())
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ev.Reset(ctx)
+
+	evaluated := ev.Eval(program, object.NewEnvironment())
+	if errObj, ok := evaluated.(*object.Error); !ok || !errors.Is(errObj.Cause, evaluator.ErrCancelled) {
+		t.Errorf("expected the evaluation to be cancelled. got=%v", evaluated)
+	}
+}
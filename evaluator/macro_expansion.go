@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/object"
+)
+
+// DefineMacros binds macros defined with top-level `let` statements
+// in the environment and removes their definitions from the program.
+func DefineMacros(program *ast.Root, env *object.Environment) {
+	statements := program.Statements[:0]
+	for _, stmt := range program.Statements {
+		letStmt, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, stmt)
+
+			continue
+		}
+
+		macro, ok := letStmt.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, stmt)
+
+			continue
+		}
+
+		env.Set(letStmt.Name.Value, &object.Macro{
+			Parameters: macro.Parameters,
+			Body:       macro.Body,
+			Env:        env,
+		})
+	}
+	program.Statements = statements
+}
+
+// ExpandMacros function expands the macros of the program with a new Evaluator.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return New().ExpandMacros(program, env)
+}
+
+// ExpandMacros method replaces calls of the macros defined in the environment
+// with the nodes quoted by the macros. The macro body is evaluated by the
+// Evaluator, within its limits, with the arguments of the call bound to its
+// parameters as quotes.
+// The program is expanded in place, the first error raised by a macro
+// stops the expansion and is returned.
+func (ev *Evaluator) ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var errObj *object.Error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || errObj != nil {
+			return node
+		}
+
+		macro, ok := lookupMacro(call, env)
+		if !ok {
+			return node
+		}
+
+		if errObj = object.CheckArity(len(macro.Parameters), 0, false, len(call.Arguments)); errObj != nil {
+			errObj.Pos = call.Pos()
+
+			return node
+		}
+
+		macroEnv := object.NewEnclosedEnvironment(macro.Env)
+		for i, param := range macro.Parameters {
+			macroEnv.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+		}
+
+		ev.frames = append(ev.frames, object.Frame{Function: call.Function.String(), Pos: call.Pos()})
+		evaluated := unwrapReturnValue(ev.Eval(macro.Body, macroEnv))
+		ev.frames = ev.frames[:len(ev.frames)-1]
+		switch evaluated := evaluated.(type) {
+		case *object.Quote:
+			return evaluated.Node
+		case *object.Error:
+			errObj = evaluated
+		default:
+			errObj = newError("macro must return QUOTE, got %s", typeOf(evaluated))
+			errObj.Pos = call.Pos()
+		}
+
+		return node
+	})
+	if errObj != nil {
+		return nil, errObj
+	}
+
+	return expanded, nil
+}
+
+func lookupMacro(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+
+	return macro, ok
+}
@@ -0,0 +1,173 @@
+package evaluator_test
+
+import (
+	"testing"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(t, input)
+
+	evaluator.DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("wrong macro parameters. got=%v", macro.Parameters)
+	}
+
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q. got=%q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, print("not greater"), print("greater"));`,
+			`if (!(10 > 5)) { print("not greater") } else { print("greater") }`,
+		},
+		{
+			`let twice = macro(x) { quote([unquote(x), unquote(x)]) };
+			let f = fn() { twice(1 + 1) };`,
+			`let f = fn() { [1 + 1, 1 + 1] };`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(t, tt.expected)
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		evaluator.DefineMacros(program, env)
+		expanded, errObj := evaluator.ExpandMacros(program, env)
+		if errObj != nil {
+			t.Fatalf("%q: unexpected error: %s", tt.input, errObj.Inspect())
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let m = macro(a) { quote(unquote(a)) };\nm(1, 2)",
+			"ERROR: 2:1: wrong number of arguments: want=1, got=2",
+		},
+		{
+			"let m = macro() { 1 };\nm()",
+			"ERROR: 2:1: macro must return QUOTE, got INTEGER",
+		},
+		{
+			"let m = macro() { 1 / 0 };\nm()",
+			"ERROR: 1:19: division by zero\tat m (called at 2:1)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(t, tt.input).(*object.Error)
+		if !ok {
+			t.Fatalf("%q: expected error", tt.input)
+		}
+
+		if errObj.Inspect()+errObj.Traceback() != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%q", tt.input, tt.expected, errObj.Inspect()+errObj.Traceback())
+		}
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };
+			unless(10 > 5, "not greater", "greater")`,
+			`"greater"`,
+		},
+		{
+			`let assert = macro(cond) { quote(if (!(unquote(cond))) { "assertion failed" } else { true }) };
+			let x = 2; [assert(x == 2), assert(x == 3)]`,
+			`[true, "assertion failed"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func testParseProgram(t *testing.T, input string) *ast.Root {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return program
+}
@@ -0,0 +1,36 @@
+package evaluator
+
+import (
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/object"
+)
+
+// quote returns a copy of the argument of the `quote` call unevaluated,
+// except for the `unquote` calls inside of it, which are replaced with
+// the nodes representing the values of their arguments.
+func (ev *Evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	values := make([]object.Object, 0)
+	if len(call.Arguments) == 1 {
+		for _, unquote := range object.UnquoteCalls(call.Arguments[0]) {
+			if len(unquote.Arguments) != 1 {
+				break
+			}
+
+			evaluated := ev.Eval(unquote.Arguments[0], env)
+			if isError(evaluated) {
+				return evaluated
+			}
+			values = append(values, evaluated)
+		}
+	}
+
+	return object.QuoteCall(call, values)
+}
+
+func typeOf(obj object.Object) object.Type {
+	if obj == nil {
+		return object.NullObj
+	}
+
+	return obj.Type()
+}
@@ -0,0 +1,88 @@
+package evaluator_test
+
+import (
+	"testing"
+
+	"github.com/dstdfx/scroopy/object"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(1.5 * 2))`, `3.0`},
+		{`quote(unquote(2 ** 64))`, `18446744073709551616`},
+		{`quote(unquote("a" + "b"))`, `ab`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))`, `(8 + (4 + 4))`},
+		{`quote(f(unquote(1 + 1), [unquote(2 + 2)]))`, `f(2, [4])`},
+		{`let f = fn(x) { let y = 3; quote(unquote(x) + unquote(y)) }; f(2)`, `(2 + 3)`},
+		{`let x = 1; [quote(unquote(x)), quote(unquote(x + 1))][1]`, `2`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "ERROR: 1:1: wrong number of arguments. got=2, want=1"},
+		{`quote(unquote(1, 2))`, "ERROR: 1:1: wrong number of arguments. got=2, want=1"},
+		{`quote(unquote(1 / 0))`, "ERROR: 1:15: division by zero"},
+		{`quote(unquote([1]))`, "ERROR: 1:1: cannot unquote ARRAY"},
+		{`let f = fn() { quote(unquote(g())) }; let g = fn() { 1 / 0 }; f()`, "ERROR: 1:54: division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func testQuoteObject(t *testing.T, obj object.Object, expected string) {
+	t.Helper()
+
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote. got=%T (%+v)", obj, obj)
+	}
+
+	if quote.Node == nil {
+		t.Fatalf("quote.Node is nil")
+	}
+
+	if quote.Node.String() != expected {
+		t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), expected)
+	}
+}
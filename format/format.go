@@ -0,0 +1,421 @@
+// Package format implements the canonical formatting of Scroopy source code.
+//
+// Statements are placed one per line and indented by two spaces per block,
+// simple statements end with a semicolon, operators are surrounded by
+// single spaces and parentheses are kept only where precedence requires them.
+// Comments are preserved, a single blank line between statements is kept.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/parser"
+	"github.com/dstdfx/scroopy/token"
+)
+
+const indentation = "  "
+
+// ErrUnsupportedNode is returned for nodes that can't be formatted on their own.
+var ErrUnsupportedNode = errors.New("unsupported node")
+
+// Source parses the source code and returns it formatted. The error
+// is a parser.ErrorList if the source code has syntax errors.
+func Source(filename string, src []byte) ([]byte, error) {
+	p := parser.New(lexer.NewWithMode(filename, string(src), lexer.ScanComments))
+	root := p.ParseProgram()
+	if err := p.Errors().Err(); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Node(buf, root); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Node writes the node formatted to w. Comments are printed for the root
+// only, they're taken from Root.Comments.
+func Node(w io.Writer, node ast.Node) error {
+	p := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Root:
+		p.comments = node.Comments
+		p.statements(node.Statements, token.Position{Offset: -1})
+		if p.buf.Len() > 0 {
+			p.buf.WriteByte('\n')
+		}
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expression(node, parser.LOWEST)
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedNode, node)
+	}
+
+	_, err := w.Write(p.buf.Bytes())
+
+	return err
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	indent   int
+	comments []*ast.Comment // comments not printed yet, in the source order
+	lastLine int            // source line the last printed statement or comment ends at
+}
+
+// statements prints the statements one per line followed by the comments
+// found before end, comments are printed up to the end of the source
+// if end is invalid.
+func (p *printer) statements(stmts []ast.Statement, end token.Position) {
+	first := true
+	for _, stmt := range stmts {
+		p.leadingComments(stmt.Pos(), &first)
+		p.newline(stmt.Pos().Line, first)
+		first = false
+
+		p.statement(stmt)
+		p.lastLine = stmt.End().Line
+		p.trailingComment(end)
+	}
+
+	p.leadingComments(end, &first)
+}
+
+// leadingComments prints the comments found before the position on their own lines.
+func (p *printer) leadingComments(pos token.Position, first *bool) {
+	for len(p.comments) > 0 && (pos.Offset < 0 || p.comments[0].Pos().Offset < pos.Offset) {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+
+		p.newline(comment.Pos().Line, *first)
+		*first = false
+
+		p.buf.WriteString(commentText(comment))
+		if comment.End().Line > p.lastLine {
+			p.lastLine = comment.End().Line
+		}
+	}
+}
+
+// trailingComment prints the comment found before end and starting
+// at the line the last statement ends at on the same line.
+func (p *printer) trailingComment(end token.Position) {
+	if len(p.comments) == 0 || p.comments[0].Pos().Line != p.lastLine {
+		return
+	}
+	if end.Offset >= 0 && p.comments[0].Pos().Offset >= end.Offset {
+		return
+	}
+
+	comment := p.comments[0]
+	p.comments = p.comments[1:]
+
+	p.buf.WriteByte(' ')
+	p.buf.WriteString(commentText(comment))
+	p.lastLine = comment.End().Line
+}
+
+// newline starts a new indented line, a blank line is inserted before it
+// if there's one in the source before the given line.
+func (p *printer) newline(line int, first bool) {
+	if p.buf.Len() > 0 {
+		p.buf.WriteByte('\n')
+		if !first && line > p.lastLine+1 {
+			p.buf.WriteByte('\n')
+		}
+	}
+	p.buf.WriteString(strings.Repeat(indentation, p.indent))
+}
+
+func commentText(comment *ast.Comment) string {
+	return strings.TrimRightFunc(comment.Token.Literal, unicode.IsSpace)
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.buf.WriteString("let ")
+		p.buf.WriteString(stmt.Name.Value)
+		p.buf.WriteString(" = ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.buf.WriteByte(';')
+	case *ast.ReturnStatement:
+		p.buf.WriteString("return")
+		if stmt.Value != nil {
+			p.buf.WriteByte(' ')
+			p.expression(stmt.Value, parser.LOWEST)
+		}
+		p.buf.WriteByte(';')
+	case *ast.AssignStatement:
+		p.expression(stmt.Target, parser.LOWEST)
+		p.buf.WriteString(" " + stmt.Operator + "= ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.buf.WriteByte(';')
+	case *ast.BranchStatement:
+		p.buf.WriteString(stmt.Token.Literal)
+		p.buf.WriteByte(';')
+	case *ast.WhileStatement:
+		p.buf.WriteString("while (")
+		p.expression(stmt.Condition, parser.LOWEST)
+		p.buf.WriteString(") ")
+		p.block(stmt.Body)
+	case *ast.ForStatement:
+		p.buf.WriteString("for (")
+		if stmt.Key != nil {
+			p.buf.WriteString(stmt.Key.Value + ", ")
+		}
+		p.buf.WriteString(stmt.Value.Value + " in ")
+		p.expression(stmt.Iterable, parser.LOWEST)
+		p.buf.WriteString(") ")
+		p.block(stmt.Body)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+		p.buf.WriteByte(';')
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && !p.hasCommentBefore(block.Rbrace) {
+		p.buf.WriteString("{}")
+
+		return
+	}
+
+	p.buf.WriteByte('{')
+	p.indent++
+	p.lastLine = block.Pos().Line
+	p.statements(block.Statements, block.Rbrace)
+	p.indent--
+	p.newline(block.Rbrace.Line, true)
+	p.buf.WriteByte('}')
+	p.lastLine = block.Rbrace.Line
+}
+
+func (p *printer) hasCommentBefore(pos token.Position) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos().Offset < pos.Offset
+}
+
+// expression prints the expression, parenthesized if it binds weaker
+// than the given precedence.
+func (p *printer) expression(expr ast.Expression, precedence int) {
+	if exprPrecedence(expr) < precedence {
+		p.buf.WriteByte('(')
+		p.expression(expr, parser.LOWEST)
+		p.buf.WriteByte(')')
+
+		return
+	}
+
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		p.buf.WriteString(expr.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.BooleanLiteral:
+		p.buf.WriteString(expr.TokenLiteral())
+	case *ast.StringLiteral:
+		p.buf.WriteString(quote(expr.Value))
+	case *ast.PrefixExpression:
+		p.buf.WriteString(expr.Operator)
+		// The operator repeated without a space reads as another one, e.g. `--`.
+		if right, ok := expr.Right.(*ast.PrefixExpression); ok && right.Operator == expr.Operator {
+			p.buf.WriteByte('(')
+			p.expression(right, parser.LOWEST)
+			p.buf.WriteByte(')')
+
+			break
+		}
+		p.expression(expr.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		// Operators are left-associative, the right operand of the same
+		// precedence has to be parenthesized.
+		operatorPrecedence := parser.Precedence(token.Type(expr.Operator))
+		p.expression(expr.Left, operatorPrecedence)
+		p.buf.WriteString(" " + expr.Operator + " ")
+		p.expression(expr.Right, operatorPrecedence+1)
+	case *ast.IfExpression:
+		p.buf.WriteString("if (")
+		p.expression(expr.Condition, parser.LOWEST)
+		p.buf.WriteString(") ")
+		p.block(expr.Consequence)
+		if expr.Alternative != nil {
+			p.buf.WriteString(" else ")
+			p.block(expr.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.buf.WriteString("fn(")
+		for i, param := range expr.Parameters {
+			if i > 0 {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString(param.Value)
+			if expr.Defaults != nil && expr.Defaults[i] != nil {
+				p.buf.WriteString(" = ")
+				p.expression(expr.Defaults[i], parser.LOWEST)
+			}
+		}
+		if expr.Rest != nil {
+			if len(expr.Parameters) > 0 {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString("..." + expr.Rest.Value)
+		}
+		p.buf.WriteString(") ")
+		p.block(expr.Body)
+	case *ast.MacroLiteral:
+		p.buf.WriteString("macro(")
+		for i, param := range expr.Parameters {
+			if i > 0 {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString(param.Value)
+		}
+		p.buf.WriteString(") ")
+		p.block(expr.Body)
+	case *ast.CallExpression:
+		p.expression(expr.Function, parser.CALL)
+		p.list("(", ")", p.expressionItems(expr.Arguments), expr.Token.Pos, expr.Rparen)
+	case *ast.SpreadExpression:
+		p.buf.WriteString("...")
+		p.expression(expr.Value, parser.LOWEST)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", p.expressionItems(expr.Elements), expr.Token.Pos, expr.Rbracket)
+	case *ast.IndexExpression:
+		p.expression(expr.Left, parser.INDEX)
+		p.buf.WriteByte('[')
+		p.expression(expr.Index, parser.LOWEST)
+		p.buf.WriteByte(']')
+	case *ast.HashLiteral:
+		items := make([]listItem, 0, len(expr.Pairs))
+		for _, key := range expr.SortedKeys() {
+			key, value := key, expr.Pairs[key]
+			items = append(items, listItem{pos: key.Pos(), end: value.End(), print: func() {
+				p.expression(key, parser.LOWEST)
+				p.buf.WriteString(": ")
+				p.expression(value, parser.LOWEST)
+			}})
+		}
+		p.list("{", "}", items, expr.Token.Pos, expr.Rbrace)
+	}
+}
+
+// listItem is an element of a list printed by printer.list,
+// pairs of hash literals span from the key to the value.
+type listItem struct {
+	pos, end token.Position
+	print    func()
+}
+
+func (p *printer) expressionItems(exprs []ast.Expression) []listItem {
+	items := make([]listItem, 0, len(exprs))
+	for _, expr := range exprs {
+		expr := expr
+		items = append(items, listItem{pos: expr.Pos(), end: expr.End(), print: func() {
+			p.expression(expr, parser.LOWEST)
+		}})
+	}
+
+	return items
+}
+
+// list prints the items separated by commas between the brackets, which are
+// found at start and end in the source. Lists with comments inside are printed
+// one item per line, so the comments stay next to the items they describe.
+func (p *printer) list(open, close string, items []listItem, start, end token.Position) {
+	p.buf.WriteString(open)
+	if !p.hasCommentBefore(end) {
+		for i, item := range items {
+			if i > 0 {
+				p.buf.WriteString(", ")
+			}
+			item.print()
+		}
+		p.buf.WriteString(close)
+
+		return
+	}
+
+	p.indent++
+	p.lastLine = start.Line
+	first := true
+	for i, item := range items {
+		p.leadingComments(item.pos, &first)
+		p.newline(item.pos.Line, first)
+		first = false
+
+		item.print()
+		// Comments after the start of the next item belong to it,
+		// e.g. to an element of a nested list.
+		next := end
+		if i < len(items)-1 {
+			p.buf.WriteByte(',')
+			next = items[i+1].pos
+		}
+		p.lastLine = item.end.Line
+		p.trailingComment(next)
+	}
+	p.leadingComments(end, &first)
+	p.indent--
+	p.newline(end.Line, true)
+	p.buf.WriteString(close)
+	p.lastLine = end.Line
+}
+
+// exprPrecedence returns how tight the expression binds its operands,
+// expressions that don't have operands bind the tightest.
+func exprPrecedence(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.Type(expr.Operator))
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	default:
+		return parser.INDEX
+	}
+}
+
+// escapes maps characters to the escape sequences the lexer reads them from.
+var escapes = map[rune]string{
+	'\n': `\n`,
+	'\t': `\t`,
+	'\r': `\r`,
+	0:    `\0`,
+	'\\': `\\`,
+	'"':  `\"`,
+}
+
+// quote returns the string literal reading to the given value.
+func quote(value string) string {
+	strBuilder := strings.Builder{}
+	strBuilder.WriteByte('"')
+
+	for _, r := range value {
+		switch escape, ok := escapes[r]; {
+		case ok:
+			strBuilder.WriteString(escape)
+		case !unicode.IsPrint(r) && r > 0xFFFF:
+			fmt.Fprintf(&strBuilder, `\U%08X`, r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&strBuilder, `\u%04X`, r)
+		default:
+			strBuilder.WriteRune(r)
+		}
+	}
+
+	strBuilder.WriteByte('"')
+
+	return strBuilder.String()
+}
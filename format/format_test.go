@@ -0,0 +1,214 @@
+package format_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/format"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "spacing and semicolons",
+			input:    "let   x=1+2*3\nx+=1\nprint(x,[1,2],{\"a\":1})",
+			expected: "let x = 1 + 2 * 3;\nx += 1;\nprint(x, [1, 2], {\"a\": 1});\n",
+		},
+		{
+			name:     "parentheses",
+			input:    "(1 + 2) * 3; 1 - (2 - 3); (1 - 2) - 3; -(2 ** 3); !(a == b); (a && b) || c; a && (b || c)",
+			expected: "(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n-(2 ** 3);\n!(a == b);\na && b || c;\na && (b || c);\n",
+		},
+		{
+			name:     "repeated prefix operators",
+			input:    "-(-1); - -x; !(!a); -!a; 1 - -2",
+			expected: "-(-1);\n-(-x);\n!(!a);\n-!a;\n1 - -2;\n",
+		},
+		{
+			name: "comments inside lists",
+			input: "let q = [1,\n 2, // two\n 3];\nf(a, // first\n\n  // last\n  b)\n" +
+				"let h = {\"b\": 2, // b\n\"a\": 1};\n[ // none\n]",
+			expected: "let q = [\n  1,\n  2, // two\n  3\n];\nf(\n  a, // first\n\n  // last\n  b\n);\n" +
+				"let h = {\n  \"b\": 2, // b\n  \"a\": 1\n};\n[\n  // none\n];\n",
+		},
+		{
+			name:  "comments inside nested lists",
+			input: "print(f(1, g(2, // c\n 3)));\nlet h = {\"b\":1,\"a\":[1,2, // c1\n3]}",
+			expected: "print(\n  f(\n    1,\n    g(\n      2, // c\n      3\n    )\n  )\n);\n" +
+				"let h = {\n  \"b\": 1,\n  \"a\": [\n    1,\n    2, // c1\n    3\n  ]\n};\n",
+		},
+		{
+			name:     "calls and indexes",
+			input:    "(fn(x){x})(1); (a + b)[0]; f(...xs, 1)(2); a[b[0]]",
+			expected: "fn(x) {\n  x;\n}(1);\n(a + b)[0];\nf(...xs, 1)(2);\na[b[0]];\n",
+		},
+		{
+			name:  "blocks",
+			input: "let f=fn(a,b=2,...rest){if(a>b){return a}else{return b}}\nwhile(true){break}\nfor(k,v in h){continue}",
+			expected: `let f = fn(a, b = 2, ...rest) {
+  if (a > b) {
+    return a;
+  } else {
+    return b;
+  };
+};
+while (true) {
+  break;
+}
+for (k, v in h) {
+  continue;
+}
+`,
+		},
+		{
+			name:     "empty blocks",
+			input:    "let f = fn() {  }; while (x) {}",
+			expected: "let f = fn() {};\nwhile (x) {}\n",
+		},
+		{
+			name:     "literals",
+			input:    `let s = "tab\there \"q\" \u00e9 \u0001"; let n = 0x_FF + 1_000 + 1.5e3 + 99999999999999999999;`,
+			expected: "let s = \"tab\\there \\\"q\\\" é \\u0001\";\nlet n = 0x_FF + 1_000 + 1.5e3 + 99999999999999999999;\n",
+		},
+		{
+			name:     "macros",
+			input:    "let m = macro(a, b) { quote(unquote(a) + unquote(b)) }",
+			expected: "let m = macro(a, b) {\n  quote(unquote(a) + unquote(b));\n};\n",
+		},
+		{
+			name: "comments",
+			input: `// Header.
+
+let x = 1;   // trailing
+/* block
+   comment */
+let f = fn() {
+    // inside
+
+
+    x
+};
+let g = fn() { /* empty */ };
+// end
+`,
+			expected: `// Header.
+
+let x = 1; // trailing
+/* block
+   comment */
+let f = fn() {
+  // inside
+
+  x;
+};
+let g = fn() {
+  /* empty */
+};
+// end
+`,
+		},
+		{
+			name:     "blank lines",
+			input:    "\n\nlet a = 1;\n\n\n\nlet b = 2;\nlet c = 3;\n\n",
+			expected: "let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			name:     "empty",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := format.Source("", []byte(tt.input))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		if string(formatted) != tt.expected {
+			t.Errorf("%s: wrong result.\nwant=%q\ngot= %q", tt.name, tt.expected, formatted)
+		}
+
+		// Formatting is idempotent.
+		again, err := format.Source("", formatted)
+		if err != nil {
+			t.Fatalf("%s: formatted source doesn't parse: %s", tt.name, err)
+		}
+		if !bytes.Equal(again, formatted) {
+			t.Errorf("%s: formatting is not idempotent.\nfirst= %q\nagain= %q", tt.name, formatted, again)
+		}
+	}
+}
+
+func TestSource_KeepsMeaning(t *testing.T) {
+	inputs := []string{
+		"1 + 2 * 3 - 4 / 2 % 3 ** 2",
+		"-2 ** 2 + -(2 ** 2)",
+		"-(-(-1)) + !(!true)",
+		"!true == false || 1 < 2 && 2 >= 1",
+		"fn(x, y = x * 2, ...r) { [x, y, r] }(1, ...[2, 3])",
+		"let h = {\"k\": [1, 2]}; h[\"k\"][1]",
+		"if (a) { b } else { c }[0]",
+		"let q = [1, // one\n 2]; f(x, // x\n y)[0]",
+		"print(f(1, g(2, // c\n 3)))",
+	}
+
+	for _, input := range inputs {
+		formatted, err := format.Source("", []byte(input))
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+
+		if got, want := parse(t, string(formatted)).String(), parse(t, input).String(); got != want {
+			t.Errorf("%q: formatting changed the program.\nwant=%s\ngot= %s", input, want, got)
+		}
+	}
+}
+
+func TestSource_SyntaxErrors(t *testing.T) {
+	_, err := format.Source("script.scr", []byte("let x 1;"))
+
+	var errList parser.ErrorList
+	if !errors.As(err, &errList) {
+		t.Fatalf("expected parser.ErrorList, got=%T (%v)", err, err)
+	}
+
+	if errList.Error() != "script.scr:1:7: expected next token to be '=', got 'INT' instead" {
+		t.Errorf("wrong error. got=%q", errList.Error())
+	}
+}
+
+func TestNode(t *testing.T) {
+	p := parser.New(lexer.New("fn(x) { x * (1 + 2) }"))
+	root := p.ParseProgram()
+	expr := root.Statements[0].(*ast.ExpressionStatement).Expression
+
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, expr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if buf.String() != "fn(x) {\n  x * (1 + 2);\n}" {
+		t.Errorf("wrong result. got=%q", buf.String())
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Root {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	root := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return root
+}
@@ -0,0 +1,70 @@
+// Package frame reads and writes messages framed with the Content-Length
+// header, the way the Language Server and Debug Adapter protocols send them.
+package frame
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxContentLength is the maximum length of the content of a message in bytes.
+const MaxContentLength = 64 << 20
+
+// ErrMalformedHeader is returned for messages without a valid Content-Length header.
+var ErrMalformedHeader = errors.New("malformed message header")
+
+// Read returns the content of the next message, io.EOF is returned
+// if the input ends between messages.
+func Read(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for first := true; ; first = false {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if first && errors.Is(err, io.EOF) && line == "" {
+				return nil, io.EOF
+			}
+
+			return nil, fmt.Errorf("failed to read message header: %w", err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrMalformedHeader, line)
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:colon]), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[colon+1:]))
+			if err != nil || length < 0 || length > MaxContentLength {
+				return nil, fmt.Errorf("%w: %q", ErrMalformedHeader, line)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("%w: missing Content-Length", ErrMalformedHeader)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, fmt.Errorf("failed to read message content: %w", err)
+	}
+
+	return content, nil
+}
+
+// Write writes the content as a message.
+func Write(w io.Writer, content []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(content), content); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
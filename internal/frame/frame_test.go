@@ -0,0 +1,62 @@
+package frame_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/scroopy/internal/frame"
+)
+
+func TestRead(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("Content-Length: 2\r\nContent-Type: x\r\n\r\n{}content-length:1\r\n\r\n1"))
+	for _, expected := range []string{"{}", "1"} {
+		content, err := frame.Read(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Errorf("wrong content. expected=%q, got=%q", expected, content)
+		}
+	}
+
+	if _, err := frame.Read(r); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF. got=%v", err)
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected error
+	}{
+		{"Content-Length 2\r\n\r\n{}", frame.ErrMalformedHeader},
+		{"Content-Length: -1\r\n\r\n", frame.ErrMalformedHeader},
+		{"Content-Length: 9999999999999999\r\n\r\n{}", frame.ErrMalformedHeader},
+		{"Content-Length: 99999999999999999999\r\n\r\n{}", frame.ErrMalformedHeader},
+		{"Content-Type: x\r\n\r\n{}", frame.ErrMalformedHeader},
+		{"Content-Length: 3\r\n\r\n{}", io.ErrUnexpectedEOF},
+		{"Content-Length: 2\r\n", io.EOF},
+	}
+
+	for _, tt := range tests {
+		if _, err := frame.Read(bufio.NewReader(strings.NewReader(tt.input))); !errors.Is(err, tt.expected) {
+			t.Errorf("%q: wrong error. expected=%v, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := frame.Write(buf, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := frame.Read(bufio.NewReader(buf))
+	if err != nil || string(content) != "{}" {
+		t.Errorf("wrong content. expected=%q, got=%q (%v)", "{}", content, err)
+	}
+}
@@ -1,28 +1,101 @@
 package lexer
 
-import "github.com/dstdfx/scroopy/token"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dstdfx/scroopy/token"
+)
+
+// Mode controls the lexer behavior.
+type Mode uint
+
+// ScanComments makes the lexer return comments as token.COMMENT tokens
+// instead of skipping them.
+const ScanComments Mode = 1 << iota
+
+// Error represents an illegal piece of the input found by the lexer.
+type Error struct {
+	Pos token.Position // position of the error within a token.ILLEGAL token
+	Msg string         // human readable description of the error
+}
 
 // Lexer takes source code as an input and tokenizes it.
+// The input is decoded as UTF-8.
 type Lexer struct {
+	mode        Mode
+	errors      []*Error
+	filename    string // name of the source file, used in positions
 	input       string // input data to tokenize
 	currentPos  int    // current position in input, index of the char
 	nextReadPos int    // current next reading position after the currentPos
-	char        byte   // current read char
+	char        rune   // current read char
+	line        int    // line number of the current char, starting at 1
+	lineStart   int    // offset of the first char of the current line
 }
 
 // New returns new instance of Lexer.
 func New(src string) *Lexer {
-	l := &Lexer{input: src}
+	return NewWithFilename("", src)
+}
+
+// NewWithFilename returns new instance of Lexer that records the given
+// filename in positions of the produced tokens.
+func NewWithFilename(filename, src string) *Lexer {
+	return NewWithMode(filename, src, 0)
+}
+
+// NewWithMode returns new instance of Lexer that records the given
+// filename in positions of the produced tokens and behaves according to the mode.
+func NewWithMode(filename, src string, mode Mode) *Lexer {
+	l := &Lexer{filename: filename, input: src, line: 1, mode: mode}
 	l.readChar() // in order to initialize lexer fields
 
 	return l
 }
 
+// Errors returns errors found within token.ILLEGAL tokens returned so far,
+// the ones the lexer has an explanation for.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
 // NextToken method returns next token in the input.
 // If there's no more tokens left - token with token.EOF type is returned.
+// Comments are skipped unless the lexer was created with ScanComments mode.
+// A token containing an error is returned as token.ILLEGAL with its source text as the literal.
 func (l *Lexer) NextToken() token.Token {
+	for {
+		l.skipWhitespace()
+
+		errorsBefore := len(l.errors)
+		pos := l.position()
+		tok := l.scanToken()
+		tok.Pos = pos
+		if tok.Type == token.EOF {
+			tok.End = pos
+		} else {
+			tok.End = l.position()
+		}
+
+		if len(l.errors) > errorsBefore {
+			tok.Type = token.ILLEGAL
+			tok.Literal = l.input[pos.Offset:tok.End.Offset]
+		}
+
+		if tok.Type == token.COMMENT && l.mode&ScanComments == 0 {
+			continue
+		}
+
+		return tok
+	}
+}
+
+func (l *Lexer) scanToken() token.Token {
 	var tok token.Token
-	l.skipWhitespace()
 
 	switch l.char {
 	case '=':
@@ -46,9 +119,9 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.char)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.char)
+		tok = l.newAssignableToken(token.PLUS, token.PLUSASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.char)
+		tok = l.newAssignableToken(token.MINUS, token.MINUSASSIGN)
 	case '*':
 		if l.peekChar() == '*' {
 			l.readChar()
@@ -57,14 +130,34 @@ func (l *Lexer) NextToken() token.Token {
 				Literal: "**",
 			}
 		} else {
-			tok = newToken(token.ASTERISK, l.char)
+			tok = l.newAssignableToken(token.ASTERISK, token.ASTERISKASSIGN)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.char)
+		switch l.peekChar() {
+		case '/':
+			tok.Type = token.COMMENT
+			tok.Literal = l.readLineComment()
+
+			return tok
+		case '*':
+			tok.Type = token.COMMENT
+			tok.Literal = l.readBlockComment()
+			if l.char == 0 {
+				return tok
+			}
+		default:
+			tok = l.newAssignableToken(token.SLASH, token.SLASHASSIGN)
+		}
+	case '%':
+		tok = l.newAssignableToken(token.PERCENT, token.PERCENTASSIGN)
 	case '<':
-		tok = newToken(token.LT, l.char)
+		tok = l.newAssignableToken(token.LT, token.LTE)
 	case '>':
-		tok = newToken(token.GT, l.char)
+		tok = l.newAssignableToken(token.GT, token.GTE)
+	case '&':
+		tok = l.newDoubleToken(token.AND)
+	case '|':
+		tok = l.newDoubleToken(token.OR)
 	case '(':
 		tok = newToken(token.LPAREN, l.char)
 	case ')':
@@ -83,9 +176,23 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACKET, l.char)
 	case ']':
 		tok = newToken(token.RBRACKET, l.char)
+	case '.':
+		if l.peekChar() == '.' && l.nextReadPos+1 < len(l.input) && l.input[l.nextReadPos+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{
+				Type:    token.ELLIPSIS,
+				Literal: "...",
+			}
+		} else {
+			tok.Type = token.ILLEGAL
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
+		if l.char == 0 {
+			return tok
+		}
 	case 0:
 		tok.Type = token.EOF
 	default:
@@ -96,8 +203,7 @@ func (l *Lexer) NextToken() token.Token {
 
 			return tok
 		case isDigit(l.char):
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 
 			return tok
 		default:
@@ -109,61 +215,389 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func newToken(tokenType token.Type, char byte) token.Token {
+func newToken(tokenType token.Type, char rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(char),
 	}
 }
 
+// newAssignableToken returns the token of the operator at the current char,
+// or the token of its form followed by `=` if `=` follows, e.g. `+=` or `<=`.
+func (l *Lexer) newAssignableToken(operator, assignment token.Type) token.Token {
+	if l.peekChar() != '=' {
+		return newToken(operator, l.char)
+	}
+
+	char := l.char
+	l.readChar()
+
+	return token.Token{
+		Type:    assignment,
+		Literal: string(char) + "=",
+	}
+}
+
+// newDoubleToken returns the token of an operator made of the current char
+// repeated twice, e.g. `&&`, a single char is illegal.
+func (l *Lexer) newDoubleToken(tokenType token.Type) token.Token {
+	if l.peekChar() != l.char {
+		return newToken(token.ILLEGAL, l.char)
+	}
+
+	char := l.char
+	l.readChar()
+
+	return token.Token{
+		Type:    tokenType,
+		Literal: string([]rune{char, char}),
+	}
+}
+
+// readChar advances to the next char of the input. Invalid UTF-8 is reported
+// once the char it's decoded to is read past, making the token it belongs to illegal.
 func (l *Lexer) readChar() {
+	switch {
+	case l.char == '\n':
+		l.line++
+		l.lineStart = l.nextReadPos
+	case l.char == utf8.RuneError && l.nextReadPos-l.currentPos == 1:
+		l.addError(l.position(), "invalid UTF-8 encoding")
+	}
+
 	if l.nextReadPos >= len(l.input) {
 		l.char = 0 // EOF
-	} else {
-		l.char = l.input[l.nextReadPos]
+		l.currentPos = len(l.input)
+		l.nextReadPos = len(l.input) + 1
+
+		return
 	}
+
+	char, width := utf8.DecodeRuneInString(l.input[l.nextReadPos:])
+	l.char = char
 	l.currentPos = l.nextReadPos
-	l.nextReadPos++
+	l.nextReadPos += width
 }
 
-func (l *Lexer) peekChar() byte {
+// position returns the position of the current char.
+func (l *Lexer) position() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.currentPos,
+		Line:     l.line,
+		Column:   l.currentPos - l.lineStart + 1,
+	}
+}
+
+func (l *Lexer) peekChar() rune {
 	if l.nextReadPos >= len(l.input) {
 		return 0 // EOF
 	}
 
-	return l.input[l.nextReadPos]
+	char, _ := utf8.DecodeRuneInString(l.input[l.nextReadPos:])
+
+	return char
 }
 
+// readIdentifier reads an identifier, which is a letter followed by
+// letters and digits, both in terms of Unicode.
 func (l *Lexer) readIdentifier() string {
 	identifierStartsAt := l.currentPos
 	l.readChar() // doing so we don't need to check current char twice
-	for isLetter(l.char) {
+	for isLetter(l.char) || unicode.IsDigit(l.char) {
 		l.readChar()
 	}
 
 	return l.input[identifierStartsAt:l.currentPos]
 }
 
-func (l *Lexer) readNumber() string {
-	identifierStartsAt := l.currentPos
-	l.readChar() // doing so we don't need to check current char twice
-	for isDigit(l.char) {
+// readNumber reads an integer or a floating-point number. Integers are decimal
+// or have a base prefix: `0x` for hex, `0o` (or just a leading `0`) for octal
+// and `0b` for binary. Floats are decimal and have a fractional part (3.14),
+// an exponent (1e-9) or both (1.5e3). Digits may be separated by `_`.
+func (l *Lexer) readNumber() (string, token.Type) {
+	pos := l.position()
+	numberStartsAt := l.currentPos
+
+	if l.char == '0' {
+		if base, name := basePrefix(l.peekChar()); base != 0 {
+			l.readChar()
+			l.readChar()
+			if l.readDigits(base, name) == 0 {
+				l.addError(pos, name+" literal has no digits")
+			}
+
+			return l.readNumberEnd(pos, numberStartsAt), token.INT
+		}
+	}
+
+	tokenType := token.Type(token.INT)
+
+	l.readDigits(10, "")
+
+	if l.char == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
 		l.readChar()
+		l.readDigits(10, "")
 	}
 
-	return l.input[identifierStartsAt:l.currentPos]
+	if l.char == 'e' || l.char == 'E' {
+		exponentDigitAt := l.nextReadPos
+		if exponentDigitAt < len(l.input) && (l.input[exponentDigitAt] == '+' || l.input[exponentDigitAt] == '-') {
+			exponentDigitAt++
+		}
+
+		if exponentDigitAt < len(l.input) && isDigit(rune(l.input[exponentDigitAt])) {
+			tokenType = token.FLOAT
+			for l.nextReadPos < exponentDigitAt {
+				l.readChar()
+			}
+			l.readChar()
+			l.readDigits(10, "")
+		}
+	}
+
+	literal := l.readNumberEnd(pos, numberStartsAt)
+
+	// Integers with a leading zero are octal.
+	if tokenType == token.INT && len(literal) > 1 && literal[0] == '0' {
+		for i, char := range literal {
+			if char == '8' || char == '9' {
+				l.addError(shift(pos, i), fmt.Sprintf("invalid digit %q in octal literal", char))
+
+				break
+			}
+		}
+	}
+
+	return literal, tokenType
+}
+
+// readNumberEnd returns the literal of the number read so far, checking
+// that `_` separators are placed between digits only.
+func (l *Lexer) readNumberEnd(pos token.Position, numberStartsAt int) string {
+	literal := l.input[numberStartsAt:l.currentPos]
+	if idx := invalidSeparator(literal); idx >= 0 {
+		l.addError(shift(pos, idx), "'_' must separate successive digits")
+	}
+
+	return literal
+}
+
+// readDigits reads digits of the base along with `_` separators and returns
+// the number of digits read. Decimal digits not belonging to the base are
+// read as well, so they're reported as invalid digits of the named literal.
+func (l *Lexer) readDigits(base int, name string) int {
+	digits := 0
+
+	for {
+		switch {
+		case l.char == '_':
+		case isDigit(l.char):
+			if int(l.char-'0') >= base {
+				l.addError(l.position(), fmt.Sprintf("invalid digit %q in %s literal", l.char, name))
+			}
+			digits++
+		case base == 16 && isHexDigit(l.char):
+			digits++
+		default:
+			return digits
+		}
+
+		l.readChar()
+	}
+}
+
+// basePrefix returns the base and the name of integer literals prefixed
+// with `0` followed by the char, 0 is returned if the char isn't a prefix.
+func basePrefix(char rune) (int, string) {
+	switch unicode.ToLower(char) {
+	case 'x':
+		return 16, "hexadecimal"
+	case 'o':
+		return 8, "octal"
+	case 'b':
+		return 2, "binary"
+	default:
+		return 0, ""
+	}
+}
+
+// invalidSeparator returns the index of the first `_` in the number literal
+// that doesn't separate two digits, or -1 if there's none. A base prefix counts
+// as a digit, e.g. `0x_1f` is valid.
+func invalidSeparator(literal string) int {
+	prev := '.' // '0' for a digit, '_' for a separator, '.' for anything else
+	hex := false
+
+	i := 0
+	if len(literal) >= 2 && literal[0] == '0' {
+		if base, _ := basePrefix(rune(literal[1])); base != 0 {
+			prev = '0'
+			hex = base == 16
+			i = 2
+		}
+	}
+
+	for ; i < len(literal); i++ {
+		char := rune(literal[i])
+
+		switch {
+		case char == '_':
+			if prev != '0' {
+				return i
+			}
+		case isDigit(char) || hex && isHexDigit(char):
+			char = '0'
+		default:
+			if prev == '_' {
+				return i - 1
+			}
+			char = '.'
+		}
+
+		prev = char
+	}
+
+	if prev == '_' {
+		return len(literal) - 1
+	}
+
+	return -1
 }
 
+// shift returns the position the given number of bytes further on the same line.
+func shift(pos token.Position, bytes int) token.Position {
+	pos.Offset += bytes
+	pos.Column += bytes
+
+	return pos
+}
+
+// escapes maps chars following `\` in string literals to the chars they stand for.
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+}
+
+// readString reads a string literal opened by the current `"` and returns
+// its value with escape sequences replaced, the current char is left at
+// the closing `"`. Errors are recorded for malformed or unterminated literals.
 func (l *Lexer) readString() string {
-	stringStartsAt := l.currentPos + 1
+	var value strings.Builder
+
+	pos := l.position()
 	for {
 		l.readChar()
-		if l.char == '"' || l.char == 0 {
+
+		switch l.char {
+		case '"':
+			return value.String()
+		case 0:
+			l.addError(pos, "unterminated string literal")
+
+			return value.String()
+		case '\\':
+			l.readEscape(&value)
+		default:
+			value.WriteRune(l.char)
+		}
+	}
+}
+
+// readEscape reads the escape sequence started by the current `\` into
+// the value, the current char is left at the last char of the sequence.
+// Besides single char escapes, `\uXXXX` and `\UXXXXXXXX` stand for
+// Unicode code points given in hex.
+func (l *Lexer) readEscape(value *strings.Builder) {
+	pos := l.position()
+	escapeStartsAt := l.currentPos
+	l.readChar()
+
+	if char, ok := escapes[l.char]; ok {
+		value.WriteRune(char)
+
+		return
+	}
+
+	var digits int
+	switch l.char {
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	case 0:
+		// Reported as an unterminated literal.
+		return
+	default:
+		l.addError(pos, fmt.Sprintf("invalid escape sequence %s", l.input[escapeStartsAt:l.nextReadPos]))
+
+		return
+	}
+
+	for i := 0; i < digits; i++ {
+		if !isHexDigit(l.peekChar()) {
+			l.addError(pos, fmt.Sprintf("invalid escape sequence %s", l.input[escapeStartsAt:l.nextReadPos]))
+
+			return
+		}
+		l.readChar()
+	}
+
+	codePoint, _ := strconv.ParseUint(l.input[escapeStartsAt+2:l.nextReadPos], 16, 32)
+	if !utf8.ValidRune(rune(codePoint)) {
+		l.addError(pos, fmt.Sprintf("escape sequence %s is invalid Unicode code point",
+			l.input[escapeStartsAt:l.nextReadPos]))
+
+		return
+	}
+
+	value.WriteRune(rune(codePoint))
+}
+
+// addError records the error found at the position, the token being read becomes illegal.
+func (l *Lexer) addError(pos token.Position, msg string) {
+	l.errors = append(l.errors, &Error{Pos: pos, Msg: msg})
+}
+
+// readLineComment reads a comment from `//` up to the end of the line,
+// the newline doesn't belong to the comment.
+func (l *Lexer) readLineComment() string {
+	commentStartsAt := l.currentPos
+	for l.char != '\n' && l.char != 0 {
+		l.readChar()
+	}
+
+	return l.input[commentStartsAt:l.currentPos]
+}
+
+// readBlockComment reads a comment from `/*` up to the closing `*/`
+// the current char is left at, or up to EOF if the comment is unterminated.
+// Block comments don't nest.
+func (l *Lexer) readBlockComment() string {
+	pos := l.position()
+	commentStartsAt := l.currentPos
+	l.readChar() // skip `*` of the opening `/*`
+	for {
+		l.readChar()
+		if l.char == 0 || l.char == '*' && l.peekChar() == '/' {
 			break
 		}
 	}
 
-	return l.input[stringStartsAt:l.currentPos]
+	if l.char == 0 {
+		l.addError(pos, "unterminated block comment")
+
+		return l.input[commentStartsAt:]
+	}
+
+	l.readChar() // up to `/` of the closing `*/`
+
+	return l.input[commentStartsAt : l.currentPos+1]
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -172,10 +606,16 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+// isDigit reports whether the char is a decimal digit, numbers are made of ASCII digits only.
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
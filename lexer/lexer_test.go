@@ -121,7 +121,7 @@ if (5 < result) {
 }
 
 func TestLexer_NextToken_WithIllegalTokens(t *testing.T) {
-	input := `=+-*/(),:;{}@!<>==!=**`
+	input := `=+-*/(),:;{}@!<> ==!=**`
 
 	tests := []struct {
 		expectedType    token.Type
@@ -230,6 +230,95 @@ func TestLexer_NextToken_WithIllegalTokens(t *testing.T) {
 	}
 }
 
+func TestLexer_NextToken_Positions(t *testing.T) {
+	input := `let x = 5;
+  x + "ab"`
+
+	tests := []struct {
+		expectedType token.Type
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{
+			expectedType: token.LET,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 0, Line: 1, Column: 1},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 3, Line: 1, Column: 4},
+		},
+		{
+			expectedType: token.IDENT,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 4, Line: 1, Column: 5},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 5, Line: 1, Column: 6},
+		},
+		{
+			expectedType: token.ASSIGN,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 6, Line: 1, Column: 7},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 7, Line: 1, Column: 8},
+		},
+		{
+			expectedType: token.INT,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 8, Line: 1, Column: 9},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 9, Line: 1, Column: 10},
+		},
+		{
+			expectedType: token.SEMICOLON,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 9, Line: 1, Column: 10},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 10, Line: 1, Column: 11},
+		},
+		{
+			expectedType: token.IDENT,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 13, Line: 2, Column: 3},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 14, Line: 2, Column: 4},
+		},
+		{
+			expectedType: token.PLUS,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 15, Line: 2, Column: 5},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 16, Line: 2, Column: 6},
+		},
+		{
+			expectedType: token.STRING,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 17, Line: 2, Column: 7},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 21, Line: 2, Column: 11},
+		},
+		{
+			expectedType: token.EOF,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 21, Line: 2, Column: 11},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 21, Line: 2, Column: 11},
+		},
+		{
+			expectedType: token.EOF,
+			expectedPos:  token.Position{Filename: "test.scr", Offset: 21, Line: 2, Column: 11},
+			expectedEnd:  token.Position{Filename: "test.scr", Offset: 21, Line: 2, Column: 11},
+		},
+	}
+
+	lex := lexer.NewWithFilename("test.scr", input)
+
+	for idx, test := range tests {
+		tok := lex.NextToken()
+
+		if tok.Type != test.expectedType {
+			t.Fatalf("test[%d]: expected '%s' token type, but got '%s'",
+				idx,
+				test.expectedType,
+				tok.Type)
+		}
+
+		if tok.Pos != test.expectedPos {
+			t.Fatalf("test[%d]: expected token position %+v, but got %+v",
+				idx,
+				test.expectedPos,
+				tok.Pos)
+		}
+
+		if tok.End != test.expectedEnd {
+			t.Fatalf("test[%d]: expected token end %+v, but got %+v",
+				idx,
+				test.expectedEnd,
+				tok.End)
+		}
+	}
+}
+
 func BenchmarkLexer(b *testing.B) {
 	input := `let five = 5;
 let ten = 10;
@@ -260,3 +349,275 @@ func runLexing(input string) {
 		t = lx.NextToken()
 	}
 }
+
+func TestLexer_NextToken_Numbers(t *testing.T) {
+	input := `5 3.14 1e-9 1.5E+3 2e10 0.5 7. 1e x`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{expectedType: token.INT, expectedLiteral: "5"},
+		{expectedType: token.FLOAT, expectedLiteral: "3.14"},
+		{expectedType: token.FLOAT, expectedLiteral: "1e-9"},
+		{expectedType: token.FLOAT, expectedLiteral: "1.5E+3"},
+		{expectedType: token.FLOAT, expectedLiteral: "2e10"},
+		{expectedType: token.FLOAT, expectedLiteral: "0.5"},
+		// A dot that isn't followed by a digit doesn't belong to the number.
+		{expectedType: token.INT, expectedLiteral: "7"},
+		{expectedType: token.ILLEGAL, expectedLiteral: ""},
+		// Neither does an exponent without digits.
+		{expectedType: token.INT, expectedLiteral: "1"},
+		{expectedType: token.IDENT, expectedLiteral: "e"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.EOF, expectedLiteral: ""},
+	}
+
+	lex := lexer.New(input)
+
+	for idx, test := range tests {
+		tok := lex.NextToken()
+
+		if tok.Type != test.expectedType || tok.Literal != test.expectedLiteral {
+			t.Fatalf("test[%d]: expected %s(%q) token, but got %s(%q)",
+				idx, test.expectedType, test.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestLexer_NextToken_LoopKeywords(t *testing.T) {
+	input := `while for in break continue inner`
+
+	expected := []token.Type{token.WHILE, token.FOR, token.IN, token.BREAK, token.CONTINUE, token.IDENT, token.EOF}
+
+	lex := lexer.New(input)
+
+	for idx, expectedType := range expected {
+		tok := lex.NextToken()
+
+		if tok.Type != expectedType {
+			t.Fatalf("test[%d]: expected '%s' token type, but got '%s'", idx, expectedType, tok.Type)
+		}
+	}
+}
+
+func TestLexer_NextToken_AssignmentOperators(t *testing.T) {
+	input := `x += 1; x -= 1; x *= 1; x /= 1; x %= 1; x = x ** 2 % 3`
+
+	expected := []token.Type{
+		token.IDENT, token.PLUSASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.MINUSASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.ASTERISKASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.SLASHASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.PERCENTASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.ASSIGN, token.IDENT, token.POW, token.INT, token.PERCENT, token.INT,
+		token.EOF,
+	}
+
+	lex := lexer.New(input)
+
+	for idx, expectedType := range expected {
+		tok := lex.NextToken()
+
+		if tok.Type != expectedType {
+			t.Fatalf("test[%d]: expected '%s' token type, but got '%s'", idx, expectedType, tok.Type)
+		}
+	}
+}
+
+func TestLexer_NextToken_LogicalOperators(t *testing.T) {
+	input := `a && b || c <= d >= e < f > g & h`
+
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.AND, Literal: "&&"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.OR, Literal: "||"},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.LTE, Literal: "<="},
+		{Type: token.IDENT, Literal: "d"},
+		{Type: token.GTE, Literal: ">="},
+		{Type: token.IDENT, Literal: "e"},
+		{Type: token.LT, Literal: "<"},
+		{Type: token.IDENT, Literal: "f"},
+		{Type: token.GT, Literal: ">"},
+		{Type: token.IDENT, Literal: "g"},
+		{Type: token.ILLEGAL, Literal: "&"},
+		{Type: token.IDENT, Literal: "h"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	lex := lexer.New(input)
+
+	for idx, expectedToken := range expected {
+		tok := lex.NextToken()
+
+		if tok.Type != expectedToken.Type {
+			t.Fatalf("test[%d]: expected '%s' token type, but got '%s'", idx, expectedToken.Type, tok.Type)
+		}
+
+		if tok.Literal != expectedToken.Literal {
+			t.Fatalf("test[%d]: expected '%s' literal, but got '%s'", idx, expectedToken.Literal, tok.Literal)
+		}
+	}
+}
+
+func TestLexer_NextToken_Comments(t *testing.T) {
+	input := `x // line comment
+/* block
+comment */ y / z /**/
+/* unterminated`
+
+	tests := []struct {
+		mode     lexer.Mode
+		expected []token.Token
+	}{
+		{
+			mode: 0,
+			expected: []token.Token{
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.IDENT, Literal: "y"},
+				{Type: token.SLASH, Literal: "/"},
+				{Type: token.IDENT, Literal: "z"},
+				{Type: token.ILLEGAL, Literal: "/* unterminated"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			mode: lexer.ScanComments,
+			expected: []token.Token{
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.COMMENT, Literal: "// line comment"},
+				{Type: token.COMMENT, Literal: "/* block\ncomment */"},
+				{Type: token.IDENT, Literal: "y"},
+				{Type: token.SLASH, Literal: "/"},
+				{Type: token.IDENT, Literal: "z"},
+				{Type: token.COMMENT, Literal: "/**/"},
+				{Type: token.ILLEGAL, Literal: "/* unterminated"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lex := lexer.NewWithMode("", input, tt.mode)
+
+		for idx, expectedToken := range tt.expected {
+			tok := lex.NextToken()
+
+			if tok.Type != expectedToken.Type {
+				t.Fatalf("test[%d]: expected '%s' token type, but got '%s'", idx, expectedToken.Type, tok.Type)
+			}
+
+			if tok.Literal != expectedToken.Literal {
+				t.Fatalf("test[%d]: expected %q literal, but got %q", idx, expectedToken.Literal, tok.Literal)
+			}
+		}
+
+		errors := lex.Errors()
+		if len(errors) != 1 || errors[0].Msg != "unterminated block comment" || errors[0].Pos.String() != "4:1" {
+			t.Errorf("expected unterminated block comment error at 4:1, got %+v", errors)
+		}
+	}
+}
+
+func TestLexer_NextToken_Strings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.Type
+		expectedLiteral string
+		expectedError   string
+	}{
+		{`"plain"`, token.STRING, "plain", ""},
+		{`"a\nb\tc\r\0"`, token.STRING, "a\nb\tc\r\x00", ""},
+		{`"quote \" backslash \\"`, token.STRING, `quote " backslash \`, ""},
+		{`"café \U0001F600"`, token.STRING, "café 😀", ""},
+		{`"héllo"`, token.STRING, "héllo", ""},
+		{"\"multi\nline\"", token.STRING, "multi\nline", ""},
+		{`"bad \q escape"`, token.ILLEGAL, `"bad \q escape"`, `invalid escape sequence \q`},
+		{`"short \u12 escape"`, token.ILLEGAL, `"short \u12 escape"`, `invalid escape sequence \u12`},
+		{`"\UFFFFFFFF"`, token.ILLEGAL, `"\UFFFFFFFF"`, `escape sequence \UFFFFFFFF is invalid Unicode code point`},
+		{`"unterminated`, token.ILLEGAL, `"unterminated`, "unterminated string literal"},
+		{`"escaped quote at the end\"`, token.ILLEGAL, `"escaped quote at the end\"`, "unterminated string literal"},
+	}
+
+	for _, tt := range tests {
+		lex := lexer.New(tt.input)
+		tok := lex.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%s: expected '%s' token type, but got '%s'", tt.input, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%s: expected %q literal, but got %q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		if next := lex.NextToken(); next.Type != token.EOF {
+			t.Errorf("%s: expected EOF after the string, got '%s'", tt.input, next.Type)
+		}
+
+		errors := lex.Errors()
+		switch {
+		case tt.expectedError == "" && len(errors) != 0:
+			t.Errorf("%s: expected no errors, got %+v", tt.input, errors[0])
+		case tt.expectedError != "" && (len(errors) != 1 || errors[0].Msg != tt.expectedError):
+			t.Errorf("%s: expected error %q, got %+v", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestLexer_NextToken_Unicode(t *testing.T) {
+	input := "let größe = π2 + 日本語; x٣ € \xff \"ok\" /* \xfe */ y"
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{token.LET, "let", "1:1"},
+		{token.IDENT, "größe", "1:5"},
+		{token.ASSIGN, "=", "1:13"},
+		{token.IDENT, "π2", "1:15"},
+		{token.PLUS, "+", "1:19"},
+		{token.IDENT, "日本語", "1:21"},
+		{token.SEMICOLON, ";", "1:30"},
+		// Unicode digits are allowed in identifiers after the first letter.
+		{token.IDENT, "x٣", "1:32"},
+		{token.ILLEGAL, "", "1:36"},
+		{token.ILLEGAL, "\xff", "1:40"},
+		{token.STRING, "ok", "1:42"},
+		{token.ILLEGAL, "/* \xfe */", "1:47"},
+		{token.IDENT, "y", "1:55"},
+		{token.EOF, "", "1:56"},
+	}
+
+	lex := lexer.New(input)
+
+	for idx, test := range tests {
+		tok := lex.NextToken()
+
+		if tok.Type != test.expectedType {
+			t.Fatalf("test[%d]: expected '%s' token type, but got '%s'", idx, test.expectedType, tok.Type)
+		}
+
+		if tok.Literal != test.expectedLiteral {
+			t.Fatalf("test[%d]: expected %q literal, but got %q", idx, test.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.String() != test.expectedPos {
+			t.Fatalf("test[%d]: expected position %s, but got %s", idx, test.expectedPos, tok.Pos)
+		}
+	}
+
+	errors := lex.Errors()
+	if len(errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(errors))
+	}
+
+	for idx, expectedPos := range []string{"1:40", "1:50"} {
+		if errors[idx].Msg != "invalid UTF-8 encoding" || errors[idx].Pos.String() != expectedPos {
+			t.Errorf("errors[%d]: expected invalid UTF-8 encoding at %s, got %+v", idx, expectedPos, errors[idx])
+		}
+	}
+}
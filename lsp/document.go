@@ -0,0 +1,157 @@
+package lsp
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/check"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/parser"
+	"github.com/dstdfx/scroopy/token"
+)
+
+// document is an open text document along with its parsed program.
+// The program is parsed on every change, even if the text has syntax
+// errors the tree holds the statements parsed successfully.
+type document struct {
+	uri         string
+	text        string
+	predeclared []string
+	lineStarts  []int // byte offsets of the first character of every line
+	root        *ast.Root
+	errs        parser.ErrorList
+	info        *check.Info
+}
+
+func newDocument(uri, text string, predeclared []string) *document {
+	d := &document{uri: uri, text: text, predeclared: predeclared, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	p := parser.New(lexer.NewWithFilename(uri, text))
+	d.root = p.ParseProgram()
+	d.errs = p.Errors()
+	d.info = check.Resolve(d.root, predeclared...)
+
+	return d
+}
+
+// position converts the byte offset to the LSP position.
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+
+	line := sort.SearchInts(d.lineStarts, offset+1) - 1
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16Len(r)
+	}
+
+	return Position{Line: line, Character: character}
+}
+
+// offset converts the LSP position to the byte offset, positions past
+// the end of the line or of the text are moved to the end.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+
+	return offset
+}
+
+func (d *document) rangeOf(pos, end token.Position) Range {
+	return Range{Start: d.position(pos.Offset), End: d.position(end.Offset)}
+}
+
+func (d *document) location(node ast.Node) Location {
+	return Location{URI: d.uri, Range: d.rangeOf(node.Pos(), node.End())}
+}
+
+// identifierAt returns the identifier found at the position, the position
+// right after the identifier counts too.
+func (d *document) identifierAt(pos Position) *ast.Identifier {
+	offset := d.offset(pos)
+
+	var found *ast.Identifier
+	ast.Inspect(d.root, func(n ast.Node) bool {
+		if found != nil || n == nil {
+			return false
+		}
+		if n.Pos().Offset > offset || n.End().Offset < offset {
+			// Root doesn't enclose comments, it has to be entered anyway.
+			_, isRoot := n.(*ast.Root)
+
+			return isRoot
+		}
+		if ident, ok := n.(*ast.Identifier); ok {
+			found = ident
+		}
+
+		return true
+	})
+
+	return found
+}
+
+// definition returns the identifier binding the name the identifier refers
+// to, the identifier itself is returned if it binds the name. Nil is returned
+// for build-in functions and undefined names.
+func (d *document) definition(ident *ast.Identifier) *ast.Identifier {
+	if def, ok := d.info.Uses[ident]; ok {
+		return def
+	}
+
+	for _, scope := range d.info.Scopes {
+		for _, binding := range scope.Bindings {
+			if binding == ident {
+				return ident
+			}
+		}
+	}
+
+	return nil
+}
+
+// references returns identifiers referring to the binding in the source order.
+func (d *document) references(def *ast.Identifier) []*ast.Identifier {
+	var refs []*ast.Identifier
+	for ident, target := range d.info.Uses {
+		if target == def {
+			refs = append(refs, ident)
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Pos().Offset < refs[j].Pos().Offset
+	})
+
+	return refs
+}
+
+func utf16Len(r rune) int {
+	// Runes outside of the basic multilingual plane take a surrogate pair.
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
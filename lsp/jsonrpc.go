@@ -0,0 +1,78 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dstdfx/scroopy/internal/frame"
+)
+
+// JSON-RPC and LSP error codes.
+const (
+	codeParseError           = -32700
+	codeInvalidRequest       = -32600
+	codeMethodNotFound       = -32601
+	codeInvalidParams        = -32602
+	codeServerNotInitialized = -32002
+	codeRequestFailed        = -32803
+)
+
+// ErrMalformedHeader is returned for messages without a valid Content-Length header.
+var ErrMalformedHeader = frame.ErrMalformedHeader
+
+// message is a JSON-RPC request, notification or response. Requests and
+// responses have an ID, notifications don't.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      json.RawMessage  `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool {
+	return m.Method != "" && m.ID != nil
+}
+
+// ResponseError is the error a request is answered with.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+func responseErrorf(code int, format string, a ...interface{}) *ResponseError {
+	return &ResponseError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// conn reads and writes messages framed with the Content-Length header.
+type conn struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: bufio.NewReader(r), w: w}
+}
+
+// read returns the content of the next message, io.EOF is returned
+// if the input ends between messages.
+func (c *conn) read() ([]byte, error) {
+	return frame.Read(c.r)
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return frame.Write(c.w, content)
+}
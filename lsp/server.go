@@ -0,0 +1,522 @@
+// Package lsp implements a Language Server Protocol server for Scroopy
+// speaking JSON-RPC over a pair of streams, usually stdin and stdout.
+//
+// Documents are synchronized in full. The server publishes syntax errors
+// and problems found by the check package, resolves definitions and
+// references of bindings, shows signatures of build-in functions on hover,
+// completes names and formats documents.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/check"
+	"github.com/dstdfx/scroopy/format"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/token"
+)
+
+// ErrExitWithoutShutdown is returned by Serve if the client asks the server
+// to exit before shutting it down.
+var ErrExitWithoutShutdown = errors.New("exit requested before shutdown")
+
+type handlerFunc func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handlerFunc{
+	"initialize":              (*Server).initialize,
+	"initialized":             nop,
+	"shutdown":                (*Server).shutdown,
+	"textDocument/didOpen":    (*Server).didOpen,
+	"textDocument/didChange":  (*Server).didChange,
+	"textDocument/didClose":   (*Server).didClose,
+	"textDocument/definition": (*Server).definition,
+	"textDocument/references": (*Server).references,
+	"textDocument/hover":      (*Server).hover,
+	"textDocument/completion": (*Server).completion,
+	"textDocument/formatting": (*Server).formatting,
+}
+
+// Server is a language server handling a single client.
+type Server struct {
+	conn        *conn
+	predeclared []string
+	documents   map[string]*document
+	initialized bool
+	shutDown    bool
+}
+
+// NewServer returns a server reading client messages from r and writing
+// its messages to w. Predeclared names are treated as bound outside
+// of the documents, see check.Program.
+func NewServer(r io.Reader, w io.Writer, predeclared ...string) *Server {
+	return &Server{
+		conn:        newConn(r, w),
+		predeclared: predeclared,
+		documents:   make(map[string]*document),
+	}
+}
+
+// Serve handles client messages until the client asks the server to exit
+// or the input ends.
+func (s *Server) Serve() error {
+	for {
+		content, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		msg := &message{}
+		if err := json.Unmarshal(content, msg); err != nil {
+			if err := s.reply(json.RawMessage("null"), nil, responseErrorf(codeParseError, "%s", err)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutDown {
+				return ErrExitWithoutShutdown
+			}
+
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches the message to its handler and answers requests,
+// notifications the server doesn't know are ignored.
+func (s *Server) handle(msg *message) error {
+	if msg.Method == "" {
+		// The server doesn't send requests, responses aren't expected.
+		return nil
+	}
+
+	result, err := s.call(msg)
+	var respErr *ResponseError
+	if !msg.isRequest() {
+		// Notifications can't be answered, only failures to write
+		// messages are reported.
+		if err != nil && !errors.As(err, &respErr) {
+			return err
+		}
+
+		return nil
+	}
+
+	if err != nil && !errors.As(err, &respErr) {
+		respErr = responseErrorf(codeRequestFailed, "%s", err)
+	}
+
+	return s.reply(msg.ID, result, respErr)
+}
+
+func (s *Server) call(msg *message) (interface{}, error) {
+	handler, ok := handlers[msg.Method]
+	switch {
+	case !ok:
+		return nil, responseErrorf(codeMethodNotFound, "method not found: %s", msg.Method)
+	case !s.initialized && msg.Method != "initialize":
+		return nil, responseErrorf(codeServerNotInitialized, "server is not initialized")
+	case s.shutDown:
+		return nil, responseErrorf(codeInvalidRequest, "server is shut down")
+	}
+
+	return handler(s, msg.Params)
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, respErr *ResponseError) error {
+	msg := &message{ID: id}
+	if respErr != nil {
+		msg.Error = respErr
+
+		return s.conn.write(msg)
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	raw := json.RawMessage(content)
+	msg.Result = &raw
+
+	return s.conn.write(msg)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	content, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode params: %w", err)
+	}
+
+	return s.conn.write(&message{Method: method, Params: content})
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return responseErrorf(codeInvalidParams, "invalid params: %s", err)
+	}
+
+	return nil
+}
+
+func nop(*Server, json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *Server) initialize(json.RawMessage) (interface{}, error) {
+	s.initialized = true
+
+	result := &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           syncFull,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			HoverProvider:              true,
+			CompletionProvider:         &struct{}{},
+			DocumentFormattingProvider: true,
+		},
+	}
+	result.ServerInfo.Name = "scroopy"
+
+	return result, nil
+}
+
+func (s *Server) shutdown(json.RawMessage) (interface{}, error) {
+	s.shutDown = true
+
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+
+	// Changes hold the whole text, the last one is the current text.
+	return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	delete(s.documents, p.TextDocument.URI)
+
+	// Diagnostics of closed documents are cleared.
+	return nil, s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update replaces text of the document and publishes its diagnostics.
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text, s.predeclared)
+	s.documents[uri] = doc
+
+	return s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics(doc),
+	})
+}
+
+// diagnostics returns syntax errors of the document, or problems found
+// by the check package if it parses.
+func diagnostics(doc *document) []Diagnostic {
+	diags := make([]Diagnostic, 0)
+	if len(doc.errs) > 0 {
+		for _, err := range doc.errs {
+			diags = append(diags, Diagnostic{
+				Range:    doc.rangeOf(err.Pos, err.Pos),
+				Severity: SeverityError,
+				Code:     string(check.Syntax),
+				Source:   "scroopy",
+				Message:  err.Msg,
+			})
+		}
+
+		return diags
+	}
+
+	for _, d := range check.Program(doc.root, doc.predeclared...) {
+		severity := SeverityWarning
+		if d.Severity == check.Error {
+			severity = SeverityError
+		}
+
+		diags = append(diags, Diagnostic{
+			Range:    doc.rangeOf(d.Pos, d.End),
+			Severity: severity,
+			Code:     string(d.Code),
+			Source:   "scroopy",
+			Message:  d.Msg,
+		})
+	}
+
+	return diags
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, responseErrorf(codeInvalidParams, "document is not open: %s", uri)
+	}
+
+	return doc, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	ident := doc.identifierAt(p.Position)
+	if ident == nil {
+		return nil, nil
+	}
+
+	def := doc.definition(ident)
+	if def == nil {
+		return nil, nil
+	}
+
+	return doc.location(def), nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]Location, 0)
+	ident := doc.identifierAt(p.Position)
+	if ident == nil {
+		return locations, nil
+	}
+
+	def := doc.definition(ident)
+	if def == nil {
+		return locations, nil
+	}
+
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, doc.location(def))
+	}
+	for _, ref := range doc.references(def) {
+		locations = append(locations, doc.location(ref))
+	}
+
+	return locations, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	ident := doc.identifierAt(p.Position)
+	if ident == nil {
+		return nil, nil
+	}
+
+	var contents string
+	if def := doc.definition(ident); def != nil {
+		contents = codeBlock(doc.describe(def))
+	} else if signature, builtinDoc, ok := builtinDoc(ident.Value); ok {
+		contents = codeBlock(signature) + "\n" + builtinDoc
+	} else {
+		return nil, nil
+	}
+
+	r := doc.rangeOf(ident.Pos(), ident.End())
+
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: contents}, Range: &r}, nil
+}
+
+// completion offers names bound in the scopes enclosing the position,
+// followed by the build-in functions they don't shadow.
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]CompletionItem, 0)
+	seen := make(map[string]bool)
+	for _, name := range doc.predeclared {
+		seen[name] = true
+		items = append(items, CompletionItem{Label: name, Kind: CompletionVariable})
+	}
+
+	scope := doc.info.Innermost(token.Position{Offset: doc.offset(p.Position)})
+	for ; scope != nil; scope = scope.Outer {
+		for _, binding := range scope.Bindings {
+			if seen[binding.Value] {
+				continue
+			}
+			seen[binding.Value] = true
+
+			items = append(items, CompletionItem{
+				Label:  binding.Value,
+				Kind:   CompletionVariable,
+				Detail: doc.describe(binding),
+			})
+		}
+	}
+
+	for _, builtin := range object.Builtins {
+		if seen[builtin.Name] {
+			continue
+		}
+
+		items = append(items, CompletionItem{
+			Label:         builtin.Name,
+			Kind:          CompletionFunction,
+			Detail:        builtin.Signature,
+			Documentation: &MarkupContent{Kind: "markdown", Value: builtin.Doc},
+		})
+	}
+
+	return items, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(doc.uri, []byte(doc.text))
+	if err != nil {
+		return nil, err
+	}
+
+	edits := make([]TextEdit, 0, 1)
+	if string(formatted) != doc.text {
+		edits = append(edits, TextEdit{
+			Range:   Range{Start: Position{}, End: doc.position(len(doc.text))},
+			NewText: string(formatted),
+		})
+	}
+
+	return edits, nil
+}
+
+// builtinDoc returns the signature and the description of the build-in function.
+func builtinDoc(name string) (signature, doc string, ok bool) {
+	for _, builtin := range object.Builtins {
+		if builtin.Name == name {
+			return builtin.Signature, builtin.Doc, true
+		}
+	}
+
+	return "", "", false
+}
+
+func codeBlock(code string) string {
+	return "```scroopy\n" + code + "\n```"
+}
+
+// describe returns the source code binding the identifier: the let
+// statement with the function body left out, the parameter or the loop.
+func (d *document) describe(def *ast.Identifier) string {
+	description := def.Value
+	ast.Inspect(d.root, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			if n.Name != def {
+				return true
+			}
+
+			switch value := n.Value.(type) {
+			case *ast.FunctionLiteral:
+				description = "let " + def.Value + " = " + withoutBody(value, value.Body)
+			case *ast.MacroLiteral:
+				description = "let " + def.Value + " = " + withoutBody(value, value.Body)
+			default:
+				description = "let " + def.Value
+			}
+		case *ast.FunctionLiteral:
+			if isParameter(def, n.Parameters, n.Rest) {
+				description = "parameter " + def.Value
+			}
+		case *ast.MacroLiteral:
+			if isParameter(def, n.Parameters, nil) {
+				description = "parameter " + def.Value
+			}
+		case *ast.ForStatement:
+			if n.Key == def || n.Value == def {
+				description = "loop variable " + def.Value
+			}
+		}
+
+		return true
+	})
+
+	return description
+}
+
+func withoutBody(literal ast.Expression, body *ast.BlockStatement) string {
+	return strings.TrimSpace(strings.TrimSuffix(literal.String(), body.String()))
+}
+
+func isParameter(ident *ast.Identifier, params []*ast.Identifier, rest *ast.Identifier) bool {
+	for _, param := range params {
+		if param == ident {
+			return true
+		}
+	}
+
+	return rest != nil && rest == ident
+}
@@ -0,0 +1,473 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/scroopy/lsp"
+)
+
+// client talks to a server running in the same process.
+type client struct {
+	t             *testing.T
+	w             io.WriteCloser
+	nextID        int
+	responses     chan map[string]json.RawMessage
+	notifications chan map[string]json.RawMessage
+	done          chan error
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{
+		t:             t,
+		w:             clientOut,
+		responses:     make(chan map[string]json.RawMessage, 16),
+		notifications: make(chan map[string]json.RawMessage, 16),
+		done:          make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- lsp.NewServer(serverIn, serverOut, "args").Serve()
+		_ = serverOut.Close()
+	}()
+	go c.readLoop(bufio.NewReader(clientIn))
+
+	t.Cleanup(func() {
+		_ = clientOut.Close()
+	})
+
+	return c
+}
+
+func (c *client) readLoop(r *bufio.Reader) {
+	for {
+		length := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if strings.HasPrefix(line, "Content-Length:") {
+				length, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+			}
+		}
+
+		content := make([]byte, length)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return
+		}
+
+		msg := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(content, &msg); err != nil {
+			return
+		}
+
+		if _, ok := msg["id"]; ok {
+			c.responses <- msg
+		} else {
+			c.notifications <- msg
+		}
+	}
+}
+
+func (c *client) send(msg map[string]interface{}) {
+	c.t.Helper()
+
+	msg["jsonrpc"] = "2.0"
+	content, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(content), content); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// call sends the request and decodes the result of the response into result.
+// The response error is returned, if any.
+func (c *client) call(method string, params, result interface{}) *lsp.ResponseError {
+	c.t.Helper()
+
+	c.nextID++
+	c.send(map[string]interface{}{"id": c.nextID, "method": method, "params": params})
+
+	msg := c.receive(c.responses)
+	if id := string(msg["id"]); id != strconv.Itoa(c.nextID) {
+		c.t.Fatalf("wrong response id. expected=%d, got=%s", c.nextID, id)
+	}
+
+	if raw, ok := msg["error"]; ok {
+		respErr := &lsp.ResponseError{}
+		if err := json.Unmarshal(raw, respErr); err != nil {
+			c.t.Fatal(err)
+		}
+
+		return respErr
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(msg["result"], result); err != nil {
+			c.t.Fatalf("failed to decode result %s: %s", msg["result"], err)
+		}
+	}
+
+	return nil
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+
+	c.send(map[string]interface{}{"method": method, "params": params})
+}
+
+// diagnostics waits for the next diagnostics published by the server.
+func (c *client) diagnostics() lsp.PublishDiagnosticsParams {
+	c.t.Helper()
+
+	msg := c.receive(c.notifications)
+	if method := string(msg["method"]); method != `"textDocument/publishDiagnostics"` {
+		c.t.Fatalf("unexpected notification %s", method)
+	}
+
+	var params lsp.PublishDiagnosticsParams
+	if err := json.Unmarshal(msg["params"], &params); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return params
+}
+
+func (c *client) receive(ch chan map[string]json.RawMessage) map[string]json.RawMessage {
+	c.t.Helper()
+
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+
+		return nil
+	}
+}
+
+// open initializes the server and opens the document.
+func (c *client) open(uri, text string) lsp.PublishDiagnosticsParams {
+	c.t.Helper()
+
+	if err := c.call("initialize", map[string]interface{}{}, nil); err != nil {
+		c.t.Fatalf("initialize failed: %s", err)
+	}
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "scroopy", "version": 1, "text": text},
+	})
+
+	return c.diagnostics()
+}
+
+func positionParams(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+const uri = "file:///script.scr"
+
+func TestServer_Initialize(t *testing.T) {
+	c := newClient(t)
+
+	if err := c.call("textDocument/hover", positionParams(uri, 0, 0), nil); err == nil || err.Code != -32002 {
+		t.Errorf("expected the server not initialized error, got=%v", err)
+	}
+
+	var result lsp.InitializeResult
+	if err := c.call("initialize", map[string]interface{}{}, &result); err != nil {
+		t.Fatalf("initialize failed: %s", err)
+	}
+	capabilities := result.Capabilities
+	if capabilities.TextDocumentSync != 1 || !capabilities.DefinitionProvider || !capabilities.ReferencesProvider ||
+		!capabilities.HoverProvider || capabilities.CompletionProvider == nil || !capabilities.DocumentFormattingProvider {
+		t.Errorf("wrong capabilities. got=%+v", capabilities)
+	}
+
+	if err := c.call("unknown/method", map[string]interface{}{}, nil); err == nil || err.Code != -32601 {
+		t.Errorf("expected the method not found error, got=%v", err)
+	}
+
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatalf("shutdown failed: %s", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error on exit: %s", err)
+	}
+}
+
+func TestServer_ExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.notify("exit", nil)
+
+	if err := <-c.done; err != lsp.ErrExitWithoutShutdown {
+		t.Errorf("wrong error. expected=%v, got=%v", lsp.ErrExitWithoutShutdown, err)
+	}
+}
+
+func TestServer_MalformedHeader(t *testing.T) {
+	c := newClient(t)
+	if _, err := io.WriteString(c.w, "Content-Length: 9999999999999999\r\n\r\n{}"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-c.done; !errors.Is(err, lsp.ErrMalformedHeader) {
+		t.Errorf("wrong error. expected=%v, got=%v", lsp.ErrMalformedHeader, err)
+	}
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	c := newClient(t)
+
+	params := c.open(uri, "let x = 1;\nlet y 2;\n")
+	if params.URI != uri {
+		t.Errorf("wrong uri. expected=%q, got=%q", uri, params.URI)
+	}
+	expected := []lsp.Diagnostic{{
+		Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 6}, End: lsp.Position{Line: 1, Character: 6}},
+		Severity: lsp.SeverityError,
+		Code:     "syntax",
+		Source:   "scroopy",
+		Message:  "expected next token to be '=', got 'INT' instead",
+	}}
+	assertDiagnostics(t, expected, params.Diagnostics)
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]interface{}{{"text": "let x = \"ж\" + y;\nprint(args);\n"}},
+	})
+	expected = []lsp.Diagnostic{
+		{
+			Range:    lsp.Range{Start: lsp.Position{Line: 0, Character: 4}, End: lsp.Position{Line: 0, Character: 5}},
+			Severity: lsp.SeverityWarning,
+			Code:     "unused-variable",
+			Source:   "scroopy",
+			Message:  "x is defined but never used",
+		},
+		{
+			// Characters are counted in UTF-16 code units rather than bytes.
+			Range:    lsp.Range{Start: lsp.Position{Line: 0, Character: 14}, End: lsp.Position{Line: 0, Character: 15}},
+			Severity: lsp.SeverityError,
+			Code:     "undefined",
+			Source:   "scroopy",
+			Message:  "undefined: y",
+		},
+	}
+	assertDiagnostics(t, expected, c.diagnostics().Diagnostics)
+
+	c.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}})
+	assertDiagnostics(t, nil, c.diagnostics().Diagnostics)
+}
+
+func assertDiagnostics(t *testing.T, expected, got []lsp.Diagnostic) {
+	t.Helper()
+
+	if len(got) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. expected=%d, got=%+v", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("wrong diagnostic %d. expected=%+v, got=%+v", i, expected[i], got[i])
+		}
+	}
+}
+
+const program = `let add = fn(x, y) { x + y };
+let total = add(1, 2);
+let twice = fn(f) { fn(v) { f(f(v)) } };
+print(total + len([total]));
+`
+
+func TestServer_Definition(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, program)
+
+	tests := []struct {
+		line, character int
+		expected        *lsp.Range
+	}{
+		// `total` in the last line refers to the second line.
+		{line: 3, character: 8, expected: &lsp.Range{
+			Start: lsp.Position{Line: 1, Character: 4}, End: lsp.Position{Line: 1, Character: 9},
+		}},
+		// The position right after the identifier counts too.
+		{line: 1, character: 15, expected: &lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 4}, End: lsp.Position{Line: 0, Character: 7},
+		}},
+		// Parameters used in nested functions.
+		{line: 2, character: 30, expected: &lsp.Range{
+			Start: lsp.Position{Line: 2, Character: 15}, End: lsp.Position{Line: 2, Character: 16},
+		}},
+		// Bindings refer to themselves.
+		{line: 0, character: 5, expected: &lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 4}, End: lsp.Position{Line: 0, Character: 7},
+		}},
+		// Build-in functions have no definition.
+		{line: 3, character: 16, expected: nil},
+		// Neither have literals.
+		{line: 1, character: 17, expected: nil},
+	}
+
+	for _, tt := range tests {
+		var location *lsp.Location
+		if err := c.call("textDocument/definition", positionParams(uri, tt.line, tt.character), &location); err != nil {
+			t.Fatalf("definition failed: %s", err)
+		}
+
+		switch {
+		case tt.expected == nil && location != nil:
+			t.Errorf("%d:%d: expected no definition, got=%+v", tt.line, tt.character, location)
+		case tt.expected != nil && location == nil:
+			t.Errorf("%d:%d: expected definition at %+v, got none", tt.line, tt.character, tt.expected)
+		case tt.expected != nil && (location.URI != uri || location.Range != *tt.expected):
+			t.Errorf("%d:%d: wrong definition. expected=%+v, got=%+v", tt.line, tt.character, tt.expected, location)
+		}
+	}
+}
+
+func TestServer_References(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, program)
+
+	params := positionParams(uri, 1, 6)
+	params["context"] = map[string]interface{}{"includeDeclaration": true}
+
+	var locations []lsp.Location
+	if err := c.call("textDocument/references", params, &locations); err != nil {
+		t.Fatalf("references failed: %s", err)
+	}
+
+	expected := []lsp.Position{{Line: 1, Character: 4}, {Line: 3, Character: 6}, {Line: 3, Character: 19}}
+	if len(locations) != len(expected) {
+		t.Fatalf("wrong number of references. expected=%d, got=%+v", len(expected), locations)
+	}
+	for i, location := range locations {
+		if location.Range.Start != expected[i] {
+			t.Errorf("wrong reference %d. expected=%+v, got=%+v", i, expected[i], location.Range.Start)
+		}
+	}
+}
+
+func TestServer_Hover(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, program)
+
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{line: 3, character: 17, expected: "```scroopy\nlen(value)\n```\n" +
+			"Returns the number of characters of a string or the number of elements of an array or a hashmap."},
+		{line: 1, character: 13, expected: "```scroopy\nlet add = fn(x, y)\n```"},
+		{line: 0, character: 13, expected: "```scroopy\nparameter x\n```"},
+		{line: 3, character: 8, expected: "```scroopy\nlet total\n```"},
+		{line: 1, character: 17, expected: ""},
+	}
+
+	for _, tt := range tests {
+		var hover *lsp.Hover
+		if err := c.call("textDocument/hover", positionParams(uri, tt.line, tt.character), &hover); err != nil {
+			t.Fatalf("hover failed: %s", err)
+		}
+
+		if tt.expected == "" {
+			if hover != nil {
+				t.Errorf("%d:%d: expected no hover, got=%+v", tt.line, tt.character, hover)
+			}
+
+			continue
+		}
+
+		if hover == nil || hover.Contents.Value != tt.expected || hover.Contents.Kind != "markdown" {
+			t.Errorf("%d:%d: wrong hover. expected=%q, got=%+v", tt.line, tt.character, tt.expected, hover)
+		}
+	}
+}
+
+func TestServer_Completion(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, program)
+
+	// Inside of the innermost function of `twice`.
+	var items []lsp.CompletionItem
+	if err := c.call("textDocument/completion", positionParams(uri, 2, 29), &items); err != nil {
+		t.Fatalf("completion failed: %s", err)
+	}
+
+	labels := make([]string, 0, len(items))
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	expected := "args v f add total twice print len first last rest push delete int float"
+	if strings.Join(labels, " ") != expected {
+		t.Errorf("wrong completion items. expected=%q, got=%q", expected, strings.Join(labels, " "))
+	}
+
+	if items[1].Kind != lsp.CompletionVariable || items[1].Detail != "parameter v" {
+		t.Errorf("wrong binding item. got=%+v", items[1])
+	}
+	if last := items[len(items)-1]; last.Kind != lsp.CompletionFunction || last.Detail != "float(value)" {
+		t.Errorf("wrong build-in item. got=%+v", last)
+	}
+
+	// Parameters aren't offered outside of their functions.
+	if err := c.call("textDocument/completion", positionParams(uri, 3, 0), &items); err != nil {
+		t.Fatalf("completion failed: %s", err)
+	}
+	if len(items) != 13 || items[1].Label != "add" {
+		t.Errorf("wrong top-level completion items. got=%+v", items)
+	}
+}
+
+func TestServer_Formatting(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, "let add=fn(x,y){x+y}\n")
+
+	params := map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}
+
+	var edits []lsp.TextEdit
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+
+	expected := lsp.TextEdit{
+		Range:   lsp.Range{Start: lsp.Position{}, End: lsp.Position{Line: 1, Character: 0}},
+		NewText: "let add = fn(x, y) {\n  x + y;\n};\n",
+	}
+	if len(edits) != 1 || edits[0] != expected {
+		t.Errorf("wrong edits. expected=%+v, got=%+v", expected, edits)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]interface{}{{"text": "let x 1;"}},
+	})
+	c.diagnostics()
+
+	if err := c.call("textDocument/formatting", params, &edits); err == nil || err.Code != -32803 {
+		t.Errorf("expected the request failed error, got=%v", err)
+	}
+}
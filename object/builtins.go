@@ -0,0 +1,282 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stdout is the writer `print` writes values to.
+var Stdout io.Writer = os.Stdout
+
+// Builtins is the list of build-in functions available to every program.
+// Compiled programs refer to build-in functions by their index in the list,
+// so new functions must be appended to the end.
+var Builtins = []struct {
+	Name      string
+	Signature string // how the function is called, shown in editors
+	Doc       string // what the function does, shown in editors
+	BuildIn   *BuildIn
+}{
+	{
+		Name:      "print",
+		Signature: "print(values...)",
+		Doc:       "Prints every value on its own line.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			return printValues(Stdout, args)
+		}},
+	},
+	// TODO: add set func for hashmaps
+	{
+		Name:      "len",
+		Signature: "len(value)",
+		Doc:       "Returns the number of characters of a string or the number of elements of an array or a hashmap.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+			}
+
+			// TODO: add an interface for objects that support len funcs
+			switch arg := args[0].(type) {
+			case *String:
+				return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *HashMap:
+				return &Integer{Value: int64(len(arg.Pairs))}
+			default:
+				return newError("argument to `len` not supported, got %s", args[0].Type())
+			}
+		}},
+	},
+	{
+		Name:      "first",
+		Signature: "first(array)",
+		Doc:       "Returns the first element of the array, null if it's empty.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+			}
+
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arrayObj := args[0].(*Array)
+			if len(arrayObj.Elements) > 0 {
+				return arrayObj.Elements[0]
+			}
+
+			return NULL
+		}},
+	},
+	{
+		Name:      "last",
+		Signature: "last(array)",
+		Doc:       "Returns the last element of the array, null if it's empty.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+			}
+
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arrayObj := args[0].(*Array)
+			arrayLength := len(arrayObj.Elements)
+			if arrayLength > 0 {
+				return arrayObj.Elements[arrayLength-1]
+			}
+
+			return NULL
+		}},
+	},
+	{
+		Name:      "rest",
+		Signature: "rest(array)",
+		Doc:       "Returns a new array holding all elements of the array but the first one, null if it's empty.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+			}
+
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arrayObj := args[0].(*Array)
+			arrayLength := len(arrayObj.Elements)
+			if arrayLength > 0 {
+				newElements := make([]Object, arrayLength-1)
+				copy(newElements, arrayObj.Elements[1:arrayLength])
+
+				return &Array{Elements: newElements}
+			}
+
+			return NULL
+		}},
+	},
+	{
+		Name:      "push",
+		Signature: "push(array, value)",
+		Doc:       "Returns a new array holding elements of the array followed by the value.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", lenArgs)
+			}
+
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arrayObj := args[0].(*Array)
+			arrayLength := len(arrayObj.Elements)
+			newArray := make([]Object, arrayLength+1)
+			copy(newArray, arrayObj.Elements)
+			newArray[arrayLength] = args[1]
+
+			return &Array{Elements: newArray}
+		}},
+	},
+	{
+		Name:      "delete",
+		Signature: "delete(hashmap, key)",
+		Doc:       "Removes the key from the hashmap and returns the hashmap.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", lenArgs)
+			}
+
+			if args[0].Type() != HashObj {
+				return newError("argument to `delete` must be HASHMAP, got %s", args[0].Type())
+			}
+
+			hashable, ok := args[1].(Hashable)
+			if !ok {
+				return newError("second argument to `delete` must be HASHABLE, got %s", args[1].Type())
+			}
+
+			hmObj := args[0].(*HashMap)
+			delete(hmObj.Pairs, hashable.HashKey())
+
+			return hmObj
+		}},
+	},
+	{
+		Name:      "int",
+		Signature: "int(value)",
+		Doc:       "Converts an integer, a float truncating it towards zero or a decimal string to an integer.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer, *BigInt:
+				return arg
+			case *Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+
+				// Conversion truncates towards zero.
+				value, _ := big.NewFloat(arg.Value).Int(nil)
+
+				return NewInteger(value)
+			case *String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+				if !ok {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+
+				return NewInteger(value)
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		}},
+	},
+	{
+		Name:      "float",
+		Signature: "float(value)",
+		Doc:       "Converts a number or a string to a float.",
+		BuildIn: &BuildIn{Fn: func(args ...Object) Object {
+			lenArgs := len(args)
+			if lenArgs != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer, *BigInt:
+				return &Float{Value: toFloat(arg)}
+			case *Float:
+				return arg
+			case *String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("cannot convert %s to FLOAT", arg.Inspect())
+				}
+
+				return &Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		}},
+	},
+}
+
+// GetBuildInByName returns the build-in function with the given name,
+// nil is returned if there's no such function.
+func GetBuildInByName(name string) *BuildIn {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.BuildIn
+		}
+	}
+
+	return nil
+}
+
+// NewPrint returns the `print` build-in function writing to the writer
+// instead of Stdout.
+func NewPrint(w io.Writer) *BuildIn {
+	return &BuildIn{Fn: func(args ...Object) Object {
+		return printValues(w, args)
+	}}
+}
+
+// BuiltinsWritingTo returns the build-in functions by name with `print`
+// writing to the writer instead of Stdout.
+func BuiltinsWritingTo(w io.Writer) map[string]*BuildIn {
+	builtins := make(map[string]*BuildIn, len(Builtins))
+	for _, def := range Builtins {
+		builtins[def.Name] = def.BuildIn
+	}
+	builtins["print"] = NewPrint(w)
+
+	return builtins
+}
+
+func printValues(w io.Writer, values []Object) Object {
+	for _, value := range values {
+		_, _ = fmt.Fprintln(w, value.Inspect())
+	}
+
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 // Environment represents a local environment that keeps track
 // of identifiers and their values within a session.
 type Environment struct {
@@ -23,16 +25,59 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// Get returns the value of the name in the nearest environment it's defined in,
+// walking up the chain of enclosing environments.
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name] // look up in inner scope
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.store[name]
-	}
+	obj, _, ok := e.Lookup(name)
 
 	return obj, ok
 }
+
+// Lookup returns the value of the name along with the nearest environment
+// it's defined in, false is returned if the name is not defined at all.
+func (e *Environment) Lookup(name string) (Object, *Environment, bool) {
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.store[name]; ok {
+			return obj, env, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// Names returns the sorted names defined in the environment itself,
+// names of the enclosing environments are not included.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Parent returns the enclosing environment, nil is returned for
+// the outermost one.
+func (e *Environment) Parent() *Environment {
+	return e.outer
+}
+
+// Set binds the value to the name in the environment itself.
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 
 	return val
 }
+
+// Assign updates the value of the name in the nearest environment it's
+// defined in, false is returned if the name is not defined at all.
+func (e *Environment) Assign(name string, val Object) bool {
+	_, env, ok := e.Lookup(name)
+	if !ok {
+		return false
+	}
+	env.store[name] = val
+
+	return true
+}
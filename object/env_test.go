@@ -0,0 +1,91 @@
+package object_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dstdfx/scroopy/object"
+)
+
+func TestEnvironmentLookup(t *testing.T) {
+	global := object.NewEnvironment()
+	global.Set("a", &object.Integer{Value: 1})
+
+	outer := object.NewEnclosedEnvironment(global)
+	outer.Set("b", &object.Integer{Value: 2})
+
+	inner := object.NewEnclosedEnvironment(object.NewEnclosedEnvironment(outer))
+	inner.Set("b", &object.Integer{Value: 3})
+
+	tests := []struct {
+		name          string
+		expectedValue int64
+		expectedEnv   *object.Environment
+	}{
+		{"a", 1, global},
+		{"b", 3, inner},
+	}
+
+	for _, tt := range tests {
+		obj, env, ok := inner.Lookup(tt.name)
+		if !ok {
+			t.Fatalf("%s is not found", tt.name)
+		}
+		if obj.(*object.Integer).Value != tt.expectedValue {
+			t.Errorf("wrong value of %s. want=%d, got=%s", tt.name, tt.expectedValue, obj.Inspect())
+		}
+		if env != tt.expectedEnv {
+			t.Errorf("%s is found in wrong environment", tt.name)
+		}
+
+		if got, ok := inner.Get(tt.name); !ok || got != obj {
+			t.Errorf("Get(%s) disagrees with Lookup. got=%v", tt.name, got)
+		}
+	}
+
+	if _, _, ok := inner.Lookup("c"); ok {
+		t.Errorf("undefined name is found")
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	global := object.NewEnvironment()
+	global.Set("a", &object.Integer{Value: 1})
+	inner := object.NewEnclosedEnvironment(object.NewEnclosedEnvironment(global))
+
+	if !inner.Assign("a", &object.Integer{Value: 2}) {
+		t.Fatalf("Assign failed")
+	}
+	if len(inner.Names()) != 0 {
+		t.Errorf("Assign defined the name in the inner environment: %v", inner.Names())
+	}
+	if obj, _ := global.Get("a"); obj.Inspect() != "2" {
+		t.Errorf("wrong value. want=2, got=%s", obj.Inspect())
+	}
+
+	if inner.Assign("b", object.NULL) {
+		t.Errorf("undefined name is assigned")
+	}
+}
+
+func TestEnvironmentNamesAndParent(t *testing.T) {
+	global := object.NewEnvironment()
+	global.Set("z", object.NULL)
+	global.Set("a", object.NULL)
+	inner := object.NewEnclosedEnvironment(global)
+	inner.Set("m", object.NULL)
+
+	if names := global.Names(); !reflect.DeepEqual(names, []string{"a", "z"}) {
+		t.Errorf("wrong names. got=%v", names)
+	}
+	if names := inner.Names(); !reflect.DeepEqual(names, []string{"m"}) {
+		t.Errorf("wrong names. got=%v", names)
+	}
+
+	if inner.Parent() != global {
+		t.Errorf("wrong parent of the enclosed environment")
+	}
+	if global.Parent() != nil {
+		t.Errorf("outermost environment has parent. got=%v", global.Parent())
+	}
+}
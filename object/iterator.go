@@ -0,0 +1,112 @@
+package object
+
+import "sort"
+
+// Iterator walks over elements of an array, characters of a string or
+// pairs of a hash map, the way `for` loops do.
+type Iterator struct {
+	keys   []Object // indexes of arrays and strings, keys of hash maps
+	values []Object // elements of arrays, characters of strings, values of hash maps
+	// singles holds what a loop with a single variable binds:
+	// values of arrays and strings, keys of hash maps.
+	singles []Object
+	pos     int
+}
+
+func (it *Iterator) Type() Type {
+	return IteratorObj
+}
+
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+// NewIterator returns new instance of Iterator over the given object,
+// false is returned if the object is not iterable.
+// Hash maps are iterated in the order of their keys, so the order doesn't
+// depend on the way pairs are stored.
+func NewIterator(obj Object) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		keys := make([]Object, len(obj.Elements))
+		for i := range obj.Elements {
+			keys[i] = &Integer{Value: int64(i)}
+		}
+
+		return &Iterator{keys: keys, values: obj.Elements, singles: obj.Elements}, true
+	case *String:
+		keys := make([]Object, 0, len(obj.Value))
+		values := make([]Object, 0, len(obj.Value))
+		for _, r := range obj.Value {
+			keys = append(keys, &Integer{Value: int64(len(keys))})
+			values = append(values, &String{Value: string(r)})
+		}
+
+		return &Iterator{keys: keys, values: values, singles: values}, true
+	case *HashMap:
+		pairs := make([]HashPair, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return lessKey(pairs[i].Key, pairs[j].Key)
+		})
+
+		keys := make([]Object, len(pairs))
+		values := make([]Object, len(pairs))
+		for i, pair := range pairs {
+			keys[i] = pair.Key
+			values[i] = pair.Value
+		}
+
+		return &Iterator{keys: keys, values: values, singles: keys}, true
+	default:
+		return nil, false
+	}
+}
+
+// Next returns the next object a loop with a single variable binds.
+func (it *Iterator) Next() (Object, bool) {
+	if it.pos >= len(it.singles) {
+		return nil, false
+	}
+	it.pos++
+
+	return it.singles[it.pos-1], true
+}
+
+// NextPair returns the next key and value a loop with two variables binds.
+func (it *Iterator) NextPair() (Object, Object, bool) {
+	if it.pos >= len(it.keys) {
+		return nil, nil, false
+	}
+	it.pos++
+
+	return it.keys[it.pos-1], it.values[it.pos-1], true
+}
+
+// lessKey orders hash keys by type, then by value.
+func lessKey(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value
+		}
+
+		return toBig(a).Cmp(toBig(b)) < 0
+	case *BigInt:
+		return a.Value.Cmp(toBig(b)) < 0
+	case *Float:
+		return a.Value < b.(*Float).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	default:
+		return a.Inspect() < b.Inspect()
+	}
+}
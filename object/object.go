@@ -3,13 +3,19 @@ package object
 import (
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/code"
+	"github.com/dstdfx/scroopy/token"
 )
 
 const (
 	IntegerObj     Type = "INTEGER"
+	FloatObj       Type = "FLOAT"
 	BooleanObj     Type = "BOOLEAN"
 	NullObj        Type = "NULL"
 	StringObj      Type = "STRING"
@@ -19,12 +25,21 @@ const (
 	BuildInObj          = "BUILDIN"
 	ArrayObj            = "ARRAY"
 	HashObj             = "HASHMAP"
+
+	CompiledFunctionObj = "COMPILED_FUNCTION"
+	BreakObj            = "BREAK"
+	ContinueObj         = "CONTINUE"
+	IteratorObj         = "ITERATOR"
+	QuoteObj            = "QUOTE"
+	MacroObj            = "MACRO"
 )
 
 var (
-	NULL  = &Null{}
-	TRUE  = &Boolean{Value: true}
-	FALSE = &Boolean{Value: false}
+	NULL     = &Null{}
+	TRUE     = &Boolean{Value: true}
+	FALSE    = &Boolean{Value: false}
+	BREAK    = &Break{}
+	CONTINUE = &Continue{}
 )
 
 // Type represents object's type.
@@ -49,6 +64,50 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// BigInt represents an arbitrary-precision integer. Integer operations
+// overflowing int64 produce BigInt, which is of the same INTEGER type,
+// results fitting int64 are Integer again.
+type BigInt struct {
+	Value *big.Int
+}
+
+// NewInteger returns Integer if the value fits int64, BigInt otherwise.
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInt{Value: value}
+}
+
+func (b *BigInt) Type() Type {
+	return IntegerObj
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+// Float represents floating-point number type.
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() Type {
+	return FloatObj
+}
+
+// Inspect returns the shortest representation of the number that keeps
+// it distinguishable from integers, e.g. "2.0" rather than "2".
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+
+	return s
+}
+
 // Boolean represents boolean type.
 type Boolean struct {
 	Value bool
@@ -99,9 +158,34 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Break represents `break` statement being evaluated.
+type Break struct{}
+
+func (b *Break) Type() Type {
+	return BreakObj
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+// Continue represents `continue` statement being evaluated.
+type Continue struct{}
+
+func (c *Continue) Type() Type {
+	return ContinueObj
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
 // Error represents an error object.
 type Error struct {
 	Message string
+	Pos     token.Position // position of the node that produced the error
+	Trace   []Frame        // call stack at the moment of the error, innermost call first
+	Cause   error          // sentinel error the error is raised for, nil for runtime errors of the program
 }
 
 func (e *Error) Type() Type {
@@ -109,12 +193,59 @@ func (e *Error) Type() Type {
 }
 
 func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("ERROR: %s: %s", e.Pos, e.Message)
+	}
+
 	return fmt.Sprintf("ERROR: %s", e.Message)
 }
 
+// Traceback returns the call stack of the error, one frame per line,
+// innermost call first. Runs of identical frames, e.g. of a runaway recursion,
+// are printed once followed by the number of repetitions. An empty string
+// is returned for errors raised outside of any function.
+func (e *Error) Traceback() string {
+	strBuilder := strings.Builder{}
+
+	for i := 0; i < len(e.Trace); {
+		frame := e.Trace[i]
+		repeated := 0
+		for i++; i < len(e.Trace) && e.Trace[i] == frame; i++ {
+			repeated++
+		}
+
+		strBuilder.WriteString("\tat ")
+		strBuilder.WriteString(frame.String())
+		strBuilder.WriteByte('\n')
+		if repeated > 0 {
+			fmt.Fprintf(&strBuilder, "\t... repeated %d more times\n", repeated)
+		}
+	}
+
+	return strBuilder.String()
+}
+
+// Frame represents a single function call in the call stack.
+type Frame struct {
+	Function string         // name the function is bound to, empty if anonymous
+	Pos      token.Position // position of the call expression
+}
+
+func (f Frame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	return fmt.Sprintf("%s (called at %s)", name, f.Pos)
+}
+
 // Function represents a function.
 type Function struct {
+	Name       string // name the function was first bound to with `let`, if any
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default values of the parameters, nil entries for required ones
+	Rest       *ast.Identifier  // rest parameter collecting the extra arguments, if any
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -127,9 +258,17 @@ func (f *Function) Inspect() string {
 	strBuilder := strings.Builder{}
 
 	params := make([]string, 0)
-	for _, p := range f.Parameters {
+	for i, p := range f.Parameters {
+		if f.Defaults != nil && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+
+			continue
+		}
 		params = append(params, p.String())
 	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
 
 	strBuilder.WriteString("fn")
 	strBuilder.WriteByte('(')
@@ -141,6 +280,81 @@ func (f *Function) Inspect() string {
 	return strBuilder.String()
 }
 
+// CheckArity returns an error if the number of arguments doesn't fit
+// a function with the given number of required and optional parameters,
+// a variadic function accepts any number of extra arguments.
+func CheckArity(required, optional int, variadic bool, got int) *Error {
+	switch {
+	case got < required && (optional > 0 || variadic):
+		return newError("wrong number of arguments: want at least %d, got=%d", required, got)
+	case got > required+optional && !variadic && optional > 0:
+		return newError("wrong number of arguments: want at most %d, got=%d", required+optional, got)
+	case (got < required || got > required+optional) && !variadic:
+		return newError("wrong number of arguments: want=%d, got=%d", required, got)
+	}
+
+	return nil
+}
+
+// CompiledFunction represents a function compiled to bytecode.
+type CompiledFunction struct {
+	Name          string // name the function literal is bound to with `let`, if any
+	Instructions  code.Instructions
+	SourceMap     code.SourceMap
+	NumLocals     int
+	NumParameters int       // number of positional parameters, the rest parameter is not counted
+	NumDefaults   int       // number of trailing positional parameters with default values
+	Variadic      bool      // whether the function has a rest parameter
+	Captures      []Capture // variables of enclosing functions the function refers to
+}
+
+func (cf *CompiledFunction) Type() Type {
+	return CompiledFunctionObj
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Capture describes where a closure takes a free variable from when it's
+// created: a local variable of the enclosing function or a free variable
+// of the enclosing closure.
+type Capture struct {
+	IsLocal bool
+	Index   int
+}
+
+// Closure represents a compiled function along with the variables
+// it captured.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Upvalue
+}
+
+// Type returns FunctionObj, closures are the functions of compiled programs.
+func (c *Closure) Type() Type {
+	return FunctionObj
+}
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Upvalue represents a variable captured by closures. While the function
+// declaring the variable is running, Location points to the variable's slot
+// on the stack, after that the value is moved to Closed, so every closure
+// sharing the upvalue keeps seeing the same variable.
+type Upvalue struct {
+	Location *Object
+	Closed   Object
+}
+
+// Close moves the captured value off the stack.
+func (u *Upvalue) Close() {
+	u.Closed = *u.Location
+	u.Location = &u.Closed
+}
+
 // BuildInFunction represents build-in function definition.
 type BuildInFunction func(args ...Object) Object
 
@@ -207,6 +421,27 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey of BigInt equals to the one of Integer of the same value.
+func (b *BigInt) HashKey() HashKey {
+	if b.Value.IsInt64() {
+		return (&Integer{Value: b.Value.Int64()}).HashKey()
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	if value == 0 {
+		value = 0 // -0.0 and 0.0 are equal, so must be their keys
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -251,3 +486,46 @@ func (h *HashMap) Inspect() string {
 
 	return strBuilder.String()
 }
+
+// Quote represents an unevaluated AST node produced by `quote`.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() Type {
+	return QuoteObj
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// Macro represents a macro bound to a name, its body is evaluated with
+// the quoted arguments of the calls the macro is expanded at.
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() Type {
+	return MacroObj
+}
+
+func (m *Macro) Inspect() string {
+	strBuilder := strings.Builder{}
+
+	params := make([]string, 0)
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	strBuilder.WriteString("macro")
+	strBuilder.WriteByte('(')
+	strBuilder.WriteString(strings.Join(params, ", "))
+	strBuilder.WriteString(") {\n")
+	strBuilder.WriteString(m.Body.String())
+	strBuilder.WriteString("\n}")
+
+	return strBuilder.String()
+}
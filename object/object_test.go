@@ -1,6 +1,8 @@
 package object_test
 
 import (
+	"math"
+	"math/big"
 	"testing"
 
 	"github.com/dstdfx/scroopy/object"
@@ -24,3 +26,56 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &object.Float{Value: 0.5}
+	half2 := &object.Float{Value: 0.5}
+	zero := &object.Float{Value: 0}
+	negativeZero := &object.Float{Value: math.Copysign(0, -1)}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+
+	if zero.HashKey() != negativeZero.HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+
+	if half1.HashKey() == zero.HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+
+	if (&object.Integer{Value: 1}).HashKey() == (&object.Float{Value: 1}).HashKey() {
+		t.Errorf("integer and float have same hash keys")
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	small := &object.Integer{Value: 42}
+	smallBig := &object.BigInt{Value: big.NewInt(42)}
+	huge1, _ := new(big.Int).SetString("18446744073709551616", 10)
+	huge2, _ := new(big.Int).SetString("18446744073709551616", 10)
+
+	if small.HashKey() != smallBig.HashKey() {
+		t.Errorf("integer and big integer with same value have different hash keys")
+	}
+
+	if (&object.BigInt{Value: huge1}).HashKey() != (&object.BigInt{Value: huge2}).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+
+	if (&object.BigInt{Value: huge1}).HashKey() == smallBig.HashKey() {
+		t.Errorf("big integers with different values have same hash keys")
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	if _, ok := object.NewInteger(big.NewInt(-7)).(*object.Integer); !ok {
+		t.Errorf("value fitting int64 isn't Integer")
+	}
+
+	huge, _ := new(big.Int).SetString("-9223372036854775809", 10)
+	if obj, ok := object.NewInteger(huge).(*object.BigInt); !ok || obj.Type() != object.IntegerObj {
+		t.Errorf("value out of int64 range isn't BigInt of INTEGER type")
+	}
+}
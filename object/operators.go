@@ -0,0 +1,520 @@
+package object
+
+import (
+	"math"
+	"math/big"
+)
+
+// InfixOperation applies the infix operator to the given operands.
+func InfixOperation(op string, left, right Object) Object {
+	switch {
+	case left.Type() == IntegerObj && right.Type() == IntegerObj:
+		return integerInfixOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		// Integers are promoted to floats if any of the operands is a float.
+		return floatInfixOperation(op, left, right)
+	case left.Type() == StringObj && right.Type() == StringObj:
+		return stringInfixOperation(op, left, right)
+	case op == "==" || op == "!=":
+		// boolean [infix op] integer
+		if left.Type() == IntegerObj && right.Type() == BooleanObj {
+			return integerBooleanInfixOperation(op, left, right)
+		}
+
+		// integer [infix op] boolean
+		if left.Type() == BooleanObj && right.Type() == IntegerObj {
+			return integerBooleanInfixOperation(op, right, left)
+		}
+
+		var boolean bool
+		if op == "==" {
+			boolean = left == right
+		} else {
+			boolean = left != right
+		}
+
+		return NativeBoolToBooleanObject(boolean)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+func stringInfixOperation(op string, left, right Object) Object {
+	leftVal := left.(*String)
+	rightVal := right.(*String)
+
+	switch op {
+	case "+":
+		return &String{Value: leftVal.Value + rightVal.Value}
+	case "==":
+		return NativeBoolToBooleanObject(leftVal.Value == rightVal.Value)
+	case "!=":
+		return NativeBoolToBooleanObject(leftVal.Value != rightVal.Value)
+	case ">":
+		return NativeBoolToBooleanObject(leftVal.Value > rightVal.Value)
+	case "<":
+		return NativeBoolToBooleanObject(leftVal.Value < rightVal.Value)
+	case ">=":
+		return NativeBoolToBooleanObject(leftVal.Value >= rightVal.Value)
+	case "<=":
+		return NativeBoolToBooleanObject(leftVal.Value <= rightVal.Value)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+// integerInfixOperation applies the operator to int64 values, arithmetic
+// overflowing int64 is done by bigIntInfixOperation instead.
+func integerInfixOperation(op string, left, right Object) Object {
+	leftVal, leftOk := left.(*Integer)
+	rightVal, rightOk := right.(*Integer)
+	if !leftOk || !rightOk {
+		return bigIntInfixOperation(op, left, right)
+	}
+
+	switch op {
+	case "+":
+		if sum := leftVal.Value + rightVal.Value; (sum > leftVal.Value) == (rightVal.Value > 0) {
+			return &Integer{Value: sum}
+		}
+
+		return bigIntInfixOperation(op, left, right)
+	case "-":
+		if diff := leftVal.Value - rightVal.Value; (diff < leftVal.Value) == (rightVal.Value > 0) {
+			return &Integer{Value: diff}
+		}
+
+		return bigIntInfixOperation(op, left, right)
+	case "/":
+		if rightVal.Value == 0 {
+			return newError("division by zero")
+		}
+
+		if leftVal.Value == math.MinInt64 && rightVal.Value == -1 {
+			return bigIntInfixOperation(op, left, right)
+		}
+
+		return &Integer{Value: floorDiv(leftVal.Value, rightVal.Value)}
+	case "%":
+		if rightVal.Value == 0 {
+			return newError("division by zero")
+		}
+
+		return &Integer{Value: floorMod(leftVal.Value, rightVal.Value)}
+	case "*":
+		if product, ok := multiplyInt64(leftVal.Value, rightVal.Value); ok {
+			return &Integer{Value: product}
+		}
+
+		return bigIntInfixOperation(op, left, right)
+	case "**":
+		if rightVal.Value < 0 {
+			return negativePow(left, right)
+		}
+
+		if power, ok := integerPow(leftVal.Value, rightVal.Value); ok {
+			return &Integer{Value: power}
+		}
+
+		return bigIntInfixOperation(op, left, right)
+	case ">":
+		return NativeBoolToBooleanObject(leftVal.Value > rightVal.Value)
+	case "<":
+		return NativeBoolToBooleanObject(leftVal.Value < rightVal.Value)
+	case ">=":
+		return NativeBoolToBooleanObject(leftVal.Value >= rightVal.Value)
+	case "<=":
+		return NativeBoolToBooleanObject(leftVal.Value <= rightVal.Value)
+	case "==":
+		return NativeBoolToBooleanObject(leftVal.Value == rightVal.Value)
+	case "!=":
+		return NativeBoolToBooleanObject(leftVal.Value != rightVal.Value)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+func bigIntInfixOperation(op string, left, right Object) Object {
+	leftVal := toBig(left)
+	rightVal := toBig(right)
+
+	switch op {
+	case "+":
+		return NewInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return NewInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "/", "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+
+		quotient, remainder := new(big.Int).QuoRem(leftVal, rightVal, new(big.Int))
+		// Round the quotient towards negative infinity, the remainder
+		// takes the sign of the divisor then.
+		if remainder.Sign() != 0 && remainder.Sign() != rightVal.Sign() {
+			quotient.Sub(quotient, big.NewInt(1))
+			remainder.Add(remainder, rightVal)
+		}
+
+		if op == "/" {
+			return NewInteger(quotient)
+		}
+
+		return NewInteger(remainder)
+	case "*":
+		return NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "**":
+		if rightVal.Sign() < 0 {
+			return negativePow(left, right)
+		}
+
+		if !powFits(leftVal, rightVal) {
+			return newError("result of ** is too large: exceeds %d bits", MaxPowBits)
+		}
+
+		return NewInteger(new(big.Int).Exp(leftVal, rightVal, nil))
+	case ">":
+		return NativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<":
+		return NativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">=":
+		return NativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "<=":
+		return NativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case "==":
+		return NativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return NativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+func floatInfixOperation(op string, left, right Object) Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch op {
+	case "+":
+		return &Float{Value: leftVal + rightVal}
+	case "-":
+		return &Float{Value: leftVal - rightVal}
+	case "/":
+		return &Float{Value: leftVal / rightVal}
+	case "%":
+		mod := math.Mod(leftVal, rightVal)
+		if mod != 0 && (mod < 0) != (rightVal < 0) {
+			mod += rightVal
+		}
+
+		return &Float{Value: mod}
+	case "*":
+		return &Float{Value: leftVal * rightVal}
+	case "**":
+		return &Float{Value: math.Pow(leftVal, rightVal)}
+	case ">":
+		return NativeBoolToBooleanObject(leftVal > rightVal)
+	case "<":
+		return NativeBoolToBooleanObject(leftVal < rightVal)
+	case ">=":
+		return NativeBoolToBooleanObject(leftVal >= rightVal)
+	case "<=":
+		return NativeBoolToBooleanObject(leftVal <= rightVal)
+	case "==":
+		return NativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return NativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+// floorDiv returns the quotient rounded towards negative infinity,
+// e.g. -7 / 2 is -4.
+func floorDiv(a, b int64) int64 {
+	quotient := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		quotient--
+	}
+
+	return quotient
+}
+
+// floorMod returns the remainder of floorDiv, which has the sign of the
+// divisor, e.g. -7 % 2 is 1, so a == b * (a / b) + a % b holds.
+func floorMod(a, b int64) int64 {
+	mod := a % b
+	if mod != 0 && (mod < 0) != (b < 0) {
+		mod += b
+	}
+
+	return mod
+}
+
+// negativePow raises the integer to the negative exponent, the result is float.
+func negativePow(base, exponent Object) Object {
+	if toBig(base).Sign() == 0 {
+		return newError("division by zero")
+	}
+
+	return &Float{Value: math.Pow(toFloat(base), toFloat(exponent))}
+}
+
+// MaxPowBits is the maximum size of integers `**` produces, larger results
+// are refused since computing them could take the process down.
+const MaxPowBits = 1 << 20
+
+// powFits reports whether base raised to the non-negative exponent
+// takes MaxPowBits at most. Bases 0, 1 and -1 fit for any exponent.
+func powFits(base, exponent *big.Int) bool {
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		return true
+	}
+	if !exponent.IsInt64() {
+		return false
+	}
+
+	// |base| >= 2**(bits-1), so the result takes at least (bits-1)*exponent bits.
+	bits := int64(base.BitLen() - 1)
+
+	return exponent.Int64() <= MaxPowBits/bits
+}
+
+// integerPow raises base to the non-negative exponent by squaring,
+// false is returned if the result overflows int64.
+func integerPow(base, exponent int64) (int64, bool) {
+	result := int64(1)
+
+	var ok bool
+	for {
+		if exponent&1 == 1 {
+			if result, ok = multiplyInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+
+		exponent >>= 1
+		if exponent == 0 {
+			return result, true
+		}
+
+		if base, ok = multiplyInt64(base, base); !ok {
+			return 0, false
+		}
+	}
+}
+
+// multiplyInt64 returns the product, false is returned if it overflows int64.
+func multiplyInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	product := a * b
+	if product/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64 {
+		return 0, false
+	}
+
+	return product, true
+}
+
+func isNumber(obj Object) bool {
+	return obj.Type() == IntegerObj || obj.Type() == FloatObj
+}
+
+// toFloat converts the number to float64, the object must be
+// either Integer, BigInt or Float.
+func toFloat(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value)
+	case *BigInt:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+
+		return value
+	default:
+		return obj.(*Float).Value
+	}
+}
+
+// toBig converts the integer to *big.Int, the object must be
+// either Integer or BigInt.
+func toBig(obj Object) *big.Int {
+	if integer, ok := obj.(*Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+
+	return obj.(*BigInt).Value
+}
+
+func integerBooleanInfixOperation(op string, left, right Object) Object {
+	nonZero := toBig(left).Sign() != 0
+	rightVal := right.(*Boolean)
+	switch op {
+	case "==":
+		return NativeBoolToBooleanObject(nonZero == rightVal.Value)
+	case "!=":
+		return NativeBoolToBooleanObject(nonZero != rightVal.Value)
+	default:
+		return NULL
+	}
+}
+
+// PrefixOperation applies the prefix operator to the given operand.
+func PrefixOperation(op string, right Object) Object {
+	switch op {
+	case "!":
+		return bangOperation(right)
+	case "-":
+		return minusOperation(right)
+	default:
+		return newError("unknown operator: %s%s", op, right.Type())
+	}
+}
+
+func bangOperation(right Object) Object {
+	switch right {
+	case TRUE:
+		return FALSE
+	case FALSE:
+		return TRUE
+	case NULL:
+		return TRUE
+	default:
+		return FALSE
+	}
+}
+
+func minusOperation(right Object) Object {
+	switch right := right.(type) {
+	case *Integer:
+		if right.Value == math.MinInt64 {
+			return NewInteger(new(big.Int).Neg(toBig(right)))
+		}
+
+		return &Integer{Value: -right.Value}
+	case *BigInt:
+		return NewInteger(new(big.Int).Neg(right.Value))
+	case *Float:
+		return &Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+// NativeBoolToBooleanObject returns TRUE or FALSE object for the given bool.
+func NativeBoolToBooleanObject(input bool) *Boolean {
+	if input {
+		return TRUE
+	}
+
+	return FALSE
+}
+
+// IsTruthy reports whether the object is considered true in conditions.
+func IsTruthy(obj Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+// IndexOperation returns the element of the array or the hash map
+// stored under the given index.
+func IndexOperation(left, index Object) Object {
+	switch {
+	case left.Type() == ArrayObj && index.Type() == IntegerObj:
+		return arrayIndexOperation(left, index)
+	case left.Type() == StringObj && index.Type() == IntegerObj:
+		return stringIndexOperation(left, index)
+	case left.Type() == HashObj:
+		return hashIndexOperation(left, index)
+	default:
+		// TODO: check index type and write appropriate error msg
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// SetIndexOperation stores the value in the array or the hash map under
+// the given index. An error is returned if the index can't be assigned,
+// nil otherwise.
+func SetIndexOperation(left, index, value Object) Object {
+	switch left := left.(type) {
+	case *Array:
+		if index.Type() != IntegerObj {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+
+		idx, ok := index.(*Integer)
+		if !ok || idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %s", index.Inspect())
+		}
+		left.Elements[idx.Value] = value
+
+		return nil
+	case *HashMap:
+		key, ok := index.(Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Pairs[key.HashKey()] = HashPair{Key: index, Value: value}
+
+		return nil
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func arrayIndexOperation(array, index Object) Object {
+	arrayObj := array.(*Array)
+	idx, ok := index.(*Integer)
+
+	if !ok || idx.Value < 0 || idx.Value > int64(len(arrayObj.Elements)-1) {
+		return NULL
+	}
+
+	return arrayObj.Elements[idx.Value]
+}
+
+// stringIndexOperation returns the character of the string at the index
+// counted in runes, not bytes.
+func stringIndexOperation(str, index Object) Object {
+	integer, ok := index.(*Integer)
+	if !ok || integer.Value < 0 {
+		return NULL
+	}
+
+	idx := integer.Value
+
+	for _, char := range str.(*String).Value {
+		if idx == 0 {
+			return &String{Value: string(char)}
+		}
+		idx--
+	}
+
+	return NULL
+}
+
+func hashIndexOperation(hashmap, index Object) Object {
+	hmObj := hashmap.(*HashMap)
+	key, ok := index.(Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hmObj.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
@@ -0,0 +1,104 @@
+package object
+
+import (
+	"strconv"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/token"
+)
+
+// IsCallOf reports whether the call calls the function bound to the name.
+func IsCallOf(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+
+	return ok && ident.Value == name
+}
+
+// UnquoteCalls returns the `unquote` calls of the quoted node in the order
+// their arguments are evaluated, arguments of the calls aren't searched.
+func UnquoteCalls(node ast.Node) []*ast.CallExpression {
+	calls := make([]*ast.CallExpression, 0)
+	ast.Inspect(node, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !IsCallOf(call, "unquote") {
+			return true
+		}
+		calls = append(calls, call)
+
+		return false
+	})
+
+	return calls
+}
+
+// QuoteCall returns the value of the `quote` call: a copy of its argument
+// unevaluated, except for the `unquote` calls inside of it, which are replaced
+// with the nodes representing the values of their arguments. The values are
+// given in the order of UnquoteCalls, up to the first `unquote` call with
+// a wrong number of arguments.
+func QuoteCall(call *ast.CallExpression, values []Object) Object {
+	if len(call.Arguments) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(call.Arguments))
+	}
+
+	node := ast.Copy(call.Arguments[0])
+
+	replacements := make(map[ast.Node]ast.Node)
+	for i, unquote := range UnquoteCalls(node) {
+		if len(unquote.Arguments) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(unquote.Arguments))
+		}
+
+		converted, ok := toNode(values[i], unquote.Pos())
+		if !ok {
+			return newError("cannot unquote %s", typeOf(values[i]))
+		}
+		replacements[unquote] = converted
+	}
+
+	return &Quote{Node: ast.Modify(node, func(node ast.Node) ast.Node {
+		if replacement, ok := replacements[node]; ok {
+			return replacement
+		}
+
+		return node
+	})}
+}
+
+// toNode returns the literal node representing the value,
+// the node is positioned at the given position.
+func toNode(obj Object, pos token.Position) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return &ast.IntegerLiteral{Token: literalToken(token.INT, obj.Inspect(), pos), Value: obj.Value}, true
+	case *BigInt:
+		return &ast.IntegerLiteral{Token: literalToken(token.INT, obj.Inspect(), pos), Big: obj.Value}, true
+	case *Float:
+		return &ast.FloatLiteral{Token: literalToken(token.FLOAT, obj.Inspect(), pos), Value: obj.Value}, true
+	case *Boolean:
+		tokenType := token.Type(token.FALSE)
+		if obj.Value {
+			tokenType = token.TRUE
+		}
+
+		return &ast.BooleanLiteral{Token: literalToken(tokenType, obj.Inspect(), pos), Value: obj.Value}, true
+	case *String:
+		return &ast.StringLiteral{Token: literalToken(token.STRING, strconv.Quote(obj.Value), pos), Value: obj.Value}, true
+	case *Quote:
+		return obj.Node, true
+	default:
+		return nil, false
+	}
+}
+
+func literalToken(tokenType token.Type, literal string, pos token.Position) token.Token {
+	return token.Token{Type: tokenType, Literal: literal, Pos: pos, End: pos}
+}
+
+func typeOf(obj Object) Type {
+	if obj == nil {
+		return NullObj
+	}
+
+	return obj.Type()
+}
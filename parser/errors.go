@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dstdfx/scroopy/token"
+)
+
+// Error represents a single syntax error found by the parser.
+type Error struct {
+	Pos      token.Position // position the error refers to
+	Expected []token.Type   // token types that would have been accepted, if known
+	Found    token.Token    // token found at the error position
+	Msg      string         // human readable description of the error
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList is a list of syntax errors in the order they were found.
+// ErrorList implements the error interface.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns an error equivalent to this error list.
+// If the list is empty, Err returns nil.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// Render returns errors of the list one per line, each followed by
+// the source line the error refers to and a caret under the offending column.
+func (l ErrorList) Render(src string) string {
+	strBuilder := strings.Builder{}
+
+	for _, e := range l {
+		strBuilder.WriteString(e.Error())
+		strBuilder.WriteByte('\n')
+
+		if excerpt := e.Pos.Excerpt(src); excerpt != "" {
+			strBuilder.WriteString(excerpt)
+			strBuilder.WriteByte('\n')
+		}
+	}
+
+	return strBuilder.String()
+}
+
+// bailout is used as a panic value to abandon parsing of the current
+// statement once a syntax error has been reported.
+type bailout struct{}
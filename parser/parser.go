@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/dstdfx/scroopy/ast"
 	"github.com/dstdfx/scroopy/lexer"
@@ -12,6 +15,8 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	LOGICALOR   // ||
+	LOGICALAND  // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
@@ -27,14 +32,29 @@ var precedences = map[token.Type]int{
 	token.NOTEQUAL: EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LTE:      LESSGREATER,
+	token.GTE:      LESSGREATER,
+	token.AND:      LOGICALAND,
+	token.OR:       LOGICALOR,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LBRACKET: INDEX,
 	token.POW:      PRODUCT,
 }
 
+// assignments maps assignment tokens to the infix operators they apply.
+var assignments = map[token.Type]string{
+	token.ASSIGN:         "",
+	token.PLUSASSIGN:     "+",
+	token.MINUSASSIGN:    "-",
+	token.ASTERISKASSIGN: "*",
+	token.SLASHASSIGN:    "/",
+	token.PERCENTASSIGN:  "%",
+}
+
 var ErrExpectedNextTokenFmt = "expected next token to be '%s', got '%s' instead"
 
 type (
@@ -48,7 +68,10 @@ type Parser struct {
 
 	currentToken token.Token
 	peekToken    token.Token
-	errors       []string
+	errors       ErrorList
+	comments     []*ast.Comment // comments skipped so far, if the lexer scans them
+	braceDepth   int            // number of `{` tokens not closed yet, up to the current token
+	loopDepth    int            // number of loops enclosing the current token within the current function
 
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
@@ -56,10 +79,11 @@ type Parser struct {
 
 // New returns new instance of Parser.
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: make([]string, 0)}
+	p := &Parser{l: l, errors: make(ErrorList, 0)}
 	p.prefixParseFns = make(map[token.Type]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -68,6 +92,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNC, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
@@ -76,10 +101,15 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOTEQUAL, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LTE, p.parseInfixExpression)
+	p.registerInfix(token.GTE, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.registerInfix(token.POW, p.parseInfixExpression)
@@ -94,45 +124,196 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekToken = p.l.NextToken()
+	}
+
+	switch p.currentToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		if p.braceDepth > 0 {
+			p.braceDepth--
+		}
+	}
 }
 
-// Errors method returns a slice of encountered errors.
-func (p *Parser) Errors() []string {
+// Errors method returns a list of encountered errors.
+func (p *Parser) Errors() ErrorList {
 	return p.errors
 }
 
-func (p *Parser) peekError(t token.Type) {
-	p.errors = append(p.errors, fmt.Sprintf(ErrExpectedNextTokenFmt, t, p.peekToken.Type))
+func (p *Parser) peekError(expected ...token.Type) {
+	p.illegalTokenError(p.peekToken)
+
+	msg := fmt.Sprintf(ErrExpectedNextTokenFmt, expected[0], p.peekToken.Type)
+	if len(expected) > 1 {
+		alternatives := make([]string, 0, len(expected))
+		for _, t := range expected {
+			alternatives = append(alternatives, "'"+string(t)+"'")
+		}
+		msg = fmt.Sprintf("expected next token to be one of %s, got '%s' instead",
+			strings.Join(alternatives, ", "), p.peekToken.Type)
+	}
+
+	p.fail(&Error{
+		Pos:      p.peekToken.Pos,
+		Expected: expected,
+		Found:    p.peekToken,
+		Msg:      msg,
+	})
+}
+
+// illegalTokenError reports the error the lexer found within the token,
+// if the token is illegal because of it.
+func (p *Parser) illegalTokenError(tok token.Token) {
+	if tok.Type != token.ILLEGAL {
+		return
+	}
+
+	for _, err := range p.l.Errors() {
+		if err.Pos.Offset >= tok.Pos.Offset && err.Pos.Offset < tok.End.Offset {
+			p.fail(&Error{Pos: err.Pos, Found: tok, Msg: err.Msg})
+		}
+	}
+}
+
+// errorf reports an error found at the current token.
+func (p *Parser) errorf(format string, args ...interface{}) {
+	p.fail(&Error{
+		Pos:   p.currentToken.Pos,
+		Found: p.currentToken,
+		Msg:   fmt.Sprintf(format, args...),
+	})
+}
+
+// fail records the error and abandons parsing of the current statement,
+// the statement list being parsed recovers from it by calling synchronize.
+func (p *Parser) fail(err *Error) {
+	p.errors = append(p.errors, err)
+
+	panic(bailout{})
 }
 
 // ParseProgram method parses the program and builds AST.
 func (p *Parser) ParseProgram() *ast.Root {
 	root := &ast.Root{}
-	root.Statements = make([]ast.Statement, 0)
-
-	for p.currentToken.Type != token.EOF {
-		root.Statements = append(root.Statements, p.parseStatement())
+	root.Statements = p.parseStatementList(0)
+
+	// A statement list stops at `}`, which has nothing to close at the top level.
+	for p.currentToken.Type == token.RBRACE {
+		p.errors = append(p.errors, &Error{
+			Pos:   p.currentToken.Pos,
+			Found: p.currentToken,
+			Msg:   "unexpected '}' without matching '{'",
+		})
 		p.nextToken()
+		root.Statements = append(root.Statements, p.parseStatementList(0)...)
 	}
 
+	root.Comments = p.comments
+
 	return root
 }
 
+// parseStatementList parses statements until the closing `}` of the
+// enclosing block or EOF is reached. The depth is the number of braces
+// enclosing the statements.
+func (p *Parser) parseStatementList(depth int) []ast.Statement {
+	statements := make([]ast.Statement, 0)
+
+	for p.currentToken.Type != token.RBRACE && p.currentToken.Type != token.EOF {
+		stmt, ok := p.tryParseStatement()
+		if !ok {
+			p.synchronize(depth)
+
+			continue
+		}
+
+		statements = append(statements, stmt)
+		p.nextToken()
+	}
+
+	return statements
+}
+
+// tryParseStatement parses a single statement, reporting false if parsing
+// was abandoned due to a syntax error.
+func (p *Parser) tryParseStatement() (stmt ast.Statement, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isBailout := r.(bailout); !isBailout {
+				panic(r)
+			}
+
+			stmt, ok = nil, false
+		}
+	}()
+
+	return p.parseStatement(), true
+}
+
+// synchronize skips tokens after a syntax error until a point where parsing
+// of the next statement at the given brace depth can start: right after `;`,
+// at a statement keyword, at the `}` closing the enclosing block or at EOF.
+// This way a single mistake yields a single error.
+func (p *Parser) synchronize(depth int) {
+	for {
+		switch {
+		case p.currentToken.Type == token.EOF:
+			return
+		case p.braceDepth < depth:
+			// The current token closes the enclosing block.
+			return
+		case p.braceDepth == depth && p.currentToken.Type == token.SEMICOLON:
+			p.nextToken()
+
+			return
+		}
+
+		p.nextToken()
+
+		if p.braceDepth == depth && isStatementKeyword(p.currentToken.Type) {
+			return
+		}
+	}
+}
+
+func isStatementKeyword(t token.Type) bool {
+	switch t {
+	case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currentToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.currentToken}
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if _, ok := assignments[p.peekToken.Type]; ok {
+		return p.parseAssignStatement(stmt.Expression)
+	}
+
 	if p.peekToken.Type == token.SEMICOLON {
 		p.nextToken()
 	}
@@ -163,8 +344,8 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.Type) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.illegalTokenError(p.currentToken)
+	p.errorf("no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -200,6 +381,36 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+func (p *Parser) parseAssignStatement(target ast.Expression) *ast.AssignStatement {
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.fail(&Error{
+			Pos:   target.Pos(),
+			Found: p.peekToken,
+			Msg:   fmt.Sprintf("cannot assign to %s", target),
+		})
+	}
+
+	p.nextToken()
+	stmt := &ast.AssignStatement{
+		Token:    p.currentToken,
+		Target:   target,
+		Operator: assignments[p.currentToken.Type],
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// expectPeek advances to the next token if it has the given type,
+// otherwise a syntax error is reported.
 func (p *Parser) expectPeek(t token.Type) bool {
 	if p.peekToken.Type == t {
 		p.nextToken()
@@ -230,9 +441,32 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	var err error
 	lit.Value, err = strconv.ParseInt(p.currentToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// Literals out of the int64 range are arbitrary-precision integers.
+		var ok bool
+		lit.Big, ok = new(big.Int).SetString(p.currentToken.Literal, 0)
+		if ok {
+			return lit
+		}
+	}
+
+	if err != nil {
+		p.errorf("could not parse %q as integer", p.currentToken.Literal)
+
+		return nil
+	}
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.currentToken}
+
+	var err error
+	// The lexer makes sure `_` separators are placed between digits.
+	lit.Value, err = strconv.ParseFloat(strings.ReplaceAll(p.currentToken.Literal, "_", ""), 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf("could not parse %q as float", p.currentToken.Literal)
 
 		return nil
 	}
@@ -269,20 +503,22 @@ func (p *Parser) registerInfix(tokenType token.Type, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
-func (p *Parser) peekPrecedence() int {
-	if pcd, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns the precedence of the infix operator of the given type,
+// LOWEST is returned for tokens that aren't infix operators.
+func Precedence(tokenType token.Type) int {
+	if pcd, ok := precedences[tokenType]; ok {
 		return pcd
 	}
 
 	return LOWEST
 }
 
-func (p *Parser) currentPrecedence() int {
-	if pcd, ok := precedences[p.currentToken.Type]; ok {
-		return pcd
-	}
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
 
-	return LOWEST
+func (p *Parser) currentPrecedence() int {
+	return Precedence(p.currentToken.Type)
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
@@ -342,16 +578,120 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if p.peekToken.Type == token.COMMA {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	}
+
+	if p.peekToken.Type != token.IN {
+		p.peekError(token.COMMA, token.IN)
+	}
+	p.nextToken()
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopBody parses the block of a loop, `break` and `continue`
+// statements are allowed in it.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBranchStatement() *ast.BranchStatement {
+	stmt := &ast.BranchStatement{Token: p.currentToken}
+
+	// The statement itself is well-formed, so parsing goes on without recovery.
+	if p.loopDepth == 0 {
+		p.errors = append(p.errors, &Error{
+			Pos:   p.currentToken.Pos,
+			Found: p.currentToken,
+			Msg:   fmt.Sprintf("'%s' outside of a loop", p.currentToken.Literal),
+		})
+	}
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currentToken}
-	block.Statements = make([]ast.Statement, 0)
+	depth := p.braceDepth
 
 	p.nextToken()
 
-	for p.currentToken.Type != token.RBRACE && p.currentToken.Type != token.EOF {
-		block.Statements = append(block.Statements, p.parseStatement())
-		p.nextToken()
+	block.Statements = p.parseStatementList(depth)
+	if p.currentToken.Type != token.RBRACE {
+		p.errorf("expected '%s' to close the block opened at %s, got '%s' instead",
+			token.RBRACE, block.Token.Pos, p.currentToken.Type)
 	}
+	block.Rbrace = p.currentToken.Pos
 
 	return block
 }
@@ -363,61 +703,142 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	fn.Parameters = p.parseFunctionParameters()
+	p.parseFunctionParameters(fn)
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	// Loops enclosing the function literal can't be broken from its body.
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
 	fn.Body = p.parseBlockStatement()
 
 	return fn
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := make([]*ast.Identifier, 0)
+// parseMacroLiteral parses macro definition, parameters of macros
+// are plain identifiers.
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macro := &ast.MacroLiteral{Token: p.currentToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	fn := &ast.FunctionLiteral{}
+	p.parseFunctionParameters(fn)
+	if fn.Defaults != nil || fn.Rest != nil {
+		p.errorf("macro parameters can't have default values or collect the rest of arguments")
+	}
+	macro.Parameters = fn.Parameters
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	macro.Body = p.parseBlockStatement()
+
+	return macro
+}
+
+// parseFunctionParameters parses parameters of the function literal, each of them
+// may have a default value, `x = 10`, the last one may collect the rest of arguments, `...rest`.
+func (p *Parser) parseFunctionParameters(fn *ast.FunctionLiteral) {
+	fn.Parameters = make([]*ast.Identifier, 0)
 
 	if p.peekToken.Type == token.RPAREN {
 		p.nextToken()
 
-		return identifiers
+		return
 	}
 
-	p.nextToken()
+	hasDefaults := false
+	for {
+		if p.peekToken.Type == token.ELLIPSIS {
+			p.nextToken()
+			p.expectPeek(token.IDENT)
+			fn.Rest = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 
-	var iden *ast.Identifier
-	iden = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-	identifiers = append(identifiers, iden)
+			if p.peekToken.Type == token.COMMA {
+				p.nextToken()
+				p.errorf("rest parameter must be the last one")
+			}
 
-	for p.peekToken.Type == token.COMMA {
-		p.nextToken()
+			break
+		}
+
+		p.expectPeek(token.IDENT)
+		param := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+		var defaultValue ast.Expression
+		if p.peekToken.Type == token.ASSIGN {
+			p.nextToken()
+			p.nextToken()
+			defaultValue = p.parseExpression(LOWEST)
+			hasDefaults = true
+		} else if hasDefaults {
+			p.fail(&Error{
+				Pos:   param.Pos(),
+				Found: param.Token,
+				Msg:   fmt.Sprintf("parameter %s without default value follows parameters with default values", param),
+			})
+		}
+
+		fn.Parameters = append(fn.Parameters, param)
+		fn.Defaults = append(fn.Defaults, defaultValue)
+
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
 		p.nextToken()
-		iden = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, iden)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if !hasDefaults {
+		fn.Defaults = nil
 	}
 
-	return identifiers
+	p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.currentToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseExpressionList(token.RPAREN, p.parseArgument)
+	exp.Rparen = p.currentToken.Pos
 
 	return exp
 }
 
+// parseArgument parses a call argument, which may be spread, `...args`.
+func (p *Parser) parseArgument() ast.Expression {
+	if p.currentToken.Type != token.ELLIPSIS {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.currentToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+
+	return spread
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currentToken}
-	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Elements = p.parseExpressionList(token.RBRACKET, func() ast.Expression {
+		return p.parseExpression(LOWEST)
+	})
+	array.Rbracket = p.currentToken.Pos
 
 	return array
 }
 
-func (p *Parser) parseExpressionList(endToken token.Type) []ast.Expression {
+func (p *Parser) parseExpressionList(endToken token.Type, parseElement func() ast.Expression) []ast.Expression {
 	expressions := make([]ast.Expression, 0)
 
 	if p.peekToken.Type == endToken {
@@ -427,12 +848,12 @@ func (p *Parser) parseExpressionList(endToken token.Type) []ast.Expression {
 	}
 
 	p.nextToken()
-	expressions = append(expressions, p.parseExpression(LOWEST))
+	expressions = append(expressions, parseElement())
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
 		p.nextToken()
-		expressions = append(expressions, p.parseExpression(LOWEST))
+		expressions = append(expressions, parseElement())
 	}
 
 	if !p.expectPeek(endToken) {
@@ -451,6 +872,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.currentToken.Pos
 
 	return exp
 }
@@ -471,14 +893,19 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
 
-		if p.peekToken.Type != token.RBRACE && !p.expectPeek(token.COMMA) {
-			return nil
+		if p.peekToken.Type != token.RBRACE && p.peekToken.Type != token.COMMA {
+			p.peekError(token.COMMA, token.RBRACE)
+		}
+
+		if p.peekToken.Type == token.COMMA {
+			p.nextToken()
 		}
 	}
 
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.currentToken.Pos
 
 	return hash
 }
@@ -2,6 +2,7 @@ package parser_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/dstdfx/scroopy/lexer"
@@ -51,8 +52,8 @@ func checkParserErrors(t *testing.T, p *parser.Parser) {
 		return
 	}
 	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+	for _, err := range errors {
+		t.Errorf("parser error: %q", err)
 	}
 	t.FailNow()
 }
@@ -91,13 +92,243 @@ func TestLetStatements_ParsingError(t *testing.T) {
 
 	errors := p.Errors()
 	if len(errors) != 1 {
-		t.Errorf("expected 1 error but got %d", len(errors))
+		t.Fatalf("expected 1 error but got %d", len(errors))
 	}
 
-	expectedError := fmt.Sprintf(parser.ErrExpectedNextTokenFmt, token.IDENT, token.INT)
-	if errors[0] != expectedError {
+	expectedError := "1:5: " + fmt.Sprintf(parser.ErrExpectedNextTokenFmt, token.IDENT, token.INT)
+	if errors[0].Error() != expectedError {
 		t.Errorf("expected error '%s' but got '%s'", expectedError, errors[0])
 	}
+
+	if len(errors[0].Expected) != 1 || errors[0].Expected[0] != token.IDENT {
+		t.Errorf("expected error to expect %v, got %v", []token.Type{token.IDENT}, errors[0].Expected)
+	}
+
+	if errors[0].Found.Type != token.INT || errors[0].Found.Literal != "10" {
+		t.Errorf("expected error to be found at INT token '10', got %+v", errors[0].Found)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 1; // trailing comment
+/* block
+   comment */ let y = x /* inline */ + 2;`
+
+	l := lexer.NewWithMode("", input, lexer.ScanComments)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "let x = 1;let y = (x + 2);" {
+		t.Errorf("comments aren't skipped. got=%q", program.String())
+	}
+
+	expected := []struct {
+		text string
+		pos  string
+	}{
+		{"// leading comment", "1:1"},
+		{"// trailing comment", "2:12"},
+		{"/* block\n   comment */", "3:1"},
+		{"/* inline */", "4:25"},
+	}
+
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("expected %d comments, got %d", len(expected), len(program.Comments))
+	}
+
+	for i, tt := range expected {
+		comment := program.Comments[i]
+		if comment.String() != tt.text {
+			t.Errorf("comments[%d]: expected %q, got %q", i, tt.text, comment.String())
+		}
+
+		if comment.Pos().String() != tt.pos {
+			t.Errorf("comments[%d]: expected position %s, got %s", i, tt.pos, comment.Pos())
+		}
+	}
+
+	// Without ScanComments mode comments are skipped by the lexer.
+	program = parser.New(lexer.New(input)).ParseProgram()
+	if len(program.Comments) != 0 {
+		t.Errorf("expected no comments, got %d", len(program.Comments))
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements int
+	}{
+		{
+			input:              "let x 5; let y = 10; y;",
+			expectedErrors:     []string{"1:7: expected next token to be '=', got 'INT' instead"},
+			expectedStatements: 2,
+		},
+		{
+			input: `let add = fn(x, y) {
+  let = x + y;
+  return x + y;
+};
+add(1, 2);`,
+			expectedErrors:     []string{"2:7: expected next token to be 'IDENT', got '=' instead"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "if (x { 1 }\nlet y = 2;",
+			expectedErrors:     []string{"1:7: expected next token to be ')', got '{' instead"},
+			expectedStatements: 1,
+		},
+		{
+			input: `let h = {"a" 1}; let z = fn() { {"b": 1 2} }; z;`,
+			expectedErrors: []string{
+				"1:14: expected next token to be ':', got 'INT' instead",
+				"1:41: expected next token to be one of ',', '}', got 'INT' instead",
+			},
+			expectedStatements: 2,
+		},
+		{
+			input:              "let f = fn() { g( };\nf();",
+			expectedErrors:     []string{"1:19: no prefix parse function for } found"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "let f = fn(x { x };",
+			expectedErrors:     []string{"1:14: expected next token to be ')', got '{' instead"},
+			expectedStatements: 0,
+		},
+		{
+			input:              "let f = fn(1) { 1 };",
+			expectedErrors:     []string{"1:12: expected next token to be 'IDENT', got 'INT' instead"},
+			expectedStatements: 0,
+		},
+		{
+			input:              "let f = fn() { 1 ",
+			expectedErrors:     []string{"1:18: expected '}' to close the block opened at 1:14, got 'EOF' instead"},
+			expectedStatements: 0,
+		},
+		{
+			input:              "} 1; 2",
+			expectedErrors:     []string{"1:1: unexpected '}' without matching '{'"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "1 + 2 = 3; let x = 1;",
+			expectedErrors:     []string{"1:1: cannot assign to (1 + 2)"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "break; 1;",
+			expectedErrors:     []string{"1:1: 'break' outside of a loop"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "while (true) { let f = fn() { continue; }; break; }",
+			expectedErrors:     []string{"1:31: 'continue' outside of a loop"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "for (x y) { x }\nlet z = 1;",
+			expectedErrors:     []string{"1:8: expected next token to be one of ',', 'IN', got 'IDENT' instead"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let x = 1; /* never closed\nlet y = 2;",
+			expectedErrors:     []string{"1:12: unterminated block comment"},
+			expectedStatements: 1,
+		},
+		{
+			input:              `let s = "a\qb"; let t = "never closed`,
+			expectedErrors:     []string{"1:11: invalid escape sequence \\q", "1:25: unterminated string literal"},
+			expectedStatements: 0,
+		},
+		{
+			input:              "let größe = 1; let bad = \"a\xffb\"; größe",
+			expectedErrors:     []string{"1:30: invalid UTF-8 encoding"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "let x \"\\u12\"; x",
+			expectedErrors:     []string{"1:8: invalid escape sequence \\u12"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let f = fn(...a, b) { a }; f",
+			expectedErrors:     []string{"1:16: rest parameter must be the last one"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let f = fn(a = 1, b) { a }; f",
+			expectedErrors:     []string{"1:19: parameter b without default value follows parameters with default values"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let m = macro(a = 1) { a }; m",
+			expectedErrors:     []string{"1:20: macro parameters can't have default values or collect the rest of arguments"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let a = [...b]; a",
+			expectedErrors:     []string{"1:10: no prefix parse function for ... found"},
+			expectedStatements: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q: expected %d errors, got %d: %v", tt.input, len(tt.expectedErrors), len(errors), errors)
+
+			continue
+		}
+
+		for i, expected := range tt.expectedErrors {
+			if errors[i].Error() != expected {
+				t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, expected, errors[i].Error())
+			}
+		}
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("%q: expected %d statements, got %d", tt.input, tt.expectedStatements, len(program.Statements))
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	input := "let x = 1;\n\tlet = 2;\nlet y 3;"
+	p := parser.New(lexer.New(input))
+	_ = p.ParseProgram()
+
+	errors := p.Errors()
+	if errors.Err() == nil {
+		t.Fatalf("expected Err() to return an error")
+	}
+
+	expected := "2:6: expected next token to be 'IDENT', got '=' instead (and 1 more errors)"
+	if errors.Error() != expected {
+		t.Errorf("wrong ErrorList.Error(). expected=%q, got=%q", expected, errors.Error())
+	}
+
+	expectedRender := `2:6: expected next token to be 'IDENT', got '=' instead
+	let = 2;
+	    ^
+3:7: expected next token to be '=', got 'INT' instead
+let y 3;
+      ^
+`
+	if errors.Render(input) != expectedRender {
+		t.Errorf("wrong ErrorList.Render(). expected=\n%s\ngot=\n%s", expectedRender, errors.Render(input))
+	}
+
+	if (parser.ErrorList{}).Err() != nil {
+		t.Errorf("expected Err() of an empty list to be nil")
+	}
 }
 
 func TestReturnStatements(t *testing.T) {
@@ -180,6 +411,153 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0Xff", 255},
+		{"0o755", 493},
+		{"0755", 493},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_dead_BEEF", 0xdeadbeef},
+		{"0b_1_0", 2},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("%s: literal.Value not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %s. got=%s", tt.input, literal.String())
+		}
+	}
+}
+
+func TestBigIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"0xFFFF_FFFF_FFFF_FFFF_F", "295147905179352825855"},
+		{"0o1_777777777777777777777", "18446744073709551615"},
+		{"0b1" + strings.Repeat("0", 64), "18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Big == nil || literal.Big.String() != tt.expected {
+			t.Errorf("%s: literal.Big not %s. got=%v", tt.input, tt.expected, literal.Big)
+		}
+	}
+}
+
+func TestIntegerLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", "1:1: hexadecimal literal has no digits"},
+		{"0b", "1:1: binary literal has no digits"},
+		{"0b1021", "1:5: invalid digit '2' in binary literal"},
+		{"0o78", "1:4: invalid digit '8' in octal literal"},
+		{"0789", "1:3: invalid digit '8' in octal literal"},
+		{"1__000", "1:3: '_' must separate successive digits"},
+		{"1_000_", "1:6: '_' must separate successive digits"},
+		{"1_.5", "1:2: '_' must separate successive digits"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%s: expected an error", tt.input)
+
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%s: wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5E3;", 2500},
+		{"1_000.000_5;", 1000.0005},
+		{"09.5;", 9.5},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d",
+				len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestFloatLiteralExpression_OutOfRange(t *testing.T) {
+	p := parser.New(lexer.New("1e400"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got=%d (%v)", len(errors), errors)
+	}
+
+	expected := `1:1: could not parse "1e400" as float`
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -307,6 +685,10 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
 		{"5 ** 2;", 5, "**", 2},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 	}
 
 	for _, tt := range infixTests {
@@ -456,6 +838,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a < b && b <= c == true",
+			"((a < b) && ((b <= c) == true))",
+		},
+		{
+			"!a || a + 1 >= b",
+			"((!a) || ((a + 1) >= b))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -665,6 +1067,131 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedOperator string
+		expected         string
+	}{
+		{"x = 5;", "", "x = 5;"},
+		{"x += y * 2", "+", "x += (y * 2);"},
+		{"x -= 1", "-", "x -= 1;"},
+		{"x *= 1", "*", "x *= 1;"},
+		{"x /= 1", "/", "x /= 1;"},
+		{"arr[0] = 1", "", "(arr[0]) = 1;"},
+		{`h["a"] += 1;`, "+", `(h[a]) += 1;`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.AssignStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.Operator != tt.expectedOperator {
+			t.Errorf("wrong operator. expected=%q, got=%q", tt.expectedOperator, stmt.Operator)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program string. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { break; continue; }`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	for i, expected := range []string{"break", "continue"} {
+		branch, ok := stmt.Body.Statements[i].(*ast.BranchStatement)
+		if !ok {
+			t.Fatalf("body.Statements[%d] is not ast.BranchStatement. got=%T", i, stmt.Body.Statements[i])
+		}
+
+		if branch.TokenLiteral() != expected {
+			t.Errorf("body.Statements[%d] is not %s. got=%s", i, expected, branch.TokenLiteral())
+		}
+	}
+
+	if program.String() != "while ((x < 10)) break;continue;" {
+		t.Errorf("wrong program string. got=%q", program.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKey   string
+		expectedValue string
+		expected      string
+	}{
+		{"for (x in arr) { x }", "", "x", "for (x in arr) x"},
+		{"for (k, v in {}) { v };", "k", "v", "for (k, v in {}) v"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		if tt.expectedKey == "" && stmt.Key != nil {
+			t.Errorf("stmt.Key is not nil. got=%+v", stmt.Key)
+		}
+
+		if tt.expectedKey != "" && !testIdentifier(t, stmt.Key, tt.expectedKey) {
+			return
+		}
+
+		if !testIdentifier(t, stmt.Value, tt.expectedValue) {
+			return
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program string. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
@@ -737,6 +1264,114 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionParameterDefaultsParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedDefaults []string
+		expectedRest     string
+		expectedString   string
+	}{
+		{"fn(x, y = 10) {}", []string{"", "10"}, "", "fn(x, y = 10) "},
+		{"fn(x = 1, y = x * 2) {}", []string{"1", "(x * 2)"}, "", "fn(x = 1, y = (x * 2)) "},
+		{"fn(first, ...rest) {}", nil, "rest", "fn(first, ...rest) "},
+		{"fn(...args) {}", nil, "args", "fn(...args) "},
+		{"fn(x, y = 1, ...rest) {}", []string{"", "1"}, "rest", "fn(x, y = 1, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if tt.expectedDefaults == nil && function.Defaults != nil {
+			t.Errorf("%q: expected no defaults, got=%v", tt.input, function.Defaults)
+		}
+		for i, expected := range tt.expectedDefaults {
+			switch {
+			case expected == "" && function.Defaults[i] != nil:
+				t.Errorf("%q: expected no default for parameter %d, got=%s", tt.input, i, function.Defaults[i])
+			case expected != "" && (function.Defaults[i] == nil || function.Defaults[i].String() != expected):
+				t.Errorf("%q: wrong default for parameter %d. want=%s, got=%v", tt.input, i, expected, function.Defaults[i])
+			}
+		}
+
+		switch {
+		case tt.expectedRest == "" && function.Rest != nil:
+			t.Errorf("%q: expected no rest parameter, got=%s", tt.input, function.Rest)
+		case tt.expectedRest != "":
+			testIdentifier(t, function.Rest, tt.expectedRest)
+		}
+
+		if function.String() != tt.expectedString {
+			t.Errorf("%q: wrong string. want=%q, got=%q", tt.input, tt.expectedString, function.String())
+		}
+	}
+}
+
+func TestSpreadArgumentParsing(t *testing.T) {
+	p := parser.New(lexer.New("f(1, ...xs, ...[2, 3])"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if len(call.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
+	}
+
+	testLiteralExpression(t, call.Arguments[0], 1)
+
+	spread, ok := call.Arguments[1].(*ast.SpreadExpression)
+	if !ok {
+		t.Fatalf("argument is not ast.SpreadExpression. got=%T", call.Arguments[1])
+	}
+	testIdentifier(t, spread.Value, "xs")
+
+	if call.String() != "f(1, ...xs, ...[2, 3])" {
+		t.Errorf("wrong string. got=%q", call.String())
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	l := lexer.New(input)
@@ -983,6 +1618,84 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y;
+};
+add(1, [2, 3][0]);
+{"a": -1}`
+
+	tests := []struct {
+		node          func(root *ast.Root) ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{
+			node:          func(root *ast.Root) ast.Node { return root },
+			expectedStart: "1:1",
+			expectedEnd:   "5:10",
+		},
+		{
+			node:          func(root *ast.Root) ast.Node { return root.Statements[0] },
+			expectedStart: "1:1",
+			expectedEnd:   "3:2",
+		},
+		{
+			node: func(root *ast.Root) ast.Node {
+				return root.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body
+			},
+			expectedStart: "1:20",
+			expectedEnd:   "3:2",
+		},
+		{
+			node: func(root *ast.Root) ast.Node {
+				fn := root.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+
+				return fn.Body.Statements[0]
+			},
+			expectedStart: "2:3",
+			expectedEnd:   "2:8",
+		},
+		{
+			node:          func(root *ast.Root) ast.Node { return root.Statements[1] },
+			expectedStart: "4:1",
+			expectedEnd:   "4:18",
+		},
+		{
+			node: func(root *ast.Root) ast.Node {
+				call := root.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+
+				return call.Arguments[1]
+			},
+			expectedStart: "4:8",
+			expectedEnd:   "4:17",
+		},
+		{
+			node:          func(root *ast.Root) ast.Node { return root.Statements[2] },
+			expectedStart: "5:1",
+			expectedEnd:   "5:10",
+		},
+	}
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	for idx, tt := range tests {
+		node := tt.node(program)
+		if node.Pos().String() != tt.expectedStart {
+			t.Errorf("test[%d]: wrong start position of %q. expected=%s, got=%s",
+				idx, node, tt.expectedStart, node.Pos())
+		}
+
+		if node.End().String() != tt.expectedEnd {
+			t.Errorf("test[%d]: wrong end position of %q. expected=%s, got=%s",
+				idx, node, tt.expectedEnd, node.End())
+		}
+	}
+}
+
 func BenchmarkParser_ParseProgram(b *testing.B) {
 	input := `let five = 5;
 let ten = 10;
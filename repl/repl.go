@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
-	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/engine"
 	"github.com/dstdfx/scroopy/lexer"
 	"github.com/dstdfx/scroopy/object"
 	"github.com/dstdfx/scroopy/parser"
@@ -15,8 +16,13 @@ import (
 // Start runs the main REPL goroutine.
 // It reads data from the given io.Reader, parses and evaluates it.
 func Start(in io.Reader, out io.Writer) {
+	StartWithEngine(in, out, engine.NewEvaluator())
+}
+
+// StartWithEngine runs the main REPL goroutine executing every line
+// with the given engine.
+func StartWithEngine(in io.Reader, out io.Writer, eng engine.Engine) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
 
 	for {
 		_, err := io.WriteString(out, ">> ")
@@ -33,12 +39,12 @@ func Start(in io.Reader, out io.Writer) {
 		p := parser.New(l)
 		root := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Errors())
 
 			continue
 		}
 
-		evaluated := evaluator.Eval(root, env)
+		evaluated := eng.Run(root)
 		if evaluated == nil {
 			continue
 		}
@@ -47,12 +53,22 @@ func Start(in io.Reader, out io.Writer) {
 		if err != nil {
 			handleIOError(err)
 		}
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			_, err = io.WriteString(out, errObj.Traceback())
+			if err != nil {
+				handleIOError(err)
+			}
+		}
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		_, err := io.WriteString(out, "\t"+msg+"\n")
+// printParserErrors prints every error followed by the offending line of
+// the source and a caret under the column the error refers to.
+func printParserErrors(out io.Writer, src string, errors parser.ErrorList) {
+	rendered := strings.TrimSuffix(errors.Render(src), "\n")
+	for _, line := range strings.Split(rendered, "\n") {
+		_, err := io.WriteString(out, "\t"+line+"\n")
 		if err != nil {
 			handleIOError(err)
 		}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/dstdfx/scroopy/engine"
 	"github.com/dstdfx/scroopy/repl"
 )
 
@@ -19,3 +20,42 @@ func TestStart(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestStart_ParserErrors(t *testing.T) {
+	input := `let x 5;`
+	expected := ">> \t1:7: expected next token to be '=', got 'INT' instead\n" +
+		"\tlet x 5;\n" +
+		"\t      ^\n" +
+		">> "
+	output := bytes.NewBuffer(make([]byte, 0, 32))
+	repl.Start(strings.NewReader(input), output)
+
+	if output.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output.String())
+	}
+}
+
+func TestStart_RuntimeErrors(t *testing.T) {
+	input := `let inner = fn(x) { x + true }; let outer = fn(x) { inner(x) }; outer(1)`
+	expected := ">> ERROR: 1:21: type mismatch: INTEGER + BOOLEAN\n" +
+		"\tat inner (called at 1:53)\n" +
+		"\tat outer (called at 1:65)\n" +
+		">> "
+	output := bytes.NewBuffer(make([]byte, 0, 32))
+	repl.Start(strings.NewReader(input), output)
+
+	if output.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output.String())
+	}
+}
+
+func TestStartWithEngine_VM(t *testing.T) {
+	input := "let add = fn(a, b) { a + b };\nlet x = add(1, 2);\nx * 10\ny"
+	expected := ">> >> >> 30\n>> ERROR: 1:1: identifier not found: y\n>> "
+	output := bytes.NewBuffer(make([]byte, 0, 32))
+	repl.StartWithEngine(strings.NewReader(input), output, engine.NewVM())
+
+	if output.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output.String())
+	}
+}
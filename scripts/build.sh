@@ -1,9 +1,9 @@
 #!/usr/bin/env bash
 
-echo "==> Building scroopy-repl binary..."
+echo "==> Building scroopy binary..."
 GO111MODULE=on CGO_ENABLED=0 \
 go build -mod=mod -a -installsuffix cgo -ldflags \
-    "-X scroopy/cmd/repl/app.buildGitCommit=$(git rev-parse HEAD) \
-    -X scroopy/cmd/repl/app.buildGitTag=$(git describe --abbrev=0) \
-    -X scroopy/cmd/repl/app.buildDate=$(date +%Y%m%d)" \
-    -o scroopy-repl ./cmd/repl
+    "-X github.com/dstdfx/scroopy/cmd/scroopy/app.buildGitCommit=$(git rev-parse HEAD) \
+    -X github.com/dstdfx/scroopy/cmd/scroopy/app.buildGitTag=$(git describe --abbrev=0) \
+    -X github.com/dstdfx/scroopy/cmd/scroopy/app.buildDate=$(date +%Y%m%d)" \
+    -o scroopy ./cmd/scroopy
@@ -0,0 +1,179 @@
+// Package scroopy embeds the Scroopy interpreter in Go programs.
+//
+// Every Interpreter has its own global variables, build-in functions and
+// output writers, so isolated interpreters can run in the same process:
+//
+//	interp := scroopy.New()
+//	interp.SetStdout(&buf)
+//	interp.RegisterFunc("double", func(args ...object.Object) object.Object {
+//		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+//	})
+//	result, err := interp.Eval("print(double(21))")
+package scroopy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dstdfx/scroopy/engine"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+// Interpreter evaluates programs with the tree-walking evaluator. Global
+// variables and macros defined by a program stay available to the programs
+// evaluated after it. An Interpreter must not be used concurrently.
+type Interpreter struct {
+	engine   *engine.Evaluator
+	ctx      context.Context
+	builtins map[string]*object.BuildIn
+	stdout   *output
+	stderr   *output
+}
+
+// RuntimeError is returned when the program fails while it's evaluated.
+// It wraps the cause of the error, e.g. evaluator.ErrTimedOut.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Err.Pos.IsValid() {
+		return fmt.Sprintf("%s: runtime error: %s", e.Err.Pos, e.Err.Message)
+	}
+
+	return "runtime error: " + e.Err.Message
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err.Cause
+}
+
+// output is the writer programs write to, it stays the same while
+// the underlying writer is replaced.
+type output struct {
+	w io.Writer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	return o.w.Write(p)
+}
+
+// New returns new instance of Interpreter writing to os.Stdout and os.Stderr.
+func New() *Interpreter {
+	return NewWithConfig(evaluator.Config{})
+}
+
+// NewWithConfig returns new instance of Interpreter limited by the config.
+// The build-in functions of the config are added to the default ones,
+// replacing those with the same name.
+func NewWithConfig(config evaluator.Config) *Interpreter {
+	interp := &Interpreter{
+		builtins: make(map[string]*object.BuildIn, len(object.Builtins)+len(config.Builtins)),
+		ctx:      config.Context,
+		stdout:   &output{w: os.Stdout},
+		stderr:   &output{w: os.Stderr},
+	}
+
+	for _, def := range object.Builtins {
+		interp.builtins[def.Name] = def.BuildIn
+	}
+	interp.builtins["print"] = object.NewPrint(interp.stdout)
+	for name, buildIn := range config.Builtins {
+		interp.builtins[name] = buildIn
+	}
+
+	config.Builtins = interp.builtins
+	interp.engine = engine.NewEvaluatorWithConfig(config)
+
+	return interp
+}
+
+// SetStdout sets the writer `print` writes to.
+func (i *Interpreter) SetStdout(w io.Writer) {
+	i.stdout.w = w
+}
+
+// SetStderr sets the writer returned by Stderr.
+func (i *Interpreter) SetStderr(w io.Writer) {
+	i.stderr.w = w
+}
+
+// Stdout returns the writer of the standard output of programs, functions
+// registered with RegisterFunc should write to it instead of os.Stdout.
+// The writer follows the changes made by SetStdout.
+func (i *Interpreter) Stdout() io.Writer {
+	return i.stdout
+}
+
+// Stderr returns the writer of the error output of programs, functions
+// registered with RegisterFunc should write to it instead of os.Stderr.
+// The writer follows the changes made by SetStderr.
+func (i *Interpreter) Stderr() io.Writer {
+	return i.stderr
+}
+
+// RegisterFunc makes the Go function available to programs of the interpreter
+// by the name, replacing the build-in function with the same name if any.
+// Global variables take precedence over functions with the same name.
+func (i *Interpreter) RegisterFunc(name string, fn object.BuildInFunction) {
+	i.builtins[name] = &object.BuildIn{Fn: fn}
+}
+
+// Set binds the value to the global name.
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.engine.Define(name, value)
+}
+
+// Get returns the value bound to the global name.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.engine.Get(name)
+}
+
+// Eval evaluates the source and returns the value of its last statement,
+// NULL if the statement has no value. Syntax errors are returned as
+// parser.ErrorList, errors raised by the program as *RuntimeError.
+// Every call gets the full step and allocation limits of the config.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.eval(i.ctx, lexer.New(src))
+}
+
+// EvalContext evaluates the source the same way Eval does, the evaluation
+// is aborted once ctx is done instead of the context of the config.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	return i.eval(ctx, lexer.New(src))
+}
+
+// EvalFile evaluates the source file the same way Eval does,
+// positions of errors refer to the file.
+func (i *Interpreter) EvalFile(path string) (object.Object, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.eval(i.ctx, lexer.NewWithFilename(path, string(src)))
+}
+
+func (i *Interpreter) eval(ctx context.Context, l *lexer.Lexer) (object.Object, error) {
+	p := parser.New(l)
+	root := p.ParseProgram()
+	if err := p.Errors().Err(); err != nil {
+		return nil, err
+	}
+
+	i.engine.Reset(ctx)
+	evaluated := i.engine.Run(root)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+	if evaluated == nil {
+		return object.NULL, nil
+	}
+
+	return evaluated, nil
+}
@@ -0,0 +1,224 @@
+package scroopy_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dstdfx/scroopy"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+)
+
+func TestInterpreter(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	interp := scroopy.New()
+	interp.SetStdout(stdout)
+
+	interp.Set("base", &object.Integer{Value: 10})
+	interp.RegisterFunc("double", func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return &object.Error{Message: "double takes one argument"}
+		}
+		n, ok := args[0].(*object.Integer)
+		if !ok {
+			return &object.Error{Message: "double takes an integer"}
+		}
+
+		return &object.Integer{Value: n.Value * 2}
+	})
+
+	evaluated, err := interp.Eval("let x = double(base) + 1; print(x); x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluated.Inspect() != "21" {
+		t.Errorf("wrong result. expected=21, got=%s", evaluated.Inspect())
+	}
+	if stdout.String() != "21\n" {
+		t.Errorf("wrong stdout. expected=%q, got=%q", "21\n", stdout.String())
+	}
+
+	// Globals stay defined for the following programs.
+	if x, ok := interp.Get("x"); !ok || x.Inspect() != "21" {
+		t.Errorf("wrong global x. got=%v", x)
+	}
+	if evaluated, err := interp.Eval("let y = x * 2;"); err != nil || evaluated != object.NULL {
+		t.Errorf("wrong result of a let statement. got=%v (%v)", evaluated, err)
+	}
+
+	_, err = interp.Eval("let x 1;")
+	var syntaxErrors parser.ErrorList
+	if !errors.As(err, &syntaxErrors) || err.Error() != "1:7: expected next token to be '=', got 'INT' instead" {
+		t.Errorf("wrong syntax error. got=%v", err)
+	}
+
+	_, err = interp.Eval("double(true)")
+	var runtimeErr *scroopy.RuntimeError
+	if !errors.As(err, &runtimeErr) || err.Error() != "1:1: runtime error: double takes an integer" {
+		t.Errorf("wrong runtime error. got=%v", err)
+	}
+}
+
+func TestInterpreter_Isolation(t *testing.T) {
+	first, second := scroopy.New(), scroopy.New()
+	firstOut, secondOut := &bytes.Buffer{}, &bytes.Buffer{}
+	first.SetStdout(firstOut)
+	second.SetStdout(secondOut)
+
+	first.RegisterFunc("greet", func(args ...object.Object) object.Object {
+		return &object.String{Value: "hello"}
+	})
+
+	if _, err := first.Eval(`let x = 1; print(greet())`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Eval(`print("second")`); err != nil {
+		t.Fatal(err)
+	}
+
+	if firstOut.String() != "\"hello\"\n" || secondOut.String() != "\"second\"\n" {
+		t.Errorf("outputs are mixed. first=%q, second=%q", firstOut.String(), secondOut.String())
+	}
+	if _, ok := second.Get("x"); ok {
+		t.Error("global x of the first interpreter is visible to the second one")
+	}
+	if _, err := second.Eval("greet()"); err == nil || err.Error() != "1:1: runtime error: identifier not found: greet" {
+		t.Errorf("function registered by the first interpreter is visible to the second one. got=%v", err)
+	}
+}
+
+func TestInterpreter_Macros(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	interp := scroopy.New()
+	interp.SetStdout(stdout)
+	interp.RegisterFunc("answer", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+
+	// Macro bodies see the same build-in functions as the program.
+	evaluated, err := interp.Eval(`let m = macro() { print("in macro"); quote(unquote(answer())) };
+print("body"); m()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluated.Inspect() != "42" {
+		t.Errorf("wrong result. expected=42, got=%s", evaluated.Inspect())
+	}
+	if stdout.String() != "\"in macro\"\n\"body\"\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+}
+
+func TestInterpreter_Stdout(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	interp := scroopy.New()
+
+	// Registered functions keep writing to the writer set later.
+	out := interp.Stdout()
+	interp.RegisterFunc("say", func(args ...object.Object) object.Object {
+		_, _ = out.Write([]byte(args[0].Inspect() + "\n"))
+
+		return nil
+	})
+	interp.SetStdout(stdout)
+
+	if _, err := interp.Eval(`say("hello")`); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "\"hello\"\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+}
+
+func TestInterpreter_Stderr(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	interp := scroopy.New()
+
+	// Registered functions keep writing to the writer set later.
+	errOut := interp.Stderr()
+	interp.RegisterFunc("warn", func(args ...object.Object) object.Object {
+		_, _ = errOut.Write([]byte(args[0].Inspect() + "\n"))
+
+		return nil
+	})
+	interp.SetStderr(stderr)
+
+	if _, err := interp.Eval(`warn("careful")`); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.String() != "\"careful\"\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
+func TestInterpreter_ConfigBuiltins(t *testing.T) {
+	interp := scroopy.NewWithConfig(evaluator.Config{Builtins: map[string]*object.BuildIn{
+		"len": {Fn: func(args ...object.Object) object.Object { return &object.Integer{Value: -1} }},
+		"one": {Fn: func(args ...object.Object) object.Object { return &object.Integer{Value: 1} }},
+	}})
+	interp.SetStdout(&bytes.Buffer{})
+
+	evaluated, err := interp.Eval(`print("ok"); [len("abc"), one(), first([2])]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluated.Inspect() != "[-1, 1, 2]" {
+		t.Errorf("wrong result. expected=[-1, 1, 2], got=%s", evaluated.Inspect())
+	}
+}
+
+func TestInterpreter_EvalFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "script.scr")
+	if err := os.WriteFile(filename, []byte("let f = fn() { 1 / 0 };\nf()\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := scroopy.New().EvalFile(filename)
+	if err == nil || err.Error() != filename+":1:16: runtime error: division by zero" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	if _, err := scroopy.New().EvalFile(filepath.Join(t.TempDir(), "missing.scr")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("wrong error for a missing file. got=%v", err)
+	}
+}
+
+func TestInterpreter_Limits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	interp := scroopy.NewWithConfig(evaluator.Config{Context: ctx})
+	if _, err := interp.Eval("while (true) {}"); !errors.Is(err, evaluator.ErrCancelled) {
+		t.Errorf("wrong error. expected=%v, got=%v", evaluator.ErrCancelled, err)
+	}
+
+	// Every call gets its own budget and context.
+	interp = scroopy.NewWithConfig(evaluator.Config{MaxSteps: 100})
+	for i := 0; i < 10; i++ {
+		if _, err := interp.Eval("let i = 0; while (i < 5) { i += 1; }"); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+	if _, err := interp.EvalContext(ctx, "1 + 2"); !errors.Is(err, evaluator.ErrCancelled) {
+		t.Errorf("wrong error. expected=%v, got=%v", evaluator.ErrCancelled, err)
+	}
+	if _, err := interp.Eval("1 + 2"); err != nil {
+		t.Errorf("context of a previous call aborted the evaluation: %v", err)
+	}
+
+	// Calls without value don't bring the host down.
+	if evaluated, err := scroopy.New().Eval("let f = fn() { }; f() == 1"); err != nil || evaluated.Inspect() != "false" {
+		t.Errorf("wrong result. expected=false, got=%v (%v)", evaluated, err)
+	}
+
+	// Runaway recursion is stopped without a limit set.
+	_, err := scroopy.New().Eval("let f = fn(n) { f(n + 1) }; f(0);")
+	if !errors.Is(err, evaluator.ErrStackDepthExceeded) {
+		t.Errorf("wrong error. expected=%v, got=%v", evaluator.ErrStackDepthExceeded, err)
+	}
+}
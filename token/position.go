@@ -0,0 +1,77 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position describes a location in the source code.
+// A Position is valid if the line number is greater than 0.
+type Position struct {
+	Filename string // filename, if any
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1 (byte count)
+}
+
+// IsValid reports whether the position is valid.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns position in one of the following forms:
+//
+//	file:line:column    valid position with filename
+//	line:column         valid position without filename
+//	file                invalid position with filename
+//	-                   invalid position without filename
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+}
+
+// Excerpt returns the source line the position points at followed by
+// a line with a caret under the position's column, e.g.:
+//
+//	let 10;
+//	    ^
+//
+// An empty string is returned if the position doesn't belong to src.
+func (p Position) Excerpt(src string) string {
+	lineStart := p.Offset - p.Column + 1
+	if !p.IsValid() || lineStart < 0 || p.Offset > len(src) {
+		return ""
+	}
+
+	lineEnd := len(src)
+	if idx := strings.IndexByte(src[lineStart:], '\n'); idx >= 0 {
+		lineEnd = lineStart + idx
+	}
+
+	strBuilder := strings.Builder{}
+	strBuilder.WriteString(strings.TrimRight(src[lineStart:lineEnd], "\r"))
+	strBuilder.WriteByte('\n')
+
+	// Keep tabs so the caret stays aligned with the source line.
+	for _, r := range src[lineStart:p.Offset] {
+		if r == '\t' {
+			strBuilder.WriteRune(r)
+		} else {
+			strBuilder.WriteByte(' ')
+		}
+	}
+	strBuilder.WriteByte('^')
+
+	return strBuilder.String()
+}
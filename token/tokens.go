@@ -4,10 +4,12 @@ const (
 	// Inner tokens.
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // returned by the lexer on request only
 
 	// Identifiers + literals.
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"   // 1343456
+	FLOAT  = "FLOAT" // 3.14, 1e-9
 	STRING = "STRING"
 
 	// Operators.
@@ -17,11 +19,23 @@ const (
 	ASTERISK = "*"
 	BANG     = "!"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
+	LTE      = "<="
+	GTE      = ">="
 	EQ       = "=="
 	NOTEQUAL = "!="
 	POW      = "**"
+	AND      = "&&"
+	OR       = "||"
+
+	// Assignment operators.
+	PLUSASSIGN     = "+="
+	MINUSASSIGN    = "-="
+	ASTERISKASSIGN = "*="
+	SLASHASSIGN    = "/="
+	PERCENTASSIGN  = "%="
 
 	// Delimiters.
 	COMMA     = ","
@@ -33,15 +47,22 @@ const (
 	RBRACE    = "}"
 	LBRACKET  = "["
 	RBRACKET  = "]"
+	ELLIPSIS  = "..."
 
 	// Keywords.
-	FUNC   = "FUNC"
-	LET    = "LET"
-	TRUE   = "TRUE"
-	FALSE  = "FALSE"
-	IF     = "IF"
-	ELSE   = "ELSE"
-	RETURN = "RETURN"
+	FUNC     = "FUNC"
+	LET      = "LET"
+	TRUE     = "TRUE"
+	FALSE    = "FALSE"
+	IF       = "IF"
+	ELSE     = "ELSE"
+	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MACRO    = "MACRO"
 )
 
 // Type represents token's type.
@@ -51,16 +72,24 @@ type Type string
 type Token struct {
 	Type    Type
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the token
 }
 
 var keywordsLookup = map[string]Type{
-	"fn":     FUNC,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNC,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
 }
 
 // LookupIdent returns a type of identifier.
@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"github.com/dstdfx/scroopy/code"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/token"
+)
+
+// Frame represents a call of a compiled function.
+type Frame struct {
+	cl          *object.Closure
+	ip          int // offset of the next instruction to execute
+	start       int // offset of the instruction being executed
+	basePointer int // stack index of the first local variable
+}
+
+// NewFrame returns new instance of Frame.
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		cl:          cl,
+		basePointer: basePointer,
+	}
+}
+
+// Instructions returns instructions of the called function.
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// Pos returns the source position of the instruction being executed.
+func (f *Frame) Pos() token.Position {
+	return f.cl.Fn.SourceMap.Lookup(f.start)
+}
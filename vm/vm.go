@@ -0,0 +1,674 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/dstdfx/scroopy/ast"
+	"github.com/dstdfx/scroopy/code"
+	"github.com/dstdfx/scroopy/compiler"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/object"
+)
+
+const (
+	// StackSize is the initial number of values the stack holds,
+	// it grows up to MaxStackSize as needed.
+	StackSize = 2048
+	// MaxStackSize is the maximum number of values on the stack.
+	MaxStackSize = 1 << 24
+	// GlobalsSize is the maximum number of global variables.
+	GlobalsSize = 65536
+	// MaxFrames is the maximum depth of function calls, the same as the one
+	// of the evaluator plus the frame of the program itself.
+	MaxFrames = evaluator.DefaultMaxDepth + 1
+)
+
+var infixOperators = [...]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+// VM executes bytecode produced by the compiler.
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+	builtins    map[string]*object.BuildIn
+
+	stack []object.Object
+	sp    int // points to the next free slot, the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	// openUpvalues holds upvalues pointing to the stack, by stack index.
+	openUpvalues map[int]*object.Upvalue
+}
+
+// New returns new instance of VM.
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore returns new instance of VM that uses the given
+// global variables, e.g. the ones defined by the previous REPL line.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+	}
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = NewFrame(&object.Closure{Fn: mainFn}, 0)
+
+	return &VM{
+		constants:    bytecode.Constants,
+		globals:      globals,
+		globalNames:  bytecode.GlobalNames,
+		stack:        make([]object.Object, StackSize),
+		frames:       frames,
+		framesIndex:  1,
+		openUpvalues: make(map[int]*object.Upvalue),
+	}
+}
+
+// SetBuiltins replaces the build-in functions with the ones of the same name
+// in the map, functions missing from it are taken from object.Builtins.
+func (vm *VM) SetBuiltins(builtins map[string]*object.BuildIn) {
+	vm.builtins = builtins
+}
+
+func (vm *VM) builtin(idx int) *object.BuildIn {
+	def := object.Builtins[idx]
+	if buildIn, ok := vm.builtins[def.Name]; ok {
+		return buildIn
+	}
+
+	return def.BuildIn
+}
+
+// Run executes the program and returns the value of its last statement,
+// the same way evaluator.Eval does. Runtime errors are returned as
+// *object.Error annotated with the position and the call stack.
+func (vm *VM) Run() object.Object {
+	for {
+		frame := vm.currentFrame()
+		ins := frame.Instructions()
+
+		frame.start = frame.ip
+		op := code.Opcode(ins[frame.ip])
+		frame.ip++
+
+		switch op {
+		case code.OpConstant:
+			constIdx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+
+			if err := vm.push(vm.constants[constIdx]); err != nil {
+				return err
+			}
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual:
+			left := vm.pop()
+			right := vm.pop()
+
+			if err := vm.pushResult(object.InfixOperation(infixOperators[op], left, right)); err != nil {
+				return err
+			}
+		case code.OpMinus:
+			if err := vm.pushResult(object.PrefixOperation("-", vm.pop())); err != nil {
+				return err
+			}
+		case code.OpBang:
+			if err := vm.pushResult(object.PrefixOperation("!", vm.pop())); err != nil {
+				return err
+			}
+		case code.OpTrue:
+			if err := vm.push(object.TRUE); err != nil {
+				return err
+			}
+		case code.OpFalse:
+			if err := vm.push(object.FALSE); err != nil {
+				return err
+			}
+		case code.OpNull:
+			if err := vm.push(object.NULL); err != nil {
+				return err
+			}
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[frame.ip:]))
+		case code.OpJumpNotTruthy:
+			target := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			if !object.IsTruthy(vm.pop()) {
+				frame.ip = target
+			}
+		case code.OpJumpTruthyOrPop, code.OpJumpNotTruthyOrPop:
+			target := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			if object.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpTruthyOrPop) {
+				frame.ip = target
+			} else {
+				vm.pop()
+			}
+		case code.OpJumpBound:
+			target := int(code.ReadUint16(ins[frame.ip:]))
+			localIdx := int(code.ReadUint8(ins[frame.ip+2:]))
+			frame.ip += 3
+
+			if vm.stack[frame.basePointer+localIdx] != nil {
+				frame.ip = target
+			}
+		case code.OpGetGlobal:
+			globalIdx := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			value := vm.globals[globalIdx]
+			if value == nil {
+				return vm.fail("identifier not found: %s", vm.globalName(globalIdx))
+			}
+
+			if err := vm.push(value); err != nil {
+				return err
+			}
+		case code.OpSetGlobal:
+			globalIdx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+
+			vm.globals[globalIdx] = vm.pop()
+		case code.OpAssignGlobal:
+			globalIdx := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			if vm.globals[globalIdx] == nil {
+				return vm.fail("identifier not found: %s", vm.globalName(globalIdx))
+			}
+			vm.globals[globalIdx] = vm.pop()
+		case code.OpGetLocal:
+			localIdx := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			value := vm.stack[frame.basePointer+localIdx]
+			if value == nil {
+				value = object.NULL
+			}
+
+			if err := vm.push(value); err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIdx := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			vm.stack[frame.basePointer+localIdx] = vm.pop()
+		case code.OpGetBuiltin:
+			builtinIdx := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+
+			if err := vm.push(vm.builtin(int(builtinIdx))); err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIdx := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+
+			if err := vm.push(*frame.cl.Free[freeIdx].Location); err != nil {
+				return err
+			}
+		case code.OpSetFree:
+			freeIdx := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+
+			*frame.cl.Free[freeIdx].Location = vm.pop()
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp -= numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.pushResult(object.IndexOperation(left, index)); err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			operator := code.Opcode(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			if err := vm.setIndex(operator); err != nil {
+				return err
+			}
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			if err := vm.call(numArgs); err != nil {
+				return err
+			}
+		case code.OpCallSpread:
+			numArrays := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			if err := vm.callSpread(numArrays); err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				return returnValue
+			}
+
+			vm.returnFromFrame()
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			vm.returnFromFrame()
+			if err := vm.push(object.NULL); err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIdx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+
+			if err := vm.pushClosure(int(constIdx)); err != nil {
+				return err
+			}
+		case code.OpIter:
+			iterable := vm.pop()
+
+			iterator, ok := object.NewIterator(iterable)
+			if !ok {
+				return vm.fail("cannot iterate over %s", iterable.Type())
+			}
+
+			if err := vm.push(iterator); err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			target := int(code.ReadUint16(ins[frame.ip:]))
+			numVars := code.ReadUint8(ins[frame.ip+2:])
+			frame.ip += 3
+
+			if err := vm.iterNext(numVars, target); err != nil {
+				return err
+			}
+		case code.OpQuote:
+			constIdx := code.ReadUint16(ins[frame.ip:])
+			numValues := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			values := make([]object.Object, numValues)
+			copy(values, vm.stack[vm.sp-numValues:vm.sp])
+			vm.sp -= numValues
+
+			call := vm.constants[constIdx].(*object.Quote).Node.(*ast.CallExpression)
+			if err := vm.pushResult(object.QuoteCall(call, values)); err != nil {
+				return err
+			}
+		default:
+			return vm.fail("unknown opcode: %d", op)
+		}
+	}
+}
+
+// CallStack returns the stack of function calls, innermost call first.
+func (vm *VM) CallStack() []object.Frame {
+	stack := make([]object.Frame, 0, vm.framesIndex-1)
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		stack = append(stack, object.Frame{
+			Function: vm.frames[i].cl.Fn.Name,
+			Pos:      vm.frames[i-1].Pos(),
+		})
+	}
+
+	return stack
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if !vm.grow(vm.sp + 1) {
+		return vm.fail("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// grow makes the stack hold at least size values, reallocating it as needed.
+// Open upvalues are moved along with the values they point to. False is
+// returned if the size exceeds MaxStackSize.
+func (vm *VM) grow(size int) bool {
+	if size <= len(vm.stack) {
+		return true
+	}
+	if size > MaxStackSize {
+		return false
+	}
+
+	newSize := 2 * len(vm.stack)
+	for newSize < size {
+		newSize *= 2
+	}
+	if newSize > MaxStackSize {
+		newSize = MaxStackSize
+	}
+
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+	for slot, upvalue := range vm.openUpvalues {
+		upvalue.Location = &vm.stack[slot]
+	}
+
+	return true
+}
+
+// pushResult pushes the result of an operation, errors are returned
+// annotated instead.
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if errObj, ok := o.(*object.Error); ok {
+		return vm.annotate(errObj)
+	}
+
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) call(numArgs int) *object.Error {
+	switch callee := vm.stack[vm.sp-1-numArgs].(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.BuildIn:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp -= numArgs + 1
+
+		result := callee.Fn(args...)
+		if result == nil {
+			result = object.NULL
+		}
+
+		return vm.pushResult(result)
+	default:
+		return vm.fail("not a function: %s", callee.Type())
+	}
+}
+
+// callSpread replaces the arrays on top of the stack with their elements
+// and calls the function below them.
+func (vm *VM) callSpread(numArrays int) *object.Error {
+	arrays := make([]object.Object, numArrays)
+	copy(arrays, vm.stack[vm.sp-numArrays:vm.sp])
+	vm.sp -= numArrays
+
+	numArgs := 0
+	for _, arg := range arrays {
+		array, ok := arg.(*object.Array)
+		if !ok {
+			return vm.fail("spread argument must be ARRAY, got %s", arg.Type())
+		}
+
+		for _, el := range array.Elements {
+			if err := vm.push(el); err != nil {
+				return err
+			}
+		}
+		numArgs += len(array.Elements)
+	}
+
+	return vm.call(numArgs)
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	fn := cl.Fn
+	if err := object.CheckArity(fn.NumParameters-fn.NumDefaults, fn.NumDefaults, fn.Variadic, numArgs); err != nil {
+		return vm.annotate(err)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return vm.fail("stack overflow")
+	}
+
+	numParameters := fn.NumParameters
+	var rest *object.Array
+	if fn.Variadic {
+		numParameters++
+		rest = &object.Array{Elements: make([]object.Object, 0)}
+		if numArgs > fn.NumParameters {
+			rest.Elements = append(rest.Elements, vm.stack[vm.sp-(numArgs-fn.NumParameters):vm.sp]...)
+			vm.sp -= numArgs - fn.NumParameters
+			numArgs = fn.NumParameters
+		}
+	}
+
+	basePointer := vm.sp - numArgs
+	if !vm.grow(basePointer + fn.NumLocals) {
+		return vm.fail("stack overflow")
+	}
+
+	// Parameters without arguments are left unset for OpJumpBound
+	// to evaluate their default values.
+	for ; numArgs < fn.NumParameters; numArgs++ {
+		vm.stack[basePointer+numArgs] = nil
+	}
+	if rest != nil {
+		vm.stack[basePointer+fn.NumParameters] = rest
+	}
+
+	// Clear the slots of local variables left from the previous calls.
+	for i := basePointer + numParameters; i < basePointer+fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.frames[vm.framesIndex] = NewFrame(cl, basePointer)
+	vm.framesIndex++
+	vm.sp = basePointer + fn.NumLocals
+
+	return nil
+}
+
+// returnFromFrame pops the current frame along with its locals and
+// the called closure.
+func (vm *VM) returnFromFrame() {
+	frame := vm.currentFrame()
+	vm.closeUpvalues(frame.basePointer)
+
+	vm.framesIndex--
+	vm.sp = frame.basePointer - 1
+}
+
+func (vm *VM) pushClosure(constIdx int) *object.Error {
+	fn, ok := vm.constants[constIdx].(*object.CompiledFunction)
+	if !ok {
+		return vm.fail("not a function: %+v", vm.constants[constIdx])
+	}
+
+	frame := vm.currentFrame()
+	free := make([]*object.Upvalue, len(fn.Captures))
+	for i, capture := range fn.Captures {
+		if capture.IsLocal {
+			free[i] = vm.captureUpvalue(frame.basePointer + capture.Index)
+		} else {
+			free[i] = frame.cl.Free[capture.Index]
+		}
+	}
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+// captureUpvalue returns the upvalue pointing to the given stack slot,
+// closures capturing the same variable share the upvalue.
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	if upvalue, ok := vm.openUpvalues[slot]; ok {
+		return upvalue
+	}
+
+	upvalue := &object.Upvalue{Location: &vm.stack[slot]}
+	vm.openUpvalues[slot] = upvalue
+
+	return upvalue
+}
+
+// closeUpvalues closes every upvalue pointing to the stack at or above
+// the given slot.
+func (vm *VM) closeUpvalues(from int) {
+	if len(vm.openUpvalues) == 0 {
+		return
+	}
+
+	for slot, upvalue := range vm.openUpvalues {
+		if slot >= from {
+			upvalue.Close()
+			delete(vm.openUpvalues, slot)
+		}
+	}
+}
+
+// iterNext pushes the next values of the iterator on top of the stack,
+// an exhausted iterator is popped and the execution jumps to the target.
+func (vm *VM) iterNext(numVars uint8, target int) *object.Error {
+	iterator := vm.stack[vm.sp-1].(*object.Iterator)
+
+	if numVars == 1 {
+		value, ok := iterator.Next()
+		if !ok {
+			vm.pop()
+			vm.currentFrame().ip = target
+
+			return nil
+		}
+
+		return vm.push(value)
+	}
+
+	key, value, ok := iterator.NextPair()
+	if !ok {
+		vm.pop()
+		vm.currentFrame().ip = target
+
+		return nil
+	}
+
+	if err := vm.push(key); err != nil {
+		return err
+	}
+
+	return vm.push(value)
+}
+
+// setIndex pops the index, the indexed object and the value, then stores
+// the value, combined with the current one by the operator if it's not 0.
+func (vm *VM) setIndex(operator code.Opcode) *object.Error {
+	index := vm.pop()
+	left := vm.pop()
+	value := vm.pop()
+
+	if operator != 0 {
+		current := object.IndexOperation(left, index)
+		if errObj, ok := current.(*object.Error); ok {
+			return vm.annotate(errObj)
+		}
+
+		value = object.InfixOperation(infixOperators[operator], current, value)
+		if errObj, ok := value.(*object.Error); ok {
+			return vm.annotate(errObj)
+		}
+	}
+
+	if errObj, ok := object.SetIndexOperation(left, index, value).(*object.Error); ok {
+		return vm.annotate(errObj)
+	}
+
+	return nil
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hash, ok := key.(object.Hashable)
+		if !ok {
+			return nil, vm.fail("unusable as hash key: %s", key.Type())
+		}
+
+		pairs[hash.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.HashMap{ObjType: object.HashObj, Pairs: pairs}, nil
+}
+
+func (vm *VM) globalName(globalIdx int) string {
+	if globalIdx < len(vm.globalNames) {
+		return vm.globalNames[globalIdx]
+	}
+
+	return fmt.Sprintf("<global %d>", globalIdx)
+}
+
+func (vm *VM) fail(format string, a ...interface{}) *object.Error {
+	return vm.annotate(&object.Error{Message: fmt.Sprintf(format, a...)})
+}
+
+// annotate sets the position of the instruction being executed and
+// the call stack to the error, unless it already has them.
+func (vm *VM) annotate(errObj *object.Error) *object.Error {
+	if !errObj.Pos.IsValid() {
+		errObj.Pos = vm.currentFrame().Pos()
+		errObj.Trace = vm.CallStack()
+	}
+
+	return errObj
+}
@@ -0,0 +1,171 @@
+package vm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dstdfx/scroopy/compiler"
+	"github.com/dstdfx/scroopy/evaluator"
+	"github.com/dstdfx/scroopy/lexer"
+	"github.com/dstdfx/scroopy/object"
+	"github.com/dstdfx/scroopy/parser"
+	"github.com/dstdfx/scroopy/vm"
+)
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			input: `let newAdder = fn(a, b) { fn(c) { a + b + c } };
+let adder = newAdder(1, 2);
+adder(8);`,
+			expected: 11,
+		},
+		{
+			input: `let newClosure = fn(a, b) {
+  let one = fn() { a; };
+  let two = fn() { b; };
+  fn() { one() + two(); };
+};
+let closure = newClosure(9, 90);
+closure();`,
+			expected: 99,
+		},
+		{
+			input: `let wrapper = fn() {
+  let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+  countDown(1);
+};
+wrapper();`,
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		result := run(t, tt.input)
+
+		integer, ok := result.(*object.Integer)
+		if !ok {
+			t.Errorf("object is not Integer. got=%T (%+v)", result, result)
+
+			continue
+		}
+
+		if integer.Value != tt.expected {
+			t.Errorf("wrong value for %q. want=%d, got=%d", tt.input, tt.expected, integer.Value)
+		}
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedPos     string
+	}{
+		{"let f = fn(a, b) { a }; f(1)", "wrong number of arguments: want=2, got=1", "1:25"},
+		{"let f = fn(n) { f(n + 1) }; f(0)", "stack overflow", "1:17"},
+		{"1(2)", "not a function: INTEGER", "1:1"},
+		{"fn() { x }()", "identifier not found: x", "1:8"},
+	}
+
+	for _, tt := range tests {
+		result := run(t, tt.input)
+
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, result, result)
+
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. want=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. want=%s, got=%s", tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewGlobalSymbolTable()
+	constants := []object.Object{}
+
+	for _, input := range []string{"let a = 40;", "let b = fn() { a + 2 };", "b()"} {
+		c := compiler.NewWithState(symbolTable, constants)
+		if err := c.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := c.Bytecode()
+		constants = bytecode.Constants
+
+		result := vm.NewWithGlobalsStore(bytecode, globals).Run()
+		if input == "b()" && result.Inspect() != "42" {
+			t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+		}
+	}
+}
+
+func run(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	c := compiler.New()
+	if err := c.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return vm.New(c.Bytecode()).Run()
+}
+
+const fibInput = `let fibonacci = fn(x) {
+  if (x < 2) {
+    return x;
+  }
+
+  fibonacci(x - 1) + fibonacci(x - 2);
+};
+fibonacci(20);`
+
+func BenchmarkFibonacci_VM(b *testing.B) {
+	root := parser.New(lexer.New(fibInput)).ParseProgram()
+
+	for i := 0; i < b.N; i++ {
+		c := compiler.New()
+		if err := c.Compile(root); err != nil {
+			b.Fatal(err)
+		}
+
+		vm.New(c.Bytecode()).Run()
+	}
+}
+
+func BenchmarkFibonacci_Evaluator(b *testing.B) {
+	root := parser.New(lexer.New(fibInput)).ParseProgram()
+
+	for i := 0; i < b.N; i++ {
+		evaluator.Eval(root, object.NewEnvironment())
+	}
+}
+
+func TestStackOverflowTraceback(t *testing.T) {
+	result := run(t, "let f = fn(n) { f(n + 1) };\nf(0)")
+
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", result, result)
+	}
+
+	// The recursive calls collapse into a single line.
+	expected := "\tat f (called at 1:17)\n" +
+		fmt.Sprintf("\t... repeated %d more times\n", vm.MaxFrames-3) +
+		"\tat f (called at 2:1)\n"
+	if errObj.Traceback() != expected {
+		t.Errorf("wrong traceback. want=%q, got=%q", expected, errObj.Traceback())
+	}
+}